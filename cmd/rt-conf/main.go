@@ -1,20 +1,45 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/canonical/go-snapctl/env"
+	"github.com/canonical/rt-conf/src/audit"
+	"github.com/canonical/rt-conf/src/cpuidle"
 	"github.com/canonical/rt-conf/src/debug"
+	"github.com/canonical/rt-conf/src/hotplug"
+	"github.com/canonical/rt-conf/src/hugepages"
 	"github.com/canonical/rt-conf/src/irq"
 	"github.com/canonical/rt-conf/src/kcmd"
+	"github.com/canonical/rt-conf/src/machine"
+	"github.com/canonical/rt-conf/src/memtuning"
 	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/netsteering"
+	"github.com/canonical/rt-conf/src/pmqos"
 	pwrmgmt "github.com/canonical/rt-conf/src/pwr_mgmt"
+	"github.com/canonical/rt-conf/src/sysctl"
+	"github.com/canonical/rt-conf/src/systemd"
 )
 
+// Subcommands which run instead of the default apply mode
+var commands = map[string]func(args []string) error{
+	"audit":         runAudit,
+	"inventory":     runInventory,
+	"pm-qos":        runPmQos,
+	"revert-sysctl": runRevertSysctl,
+	"status":        runStatus,
+	"validate":      runValidate,
+	"watch":         runWatch,
+}
+
 func main() {
 	if err := run(os.Args); err != nil {
 		log.Fatal("Error: ", err)
@@ -22,6 +47,21 @@ func main() {
 }
 
 func run(args []string) error {
+	if len(args) > 1 {
+		if cmd, ok := commands[args[1]]; ok {
+			return cmd(append([]string{args[0] + " " + args[1]}, args[2:]...))
+		}
+	}
+	return runApply(args)
+}
+
+// commonFlags holds the flags shared by all modes
+type commonFlags struct {
+	configPath *string
+	verbose    *bool
+}
+
+func newFlagSet(name string) (*flag.FlagSet, commonFlags, error) {
 	envConfigFile := os.Getenv("CONFIG_FILE")
 	verboseDefaultCfg := false
 	var err error
@@ -29,55 +69,92 @@ func run(args []string) error {
 	if ok {
 		verboseDefaultCfg, err = strconv.ParseBool(envVerbose)
 		if err != nil {
-			return fmt.Errorf("failed to parse verbose configuration: %v", err)
+			return nil, commonFlags{},
+				fmt.Errorf("failed to parse verbose configuration: %v", err)
 		}
 	}
 
-	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
-	configPath := flags.String("file",
-		envConfigFile,
-		"Path to the configuration file")
-	grubCfgPath := flags.String("grub-custom-file",
-		"/etc/default/grub.d/60_rt-conf.cfg",
-		"Path to the output drop-in grub configuration file, relevant only for GRUB bootloader")
-	verbose := flags.Bool("verbose",
-		verboseDefaultCfg,
-		"Verbose mode, prints more information to the console")
-
-	if err := flags.Parse(args[1:]); err != nil {
-		return fmt.Errorf("failed to parse flags: %v", err)
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	common := commonFlags{
+		configPath: flags.String("file",
+			envConfigFile,
+			"Path to the configuration file"),
+		verbose: flags.Bool("verbose",
+			verboseDefaultCfg,
+			"Verbose mode, prints more information to the console"),
 	}
+	return flags, common, nil
+}
 
+// loadConfig sets up logging and loads the configuration file
+func loadConfig(common commonFlags) (*model.InternalConfig, error) {
 	log.SetFlags(0)
 
-	if *verbose {
+	if *common.verbose {
 		fmt.Println("Verbose mode enabled")
 		debug.Enable()
 	}
 
-	if *configPath == "" {
+	if *common.configPath == "" {
 		flag.PrintDefaults()
-		return fmt.Errorf("failed to load config file: path not set")
+		return nil, fmt.Errorf("failed to load config file: path not set")
 	}
 
 	var conf model.InternalConfig
 
-	if err := conf.Data.LoadFromFile(*configPath); err != nil {
-		return fmt.Errorf("failed to load config file: %w", err)
+	if err := conf.Data.LoadFromFile(*common.configPath); err != nil {
+		return nil, fmt.Errorf("failed to load config file: %w", err)
 	}
 
 	// If running as a snap, override config with snap options
 	if env.Snap() != "" {
 		if err := conf.Data.LoadSnapOptions(); err != nil {
-			return fmt.Errorf("failed to load config from snap options: %v", err)
+			return nil, fmt.Errorf("failed to load config from snap options: %v", err)
 		}
 	}
 
+	return &conf, nil
+}
+
+func runApply(args []string) error {
+	flags, common, err := newFlagSet(args[0])
+	if err != nil {
+		return err
+	}
+	grubCfgPath := flags.String("grub-custom-file",
+		"/etc/default/grub.d/60_rt-conf.cfg",
+		"Path to the output drop-in grub configuration file, relevant only for GRUB bootloader")
+	irqbalanceCfgPath := flags.String("irqbalance-file",
+		"",
+		"Path to the irqbalance configuration file to update, banning the isolated CPUs and tuned IRQs from balancing")
+	sysctlCfgPath := flags.String("sysctl-file",
+		"",
+		"Path to the output drop-in sysctl configuration file, persisting the sysctl values across reboots")
+	systemdDir := flags.String("systemd-dir",
+		"/etc/systemd",
+		"Path to the systemd configuration directory, in which the drop-in files setting the systemd CPU affinity are created")
+	strict := flags.Bool("strict",
+		false,
+		"Strict mode, fails when a runtime setting is not applied as requested")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+
+	conf, err := loadConfig(common)
+	if err != nil {
+		return err
+	}
+
 	conf.GrubCfg = model.Grub{
 		GrubDropInFile: *grubCfgPath,
 	}
+	conf.IrqbalanceCfgFile = *irqbalanceCfgPath
+	conf.SysctlDropInFile = *sysctlCfgPath
+	conf.SystemdDir = *systemdDir
+	conf.Strict = *strict
 
-	if msgs, err := kcmd.ProcessKcmdArgs(&conf); err != nil {
+	if msgs, err := kcmd.ProcessKcmdArgs(conf); err != nil {
 		return fmt.Errorf("failed to process kernel cmdline args: %v", err)
 	} else {
 		for _, msg := range msgs {
@@ -85,13 +162,253 @@ func run(args []string) error {
 		}
 	}
 
-	if err := irq.ApplyIRQConfig(&conf); err != nil {
+	if err := sysctl.ApplySysctlConfig(conf); err != nil {
+		return fmt.Errorf("failed to process sysctl config: %v", err)
+	}
+
+	if err := memtuning.ApplyMemoryTuningConfig(conf); err != nil {
+		return fmt.Errorf("failed to process memory tuning config: %v", err)
+	}
+
+	if err := hugepages.ApplyHugepagesConfig(conf); err != nil {
+		return fmt.Errorf("failed to process hugepages config: %v", err)
+	}
+
+	if err := systemd.ApplySystemdConfig(conf); err != nil {
+		return fmt.Errorf("failed to process systemd affinity config: %v", err)
+	}
+
+	// CPU hotplug goes first, so the IRQ and CPU governance rules get
+	// re-applied to the CPUs it brings online or takes offline
+	if err := hotplug.ApplyHotplugConfig(conf); err != nil {
+		return fmt.Errorf("failed to process cpu hotplug config: %v", err)
+	}
+
+	// The default affinity goes before the IRQ tuning rules, so the rules
+	// can still assign IRQs to the CPUs they were moved off
+	if err := irq.ApplyIRQDefaults(conf); err != nil {
+		return fmt.Errorf("failed to process irq affinity: %v", err)
+	}
+
+	if err := irq.ApplyIRQConfig(conf); err != nil {
 		return fmt.Errorf("failed to process interrupts: %v", err)
 	}
 
-	if err := pwrmgmt.ApplyPwrConfig(&conf); err != nil {
+	if err := netsteering.ApplyNetSteeringConfig(conf); err != nil {
+		return fmt.Errorf("failed to process network steering config: %v", err)
+	}
+
+	if err := pwrmgmt.ApplyPwrConfig(conf); err != nil {
 		return fmt.Errorf("failed to process power management config: %v", err)
 	}
 
+	if err := pwrmgmt.ApplyUncoreConfig(conf); err != nil {
+		return fmt.Errorf("failed to process uncore frequency config: %v", err)
+	}
+
+	if err := cpuidle.ApplyCpuIdleConfig(conf); err != nil {
+		return fmt.Errorf("failed to process cpu idle config: %v", err)
+	}
+
+	return nil
+}
+
+// runPmQos holds the PM QoS requests until the process is stopped
+func runPmQos(args []string) error {
+	flags, common, err := newFlagSet(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+
+	conf, err := loadConfig(common)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := pmqos.HoldPmQosConfig(ctx, conf); err != nil {
+		return fmt.Errorf("failed to hold pm qos requests: %v", err)
+	}
+	return nil
+}
+
+// runWatch applies the IRQ tuning rules to new IRQs until the process is stopped
+func runWatch(args []string) error {
+	flags, common, err := newFlagSet(args[0])
+	if err != nil {
+		return err
+	}
+	interval := flags.Duration("interval",
+		2*time.Second,
+		"Interval between checks for new IRQs")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+
+	if *interval <= 0 {
+		return fmt.Errorf("invalid interval: %v", *interval)
+	}
+
+	conf, err := loadConfig(common)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := irq.WatchIRQs(ctx, conf, *interval); err != nil {
+		return fmt.Errorf("failed to watch interrupts: %v", err)
+	}
+	return nil
+}
+
+// runStatus checks whether the runtime settings still match the config
+func runStatus(args []string) error {
+	flags, common, err := newFlagSet(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+
+	conf, err := loadConfig(common)
+	if err != nil {
+		return err
+	}
+
+	if err := irq.CheckIRQStatus(conf); err != nil {
+		return fmt.Errorf("failed to check interrupts: %v", err)
+	}
+	return nil
+}
+
+// runRevertSysctl restores the sysctl values found before the first apply
+func runRevertSysctl(args []string) error {
+	flags, _, err := newFlagSet(args[0])
+	if err != nil {
+		return err
+	}
+	sysctlCfgPath := flags.String("sysctl-file",
+		"",
+		"Path to the drop-in sysctl configuration file to be removed")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+	log.SetFlags(0)
+
+	if err := sysctl.RevertSysctlConfig(*sysctlCfgPath); err != nil {
+		return fmt.Errorf("failed to revert sysctl values: %v", err)
+	}
+	return nil
+}
+
+// runValidate validates the configuration file, against a target machine
+// description when given instead of the local machine
+func runValidate(args []string) error {
+	flags, common, err := newFlagSet(args[0])
+	if err != nil {
+		return err
+	}
+	targetPath := flags.String("target",
+		"",
+		"Path to the description of the target machine, as written by the inventory command")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+	log.SetFlags(0)
+
+	if *common.configPath == "" {
+		flags.PrintDefaults()
+		return fmt.Errorf("failed to load config file: path not set")
+	}
+
+	if *targetPath == "" {
+		if _, err := model.ReadYAML(*common.configPath); err != nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+		log.Printf("%s is valid\n", *common.configPath)
+		return nil
+	}
+
+	target, err := machine.Load(*targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to load target machine: %v", err)
+	}
+	restore, err := target.Use()
+	if err != nil {
+		return fmt.Errorf("failed to load target machine: %v", err)
+	}
+	defer restore()
+
+	cfg, err := model.ReadYAML(*common.configPath)
+	if err != nil {
+		return fmt.Errorf("invalid config for %s: %v", *targetPath, err)
+	}
+	if err := target.Check(cfg); err != nil {
+		return fmt.Errorf("invalid config for %s: %v", *targetPath, err)
+	}
+	log.Printf("%s is valid for %s\n", *common.configPath, *targetPath)
+	return nil
+}
+
+// runInventory describes the local machine, for bug reports or to be used
+// as a validation target
+func runInventory(args []string) error {
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+	outputPath := flags.String("o",
+		"",
+		"Path to the output file, instead of the standard output")
+	format := flags.String("format",
+		"yaml",
+		"Output format, yaml or json")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+
+	m, err := machine.Capture()
+	if err != nil {
+		return fmt.Errorf("failed to describe the machine: %v", err)
+	}
+	content, err := m.Marshal(*format)
+	if err != nil {
+		return err
+	}
+	if *outputPath == "" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	if err := os.WriteFile(*outputPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write to %s file: %v", *outputPath, err)
+	}
+	return nil
+}
+
+// runAudit checks whether the local system is suitable for real-time
+// workloads, and suggests the config fixing each finding
+func runAudit(args []string) error {
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+	log.SetFlags(0)
+
+	if err := audit.RunAudit(); err != nil {
+		return fmt.Errorf("audit failed: %v", err)
+	}
 	return nil
 }
@@ -130,6 +130,22 @@ cpu-governance:
   "bar":
     cpus: "0"
     scaling-governor: "performance"
+`,
+		},
+		{
+			name: "Invalid watch interval",
+			args: []string{"rt-conf", "watch", "-interval", "0s", "-file", configPath},
+			err:  "invalid interval",
+			yaml: `
+irq-tuning:
+`,
+		},
+		{
+			name: "No PM QoS requests",
+			args: []string{"rt-conf", "pm-qos", "-file", configPath},
+			err:  "no PM QoS requests found in config",
+			yaml: `
+cpu-governance:
 `,
 		},
 	}
@@ -4,5 +4,8 @@ go 1.22.2
 
 require (
 	github.com/canonical/go-snapctl v1.0.0-beta.3
+	github.com/godbus/dbus/v5 v5.2.2
 	go.yaml.in/yaml/v4 v4.0.0-rc.3
 )
+
+require golang.org/x/sys v0.27.0 // indirect
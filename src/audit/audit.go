@@ -0,0 +1,278 @@
+package audit
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/machine"
+	"github.com/canonical/rt-conf/src/memtuning"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// The audit checks whether the system is suitable for real-time workloads,
+// and suggests the rt-conf config which fixes each finding.
+// See: https://wiki.linuxfoundation.org/realtime/documentation/howto/applications/preemptrt_setup
+
+// Severity of a finding
+type Severity int
+
+const (
+	// Worth knowing, but not expected to cause latency spikes by itself
+	Info Severity = iota
+	// Likely to cause latency spikes
+	Warning
+	// Makes the system unsuitable for real-time workloads
+	Critical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Critical:
+		return "critical"
+	case Warning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// Finding is an issue making the system less suitable for real-time
+type Finding struct {
+	// Name of the check, e.g. smt
+	Check    string
+	Severity Severity
+	Message  string
+	// rt-conf config which fixes the issue, empty if rt-conf can't
+	Suggestion string
+}
+
+// Kthread is a kernel thread along with the CPUs it is allowed to run on
+type Kthread struct {
+	PID  int
+	Name string
+	CPUs string
+}
+
+// State is what the checks inspect: the machine description along with the
+// runtime settings which aren't part of it
+type State struct {
+	Machine *machine.Machine
+	// SMT control, e.g. on, off or notsupported, empty if not reported
+	SMTControl string
+	// Whether turbo or boost is enabled, nil if not reported
+	Turbo *bool
+	// Base frequency of the CPUs in kHz, 0 if not reported
+	BaseFrequency     int
+	IrqbalanceRunning bool
+	// Selected transparent hugepages mode, e.g. always
+	THPEnabled string
+	// Value of kernel.timer_migration
+	TimerMigration string
+	Clocksource    string
+	// Clocksources the kernel can switch to
+	AvailableClocksources []string
+	Kthreads              []Kthread
+}
+
+type ReaderWriter struct {
+	SysPath  string
+	ProcPath string
+}
+
+var auditReaderWriter = ReaderWriter{
+	SysPath:  "/sys",
+	ProcPath: "/proc",
+}
+
+// ReadState reads the runtime settings inspected along with the machine
+// description
+func (rw ReaderWriter) ReadState(m *machine.Machine) (*State, error) {
+	state := State{Machine: m}
+	cpuDir := filepath.Join(rw.SysPath, "devices", "system", "cpu")
+	clocksourceDir := filepath.Join(rw.SysPath, "devices", "system", "clocksource", "clocksource0")
+
+	var available, thp string
+	files := []struct {
+		path  string
+		value *string
+	}{
+		{filepath.Join(cpuDir, "smt", "control"), &state.SMTControl},
+		{filepath.Join(rw.SysPath, "kernel", "mm", "transparent_hugepage", "enabled"), &thp},
+		{filepath.Join(rw.ProcPath, "sys", "kernel", "timer_migration"), &state.TimerMigration},
+		{filepath.Join(clocksourceDir, "current_clocksource"), &state.Clocksource},
+		{filepath.Join(clocksourceDir, "available_clocksource"), &available},
+	}
+	for _, file := range files {
+		value, err := utils.ReadOptional(file.path)
+		if err != nil {
+			return nil, err
+		}
+		*file.value = value
+	}
+	state.AvailableClocksources = strings.Fields(available)
+	if thp != "" {
+		_, selected, err := memtuning.ParseOptions(thp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transparent hugepages mode: %v", err)
+		}
+		state.THPEnabled = selected
+	}
+
+	turbo, err := rw.readTurbo(cpuDir)
+	if err != nil {
+		return nil, err
+	}
+	state.Turbo = turbo
+
+	baseFreq, err := utils.ReadOptional(filepath.Join(cpuDir, "cpu0", "cpufreq", "base_frequency"))
+	if err != nil {
+		return nil, err
+	}
+	if baseFreq != "" {
+		if state.BaseFrequency, err = strconv.Atoi(baseFreq); err != nil {
+			return nil, fmt.Errorf("invalid base frequency: %v", err)
+		}
+	}
+
+	if state.Kthreads, state.IrqbalanceRunning, err = rw.readProcesses(); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// readTurbo reads whether turbo is enabled, from intel_pstate or else from
+// the cpufreq boost switch
+func (rw ReaderWriter) readTurbo(cpuDir string) (*bool, error) {
+	noTurbo, err := utils.ReadOptional(filepath.Join(cpuDir, "intel_pstate", "no_turbo"))
+	if err != nil {
+		return nil, err
+	}
+	if noTurbo != "" {
+		turbo := noTurbo == "0"
+		return &turbo, nil
+	}
+
+	boost, err := utils.ReadOptional(filepath.Join(cpuDir, "cpufreq", "boost"))
+	if err != nil {
+		return nil, err
+	}
+	if boost != "" {
+		turbo := boost == "1"
+		return &turbo, nil
+	}
+	return nil, nil
+}
+
+// readProcesses reads the kernel threads, i.e. kthreadd and its children,
+// and whether irqbalance is running
+func (rw ReaderWriter) readProcesses() (kthreads []Kthread, irqbalance bool, err error) {
+	entries, err := os.ReadDir(rw.ProcPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading %s: %v", rw.ProcPath, err)
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue // Not a process
+		}
+		status, err := os.ReadFile(filepath.Join(rw.ProcPath, entry.Name(), "status"))
+		if err != nil {
+			continue // The process exited meanwhile
+		}
+
+		fields := make(map[string]string)
+		for _, line := range strings.Split(string(status), "\n") {
+			key, value, found := strings.Cut(line, ":")
+			if found {
+				fields[key] = strings.TrimSpace(value)
+			}
+		}
+		if fields["Name"] == "irqbalance" {
+			irqbalance = true
+		}
+		// kthreadd is PID 2
+		if pid != 2 && fields["PPid"] != "2" {
+			continue
+		}
+		kthreads = append(kthreads, Kthread{
+			PID:  pid,
+			Name: fields["Name"],
+			CPUs: fields["Cpus_allowed_list"],
+		})
+	}
+	sort.Slice(kthreads, func(i, j int) bool {
+		return kthreads[i].PID < kthreads[j].PID
+	})
+	return kthreads, irqbalance, nil
+}
+
+// Audit runs the checks, returning the findings sorted by decreasing
+// severity
+func Audit(state *State) ([]Finding, error) {
+	var findings []Finding
+	for _, check := range checks {
+		checkFindings, err := check(state)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, checkFindings...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity > findings[j].Severity
+	})
+	return findings, nil
+}
+
+// RunAudit audits the local system and logs the findings. It fails when
+// any finding is critical.
+func RunAudit() error {
+	utils.PrintTitle("Real-Time Readiness Audit")
+
+	m, err := machine.Capture()
+	if err != nil {
+		return fmt.Errorf("failed to describe the machine: %v", err)
+	}
+	state, err := auditReaderWriter.ReadState(m)
+	if err != nil {
+		return err
+	}
+
+	findings, err := Audit(state)
+	if err != nil {
+		return err
+	}
+	return logFindings(findings)
+}
+
+func logFindings(findings []Finding) error {
+	if len(findings) == 0 {
+		log.Println("No issues found")
+		return nil
+	}
+
+	counts := make(map[Severity]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+		log.Printf("[%s] %s: %s\n", f.Severity, f.Check, f.Message)
+		if f.Suggestion != "" {
+			log.Println("Suggested config:")
+			for _, line := range strings.Split(strings.TrimSuffix(f.Suggestion, "\n"), "\n") {
+				log.Printf("    %s\n", line)
+			}
+		}
+		log.Println()
+	}
+	log.Printf("%d critical, %d warning and %d info findings\n",
+		counts[Critical], counts[Warning], counts[Info])
+
+	if counts[Critical] > 0 {
+		return fmt.Errorf("%d critical findings", counts[Critical])
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/machine"
+)
+
+func TestMain(m *testing.M) {
+	log.SetFlags(0)
+	os.Exit(m.Run())
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+}
+
+func TestReadState(t *testing.T) {
+	root := t.TempDir()
+	rw := ReaderWriter{
+		SysPath:  filepath.Join(root, "sys"),
+		ProcPath: filepath.Join(root, "proc"),
+	}
+	writeFiles(t, root, map[string]string{
+		"sys/devices/system/cpu/smt/control":                                "on",
+		"sys/devices/system/cpu/intel_pstate/no_turbo":                      "0",
+		"sys/devices/system/cpu/cpu0/cpufreq/base_frequency":                "2100000",
+		"sys/kernel/mm/transparent_hugepage/enabled":                        "always [madvise] never",
+		"sys/devices/system/clocksource/clocksource0/current_clocksource":   "hpet",
+		"sys/devices/system/clocksource/clocksource0/available_clocksource": "tsc hpet acpi_pm",
+		"proc/sys/kernel/timer_migration":                                   "1",
+		"proc/1/status":                                                     "Name:\tsystemd\nPPid:\t0\nCpus_allowed_list:\t0-3",
+		"proc/2/status":                                                     "Name:\tkthreadd\nPPid:\t0\nCpus_allowed_list:\t0-1",
+		"proc/20/status":                                                    "Name:\tksoftirqd/2\nPPid:\t2\nCpus_allowed_list:\t2",
+		"proc/300/status":                                                   "Name:\tirqbalance\nPPid:\t1\nCpus_allowed_list:\t0-3",
+	})
+
+	m := &machine.Machine{}
+	state, err := rw.ReadState(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	turbo := true
+	expected := &State{
+		Machine:               m,
+		SMTControl:            "on",
+		Turbo:                 &turbo,
+		BaseFrequency:         2100000,
+		IrqbalanceRunning:     true,
+		THPEnabled:            "madvise",
+		TimerMigration:        "1",
+		Clocksource:           "hpet",
+		AvailableClocksources: []string{"tsc", "hpet", "acpi_pm"},
+		Kthreads: []Kthread{
+			{PID: 2, Name: "kthreadd", CPUs: "0-1"},
+			{PID: 20, Name: "ksoftirqd/2", CPUs: "2"},
+		},
+	}
+	if !reflect.DeepEqual(state, expected) {
+		t.Errorf("expected %+v, got %+v", expected, state)
+	}
+}
+
+func TestReadTurbo(t *testing.T) {
+	root := t.TempDir()
+	rw := ReaderWriter{SysPath: root, ProcPath: root}
+
+	turbo, err := rw.readTurbo(root)
+	if err != nil || turbo != nil {
+		t.Fatalf("expected (nil, nil) when not reported, got (%v, %v)", turbo, err)
+	}
+
+	writeFiles(t, root, map[string]string{"cpufreq/boost": "0"})
+	turbo, err = rw.readTurbo(root)
+	if err != nil || turbo == nil || *turbo {
+		t.Fatalf("expected turbo disabled by cpufreq boost, got (%v, %v)", turbo, err)
+	}
+}
+
+func TestLogFindings(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	findings := []Finding{
+		{Check: "smt", Severity: Warning, Message: "SMT is enabled",
+			Suggestion: kernelCmdlineSnippet("nosmt")},
+		{Check: "timer-migration", Severity: Info, Message: "Timer migration is enabled"},
+	}
+	if err := logFindings(findings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"[warning] smt: SMT is enabled",
+		"Suggested config:\n    kernel-cmdline:\n      parameters:\n        - nosmt\n",
+		"0 critical, 1 warning and 1 info findings",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in output:\n%s", want, buf.String())
+		}
+	}
+
+	findings = append(findings, Finding{Check: "preempt-rt", Severity: Critical})
+	if err := logFindings(findings); err == nil || err.Error() != "1 critical findings" {
+		t.Fatalf("expected critical findings error, got: %v", err)
+	}
+}
@@ -0,0 +1,517 @@
+package audit
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/machine"
+)
+
+// Idle states with a higher exit latency, in microseconds, are reported
+const maxIdleLatency = 10
+
+// Largest number of CPUs the kernel can be built for, see NR_CPUS
+const maxCPUs = 8192
+
+// Number of kernel threads named in a finding
+const maxNamedKthreads = 5
+
+// Checks in the order their findings are reported, for the same severity
+var checks = []func(*State) ([]Finding, error){
+	checkPreemptRT,
+	checkKernelConfig,
+	checkIsolation,
+	checkSMT,
+	checkIdleStates,
+	checkTurbo,
+	checkIrqbalance,
+	checkTHP,
+	checkTimerMigration,
+	checkClocksource,
+	checkIsolatedIRQs,
+	checkIsolatedKthreads,
+}
+
+func parseCPUs(list string) (cpulists.CPUs, error) {
+	if list == "" {
+		return make(cpulists.CPUs), nil
+	}
+	return cpulists.ParseForCPUs(list, maxCPUs)
+}
+
+func genCPUList(cpus cpulists.CPUs) string {
+	list := make([]int, 0, len(cpus))
+	for cpu := range cpus {
+		list = append(list, cpu)
+	}
+	sort.Ints(list)
+	return cpulists.GenCPUlist(list)
+}
+
+func intersects(a, b cpulists.CPUs) bool {
+	for cpu := range a {
+		if b[cpu] {
+			return true
+		}
+	}
+	return false
+}
+
+// isolatedCPUs returns the CPUs isolated via isolcpus or nohz_full
+func isolatedCPUs(m *machine.Machine) (cpulists.CPUs, error) {
+	isolated, err := parseCPUs(m.CPUs.Isolated)
+	if err != nil {
+		return nil, fmt.Errorf("invalid isolated CPUs: %v", err)
+	}
+	nohzFull, err := parseCPUs(m.CPUs.NohzFull)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nohz_full CPUs: %v", err)
+	}
+	for cpu := range nohzFull {
+		isolated[cpu] = true
+	}
+	return isolated, nil
+}
+
+// rtCPUs returns the CPUs the suggestions apply to: the isolated CPUs, or
+// else all the online CPUs but the first one, left for housekeeping
+func rtCPUs(m *machine.Machine) (cpulists.CPUs, error) {
+	isolated, err := isolatedCPUs(m)
+	if err != nil || len(isolated) > 0 {
+		return isolated, err
+	}
+	online, err := parseCPUs(m.CPUs.Online)
+	if err != nil {
+		return nil, fmt.Errorf("invalid online CPUs: %v", err)
+	}
+	first := -1
+	for cpu := range online {
+		if first == -1 || cpu < first {
+			first = cpu
+		}
+	}
+	delete(online, first)
+	return online, nil
+}
+
+// housekeepingCPUs returns the online CPUs which aren't isolated
+func housekeepingCPUs(m *machine.Machine, isolated cpulists.CPUs) (cpulists.CPUs, error) {
+	online, err := parseCPUs(m.CPUs.Online)
+	if err != nil {
+		return nil, fmt.Errorf("invalid online CPUs: %v", err)
+	}
+	for cpu := range isolated {
+		delete(online, cpu)
+	}
+	return online, nil
+}
+
+// cmdlineParam returns the value of a kernel command line parameter
+func cmdlineParam(cmdline, name string) (string, bool) {
+	for _, param := range strings.Fields(cmdline) {
+		key, value, _ := strings.Cut(param, "=")
+		if key == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func kernelCmdlineSnippet(params ...string) string {
+	snippet := "kernel-cmdline:\n  parameters:\n"
+	for _, param := range params {
+		snippet += fmt.Sprintf("    - %s\n", param)
+	}
+	return snippet
+}
+
+func checkPreemptRT(s *State) ([]Finding, error) {
+	if s.Machine.Realtime || s.Machine.KernelConfig["CONFIG_PREEMPT_RT"] == "y" {
+		return nil, nil
+	}
+	return []Finding{{
+		Check:    "preempt-rt",
+		Severity: Critical,
+		Message: "The kernel is not a PREEMPT_RT kernel, install a real-time kernel, " +
+			"e.g. with: sudo pro enable realtime-kernel",
+	}}, nil
+}
+
+func checkKernelConfig(s *State) ([]Finding, error) {
+	kconfig := s.Machine.KernelConfig
+	if kconfig == nil {
+		return []Finding{{
+			Check:    "kernel-config",
+			Severity: Info,
+			Message: "The kernel config was not found in /boot or /proc/config.gz, " +
+				"CONFIG_NO_HZ_FULL, CONFIG_RCU_NOCB_CPU and CONFIG_REGMAP_IRQ were not checked",
+		}}, nil
+	}
+
+	options := []struct {
+		name     string
+		severity Severity
+		impact   string
+	}{
+		{"CONFIG_NO_HZ_FULL", Warning,
+			"the scheduler tick can't be stopped on the isolated CPUs with nohz_full"},
+		{"CONFIG_RCU_NOCB_CPU", Warning,
+			"the RCU callbacks can't be offloaded from the isolated CPUs with rcu_nocbs"},
+		{"CONFIG_REGMAP_IRQ", Info,
+			"the IRQs of regmap based devices can't be remapped"},
+	}
+	var findings []Finding
+	for _, option := range options {
+		if value := kconfig[option.name]; value == "y" || value == "m" {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:    "kernel-config",
+			Severity: option.severity,
+			Message:  fmt.Sprintf("%s is not set, %s", option.name, option.impact),
+		})
+	}
+	return findings, nil
+}
+
+func checkIsolation(s *State) ([]Finding, error) {
+	isolated, err := isolatedCPUs(s.Machine)
+	if err != nil {
+		return nil, err
+	}
+	if len(isolated) > 0 {
+		return checkIsolationParams(s, genCPUList(isolated))
+	}
+
+	finding := Finding{
+		Check:    "isolation",
+		Severity: Warning,
+		Message: "No CPUs are isolated, the real-time tasks share their CPUs " +
+			"with the other tasks, the kernel threads and the IRQs",
+	}
+	rt, err := rtCPUs(s.Machine)
+	if err != nil {
+		return nil, err
+	}
+	if len(rt) > 0 {
+		list := genCPUList(rt)
+		finding.Suggestion = kernelCmdlineSnippet(
+			"isolcpus="+list,
+			"nohz_full="+list,
+			"rcu_nocbs="+list)
+	}
+	return []Finding{finding}, nil
+}
+
+// checkIsolationParams reports the parameters which complete the isolation
+// of the isolated CPUs, when the kernel supports them
+func checkIsolationParams(s *State, isolated string) ([]Finding, error) {
+	if s.Machine.Cmdline == "" {
+		return nil, nil
+	}
+	params := []struct {
+		name   string
+		option string
+	}{
+		{"nohz_full", "CONFIG_NO_HZ_FULL"},
+		{"rcu_nocbs", "CONFIG_RCU_NOCB_CPU"},
+	}
+	var missing, suggested []string
+	for _, param := range params {
+		if _, ok := cmdlineParam(s.Machine.Cmdline, param.name); ok {
+			continue
+		}
+		if value, ok := s.Machine.KernelConfig[param.option]; ok && value != "y" {
+			continue // Reported by the kernel config check
+		}
+		missing = append(missing, param.name)
+		suggested = append(suggested, param.name+"="+isolated)
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	return []Finding{{
+		Check:    "isolation",
+		Severity: Info,
+		Message: fmt.Sprintf("The isolated CPUs %s are not set in %s",
+			isolated, strings.Join(missing, " and ")),
+		Suggestion: kernelCmdlineSnippet(suggested...),
+	}}, nil
+}
+
+func checkSMT(s *State) ([]Finding, error) {
+	if s.SMTControl != "on" {
+		return nil, nil
+	}
+	return []Finding{{
+		Check:    "smt",
+		Severity: Warning,
+		Message: "SMT is enabled, the real-time tasks share the cores of their CPUs " +
+			"with the tasks running on the sibling threads",
+		Suggestion: kernelCmdlineSnippet("nosmt"),
+	}}, nil
+}
+
+func checkIdleStates(s *State) ([]Finding, error) {
+	if s.Machine.CPUIdle == nil {
+		return nil, nil
+	}
+	rt, err := rtCPUs(s.Machine)
+	if err != nil || len(rt) == 0 {
+		return nil, err
+	}
+
+	var states []string
+	enabledOn := make(cpulists.CPUs)
+	for _, state := range s.Machine.CPUIdle.States {
+		if state.Latency <= maxIdleLatency {
+			continue
+		}
+		disabled, err := parseCPUs(state.Disabled)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPUs of idle state %s: %v", state.Name, err)
+		}
+		enabled := false
+		for cpu := range rt {
+			if !disabled[cpu] {
+				enabledOn[cpu] = true
+				enabled = true
+			}
+		}
+		if enabled {
+			states = append(states, fmt.Sprintf("%s (%d us)", state.Name, state.Latency))
+		}
+	}
+	if len(states) == 0 {
+		return nil, nil
+	}
+
+	list := genCPUList(enabledOn)
+	return []Finding{{
+		Check:    "cpu-idle",
+		Severity: Warning,
+		Message: fmt.Sprintf("The idle states %s are enabled on CPUs %s, "+
+			"waking up from them delays the real-time tasks",
+			strings.Join(states, ", "), list),
+		Suggestion: fmt.Sprintf("cpu-idle:\n  rt:\n    cpus: %q\n    max-latency: %d\n",
+			list, maxIdleLatency),
+	}}, nil
+}
+
+func checkTurbo(s *State) ([]Finding, error) {
+	if s.Turbo == nil || !*s.Turbo {
+		return nil, nil
+	}
+	rt, err := rtCPUs(s.Machine)
+	if err != nil || len(rt) == 0 {
+		return nil, err
+	}
+
+	maxFreq := "    # max-freq: base frequency of the CPUs\n"
+	if s.BaseFrequency > 0 {
+		maxFreq = fmt.Sprintf("    max-freq: \"%dMHz\"\n", s.BaseFrequency/1000)
+	}
+	return []Finding{{
+		Check:    "turbo",
+		Severity: Warning,
+		Message: "Turbo is enabled, the frequency of the CPUs varies with " +
+			"the load and the temperature of the package",
+		Suggestion: fmt.Sprintf("cpu-governance:\n  rt:\n    cpus: %q\n"+
+			"    scaling-governor: \"performance\"\n%s", genCPUList(rt), maxFreq),
+	}}, nil
+}
+
+func checkIrqbalance(s *State) ([]Finding, error) {
+	if !s.IrqbalanceRunning {
+		return nil, nil
+	}
+	finding := Finding{
+		Check:    "irqbalance",
+		Severity: Warning,
+		Message: "irqbalance is running and moves the IRQs back onto the real-time CPUs, " +
+			"stop it or ban the CPUs from balancing with --irqbalance-file",
+	}
+	rt, err := rtCPUs(s.Machine)
+	if err != nil {
+		return nil, err
+	}
+	if len(rt) > 0 {
+		finding.Suggestion = fmt.Sprintf("irq-affinity:\n  remove-from-cpus: %q\n",
+			genCPUList(rt))
+	}
+	return []Finding{finding}, nil
+}
+
+func checkTHP(s *State) ([]Finding, error) {
+	if s.THPEnabled != "always" {
+		return nil, nil
+	}
+	return []Finding{{
+		Check:    "thp",
+		Severity: Warning,
+		Message: "Transparent hugepages are always enabled, " +
+			"their allocation and compaction cause latency spikes",
+		Suggestion: "memory-tuning:\n  thp-enabled: \"never\"\n",
+	}}, nil
+}
+
+func checkTimerMigration(s *State) ([]Finding, error) {
+	if s.TimerMigration != "1" {
+		return nil, nil
+	}
+	return []Finding{{
+		Check:    "timer-migration",
+		Severity: Info,
+		Message: "Timer migration is enabled, the timers of the housekeeping CPUs " +
+			"may be moved onto the real-time CPUs",
+		Suggestion: "sysctl:\n  kernel.timer_migration: \"0\"\n",
+	}}, nil
+}
+
+func checkClocksource(s *State) ([]Finding, error) {
+	if s.Clocksource == "" || s.Clocksource == "tsc" {
+		return nil, nil
+	}
+	if slices.Contains(s.AvailableClocksources, "tsc") {
+		return []Finding{{
+			Check:    "clocksource",
+			Severity: Warning,
+			Message: fmt.Sprintf("The clocksource is %s instead of tsc, "+
+				"reading the time is slower", s.Clocksource),
+			Suggestion: kernelCmdlineSnippet("clocksource=tsc", "tsc=reliable"),
+		}}, nil
+	}
+	if slices.Contains([]string{"hpet", "acpi_pm", "jiffies"}, s.Clocksource) {
+		return []Finding{{
+			Check:    "clocksource",
+			Severity: Warning,
+			Message: fmt.Sprintf("The clocksource is %s, reading the time is slow",
+				s.Clocksource),
+		}}, nil
+	}
+	return nil, nil
+}
+
+func checkIsolatedIRQs(s *State) ([]Finding, error) {
+	isolated, err := isolatedCPUs(s.Machine)
+	if err != nil || len(isolated) == 0 {
+		return nil, err
+	}
+
+	var movable, managed []int
+	for _, irq := range s.Machine.IRQs {
+		affinity := irq.EffectiveAffinity
+		if affinity == "" {
+			affinity = irq.Affinity
+		}
+		cpus, err := parseCPUs(affinity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid affinity of IRQ %d: %v", irq.Number, err)
+		}
+		if !intersects(cpus, isolated) {
+			continue
+		}
+		if irq.Managed {
+			managed = append(managed, irq.Number)
+		} else {
+			movable = append(movable, irq.Number)
+		}
+	}
+
+	var findings []Finding
+	list := genCPUList(isolated)
+	if len(movable) > 0 {
+		housekeeping, err := housekeepingCPUs(s.Machine, isolated)
+		if err != nil {
+			return nil, err
+		}
+		suggestion := fmt.Sprintf("irq-affinity:\n  remove-from-cpus: %q\n", list)
+		if len(housekeeping) > 0 {
+			suggestion += fmt.Sprintf("  handle-on-cpus: %q\n", genCPUList(housekeeping))
+		}
+		findings = append(findings, Finding{
+			Check:    "isolated-irqs",
+			Severity: Warning,
+			Message: fmt.Sprintf("IRQs %s are handled on the isolated CPUs %s",
+				cpulists.GenCPUlist(movable), list),
+			Suggestion: suggestion,
+		})
+	}
+	if len(managed) > 0 {
+		finding := Finding{
+			Check:    "isolated-irqs",
+			Severity: Info,
+			Message: fmt.Sprintf("Managed IRQs %s are handled on the isolated CPUs %s, "+
+				"their affinity is set by the kernel unless isolcpus has the managed_irq flag",
+				cpulists.GenCPUlist(managed), list),
+		}
+		// The managed_irq flag replaces the default domain one, so it is
+		// only suggested when the CPUs are not isolated with isolcpus yet
+		if _, ok := cmdlineParam(s.Machine.Cmdline, "isolcpus"); !ok {
+			finding.Suggestion = kernelCmdlineSnippet("isolcpus=managed_irq," + list)
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+func checkIsolatedKthreads(s *State) ([]Finding, error) {
+	isolated, err := isolatedCPUs(s.Machine)
+	if err != nil || len(isolated) == 0 {
+		return nil, err
+	}
+
+	var names []string
+	for _, kthread := range s.Kthreads {
+		cpus, err := parseCPUs(kthread.CPUs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPUs of kernel thread %d: %v", kthread.PID, err)
+		}
+		// Per-CPU kernel threads are bound to their CPU on purpose
+		if len(cpus) > 1 && intersects(cpus, isolated) {
+			names = append(names, kthread.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	named := names
+	if len(names) > maxNamedKthreads {
+		named = append(names[:maxNamedKthreads:maxNamedKthreads],
+			fmt.Sprintf("%d more", len(names)-maxNamedKthreads))
+	}
+	list := genCPUList(isolated)
+	finding := Finding{
+		Check:    "isolated-kthreads",
+		Severity: Warning,
+		Message: fmt.Sprintf("Unbound kernel threads %s can run on the isolated CPUs %s",
+			strings.Join(named, ", "), list),
+	}
+	if !isolcpusDomain(s.Machine.Cmdline) {
+		finding.Suggestion = kernelCmdlineSnippet("isolcpus=" + list)
+	}
+	return []Finding{finding}, nil
+}
+
+// isolcpusDomain returns true if the isolcpus parameter removes the CPUs
+// from the scheduler domains, the default when no flags are set
+func isolcpusDomain(cmdline string) bool {
+	value, ok := cmdlineParam(cmdline, "isolcpus")
+	if !ok {
+		return false
+	}
+	hasFlags := false
+	for _, item := range strings.Split(value, ",") {
+		switch item {
+		case "domain":
+			return true
+		case "nohz", "managed_irq":
+			hasFlags = true
+		}
+	}
+	return !hasFlags
+}
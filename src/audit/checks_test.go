@@ -0,0 +1,296 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/machine"
+	"github.com/canonical/rt-conf/src/model"
+	"go.yaml.in/yaml/v4"
+)
+
+// rtMachine returns a machine with CPUs 2-3 isolated and all the real-time
+// prerequisites met
+func rtMachine() *machine.Machine {
+	return &machine.Machine{
+		Realtime: true,
+		Cmdline:  "isolcpus=domain,managed_irq,2-3 nohz_full=2-3 rcu_nocbs=2-3",
+		KernelConfig: map[string]string{
+			"CONFIG_PREEMPT_RT":   "y",
+			"CONFIG_NO_HZ_FULL":   "y",
+			"CONFIG_RCU_NOCB_CPU": "y",
+			"CONFIG_REGMAP_IRQ":   "y",
+		},
+		CPUs: machine.CPUs{
+			Possible: "0-3",
+			Present:  "0-3",
+			Online:   "0-3",
+			Isolated: "2-3",
+			NohzFull: "2-3",
+		},
+		IRQs: []machine.IRQ{
+			{Number: 24, Actions: "eth0-rx-0", Affinity: "0-1"},
+		},
+		CPUIdle: &machine.CPUIdle{
+			States: []machine.IdleState{
+				{Name: "POLL", Latency: 0},
+				{Name: "C6", Latency: 170, Disabled: "2-3"},
+			},
+		},
+	}
+}
+
+func rtState() *State {
+	turbo := false
+	return &State{
+		Machine:        rtMachine(),
+		SMTControl:     "off",
+		Turbo:          &turbo,
+		THPEnabled:     "madvise",
+		TimerMigration: "0",
+		Clocksource:    "tsc",
+		Kthreads: []Kthread{
+			{PID: 2, Name: "kthreadd", CPUs: "0-1"},
+			{PID: 20, Name: "ksoftirqd/2", CPUs: "2"},
+		},
+	}
+}
+
+func TestAuditClean(t *testing.T) {
+	findings, err := Audit(rtState())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(findings) != 0 {
+		t.Fatalf("expected no findings, got %+v", findings)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		modify   func(s *State)
+		check    string
+		severity Severity
+		message  string
+	}{
+		{
+			name: "not preempt-rt",
+			modify: func(s *State) {
+				s.Machine.Realtime = false
+				s.Machine.KernelConfig["CONFIG_PREEMPT_RT"] = "n"
+			},
+			check:    "preempt-rt",
+			severity: Critical,
+			message:  "not a PREEMPT_RT kernel",
+		},
+		{
+			name:     "no nohz_full support",
+			modify:   func(s *State) { s.Machine.KernelConfig["CONFIG_NO_HZ_FULL"] = "n" },
+			check:    "kernel-config",
+			severity: Warning,
+			message:  "CONFIG_NO_HZ_FULL is not set",
+		},
+		{
+			name:     "no kernel config",
+			modify:   func(s *State) { s.Machine.KernelConfig = nil },
+			check:    "kernel-config",
+			severity: Info,
+			message:  "kernel config was not found",
+		},
+		{
+			name: "no isolated CPUs",
+			modify: func(s *State) {
+				s.Machine.CPUs.Isolated = ""
+				s.Machine.CPUs.NohzFull = ""
+				s.Machine.CPUIdle.States[1].Disabled = "1-3"
+			},
+			check:    "isolation",
+			severity: Warning,
+			message:  "No CPUs are isolated",
+		},
+		{
+			name:     "missing rcu_nocbs",
+			modify:   func(s *State) { s.Machine.Cmdline = "isolcpus=2-3 nohz_full=2-3" },
+			check:    "isolation",
+			severity: Info,
+			message:  "isolated CPUs 2-3 are not set in rcu_nocbs",
+		},
+		{
+			name:     "smt",
+			modify:   func(s *State) { s.SMTControl = "on" },
+			check:    "smt",
+			severity: Warning,
+			message:  "SMT is enabled",
+		},
+		{
+			name:     "deep idle state",
+			modify:   func(s *State) { s.Machine.CPUIdle.States[1].Disabled = "2" },
+			check:    "cpu-idle",
+			severity: Warning,
+			message:  "idle states C6 (170 us) are enabled on CPUs 3",
+		},
+		{
+			name: "turbo",
+			modify: func(s *State) {
+				turbo := true
+				s.Turbo = &turbo
+				s.BaseFrequency = 2100000
+			},
+			check:    "turbo",
+			severity: Warning,
+			message:  "Turbo is enabled",
+		},
+		{
+			name:     "irqbalance",
+			modify:   func(s *State) { s.IrqbalanceRunning = true },
+			check:    "irqbalance",
+			severity: Warning,
+			message:  "irqbalance is running",
+		},
+		{
+			name:     "thp",
+			modify:   func(s *State) { s.THPEnabled = "always" },
+			check:    "thp",
+			severity: Warning,
+			message:  "always enabled",
+		},
+		{
+			name:     "timer migration",
+			modify:   func(s *State) { s.TimerMigration = "1" },
+			check:    "timer-migration",
+			severity: Info,
+			message:  "Timer migration is enabled",
+		},
+		{
+			name: "hpet clocksource",
+			modify: func(s *State) {
+				s.Clocksource = "hpet"
+				s.AvailableClocksources = []string{"tsc", "hpet", "acpi_pm"}
+			},
+			check:    "clocksource",
+			severity: Warning,
+			message:  "clocksource is hpet instead of tsc",
+		},
+		{
+			name: "IRQs on isolated CPUs",
+			modify: func(s *State) {
+				s.Machine.IRQs = append(s.Machine.IRQs,
+					machine.IRQ{Number: 25, Affinity: "0-3", EffectiveAffinity: "3"},
+					machine.IRQ{Number: 26, Affinity: "0-3", EffectiveAffinity: "1"})
+			},
+			check:    "isolated-irqs",
+			severity: Warning,
+			message:  "IRQs 25 are handled on the isolated CPUs 2-3",
+		},
+		{
+			name: "managed IRQs on isolated CPUs",
+			modify: func(s *State) {
+				s.Machine.IRQs = append(s.Machine.IRQs,
+					machine.IRQ{Number: 40, Affinity: "2", Managed: true})
+			},
+			check:    "isolated-irqs",
+			severity: Info,
+			message:  "Managed IRQs 40 are handled on the isolated CPUs 2-3",
+		},
+		{
+			name: "unbound kthreads on isolated CPUs",
+			modify: func(s *State) {
+				s.Kthreads = append(s.Kthreads, Kthread{PID: 30, Name: "kworker/u8:0", CPUs: "0-3"})
+			},
+			check:    "isolated-kthreads",
+			severity: Warning,
+			message:  "kworker/u8:0 can run on the isolated CPUs 2-3",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := rtState()
+			tc.modify(state)
+
+			findings, err := Audit(state)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(findings) != 1 {
+				t.Fatalf("expected 1 finding, got %+v", findings)
+			}
+			f := findings[0]
+			if f.Check != tc.check || f.Severity != tc.severity ||
+				!strings.Contains(f.Message, tc.message) {
+				t.Errorf("expected %s %s finding containing %q, got %+v",
+					tc.severity, tc.check, tc.message, f)
+			}
+		})
+	}
+}
+
+func TestFindingsOrder(t *testing.T) {
+	state := rtState()
+	state.Machine.Realtime = false
+	state.Machine.KernelConfig["CONFIG_PREEMPT_RT"] = "n"
+	state.TimerMigration = "1"
+	state.SMTControl = "on"
+
+	findings, err := Audit(state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var checks []string
+	for _, f := range findings {
+		checks = append(checks, f.Check)
+	}
+	if strings.Join(checks, ",") != "preempt-rt,smt,timer-migration" {
+		t.Errorf("expected findings sorted by severity, got %v", checks)
+	}
+}
+
+// TestSuggestionsValid checks that the suggested configs are valid for the
+// audited machine
+func TestSuggestionsValid(t *testing.T) {
+	target := rtMachine()
+	target.CPUs.Isolated = ""
+	target.CPUs.NohzFull = ""
+	restore, err := target.Use()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(restore)
+
+	state := rtState()
+	state.Machine = target
+	state.Machine.Cmdline = ""
+	turbo := true
+	state.Turbo = &turbo
+	state.BaseFrequency = 2100000
+	state.SMTControl = "on"
+	state.IrqbalanceRunning = true
+	state.THPEnabled = "always"
+	state.TimerMigration = "1"
+	state.Clocksource = "hpet"
+	state.AvailableClocksources = []string{"tsc", "hpet"}
+
+	findings, err := Audit(state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	suggestions := 0
+	for _, f := range findings {
+		if f.Suggestion == "" {
+			continue
+		}
+		suggestions++
+		var cfg model.Config
+		if err := yaml.Unmarshal([]byte(f.Suggestion), &cfg); err != nil {
+			t.Errorf("%s: invalid suggestion:\n%s\n%v", f.Check, f.Suggestion, err)
+			continue
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s: invalid suggestion:\n%s\n%v", f.Check, f.Suggestion, err)
+		}
+	}
+	if suggestions < 7 {
+		t.Errorf("expected a suggestion per finding, got %+v", findings)
+	}
+}
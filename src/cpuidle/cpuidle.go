@@ -0,0 +1,174 @@
+package cpuidle
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Documentation for the cpuidle sysfs interface:
+// https://docs.kernel.org/admin-guide/pm/cpuidle.html#idle-states-representation-in-sysfs
+
+type ReaderWriter struct {
+	CpuIdlePath string
+}
+
+var cpuIdleReaderWriter = ReaderWriter{
+	CpuIdlePath: "/sys/devices/system/cpu/cpu%d/cpuidle",
+}
+
+// IdleState represents an idle state of a CPU
+type IdleState struct {
+	// Directory name of the state, e.g. state2
+	Dir  string
+	Name string
+	// Exit latency in microseconds
+	Latency int
+}
+
+// ReadStates returns the idle states of a CPU sorted by state index
+func (rw ReaderWriter) ReadStates(cpu int) ([]IdleState, error) {
+	cpuIdleDir := fmt.Sprintf(rw.CpuIdlePath, cpu)
+	entries, err := os.ReadDir(cpuIdleDir)
+	if err != nil {
+		return nil, fmt.Errorf("cpuidle is not available for CPU %d: %v",
+			cpu, err)
+	}
+
+	var states []IdleState
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "state") {
+			continue
+		}
+		stateDir := filepath.Join(cpuIdleDir, entry.Name())
+
+		name, err := utils.ReadTrimmed(filepath.Join(stateDir, "name"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read idle state name: %v", err)
+		}
+		latencyStr, err := utils.ReadTrimmed(filepath.Join(stateDir, "latency"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read idle state latency: %v", err)
+		}
+		latency, err := strconv.Atoi(latencyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latency for idle state %s: %v",
+				stateDir, err)
+		}
+
+		states = append(states, IdleState{
+			Dir:     entry.Name(),
+			Name:    name,
+			Latency: latency,
+		})
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(states[i].Dir, "state"))
+		b, _ := strconv.Atoi(strings.TrimPrefix(states[j].Dir, "state"))
+		return a < b
+	})
+
+	return states, nil
+}
+
+// DisableState disables the given idle state of a CPU
+func (rw ReaderWriter) DisableState(cpu int, state IdleState) error {
+	disableFile := filepath.Join(
+		fmt.Sprintf(rw.CpuIdlePath, cpu), state.Dir, "disable")
+	return utils.WriteOnly(disableFile, "1")
+}
+
+func ApplyCpuIdleConfig(config *model.InternalConfig) error {
+	utils.PrintTitle("CPU Idle States")
+	if len(config.Data.CpuIdle) == 0 {
+		log.Println("No CPU idle rules found in config")
+		return nil
+	}
+	return cpuIdleReaderWriter.applyCpuIdleConfig(config.Data.CpuIdle)
+}
+
+// Apply changes based on YAML config
+func (rw ReaderWriter) applyCpuIdleConfig(rules model.CpuIdle) error {
+	for _, label := range rules.Labels() {
+		rule := rules[label]
+		log.Printf("Rule: %s\n", label)
+
+		cpus, offline, err := cpulists.ParseOnline(rule.CPUs)
+		if err != nil {
+			return err
+		}
+		cpulists.LogOffline(offline)
+
+		sortedCPUs := make([]int, 0, len(cpus))
+		for cpu := range cpus {
+			sortedCPUs = append(sortedCPUs, cpu)
+		}
+		sort.Ints(sortedCPUs)
+
+		var msgs []string
+		for _, cpu := range sortedCPUs {
+			disabled, err := rw.applyRule(cpu, rule)
+			if err != nil {
+				return fmt.Errorf("failed to apply CPU idle rule #%s for CPU %d: %v",
+					label, cpu, err)
+			}
+			msgs = append(msgs, changeMsg(cpu, disabled))
+		}
+		utils.LogTreeStyle(msgs)
+	}
+	return nil
+}
+
+// applyRule disables the idle states of a CPU which match the rule
+// and returns their names
+func (rw ReaderWriter) applyRule(cpu int, rule model.CpuIdleRule) ([]string, error) {
+	states, err := rw.ReadStates(cpu)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range rule.DisableStates {
+		if !slices.ContainsFunc(states, func(s IdleState) bool {
+			return s.Name == name
+		}) {
+			return nil, fmt.Errorf("idle state %q not found", name)
+		}
+	}
+
+	var disabled []string
+	for _, state := range states {
+		if !matchesRule(state, rule) {
+			continue
+		}
+		if err := rw.DisableState(cpu, state); err != nil {
+			return nil, err
+		}
+		disabled = append(disabled, state.Name)
+	}
+	return disabled, nil
+}
+
+func matchesRule(state IdleState, rule model.CpuIdleRule) bool {
+	if slices.Contains(rule.DisableStates, state.Name) {
+		return true
+	}
+	return rule.MaxLatency != nil && state.Latency > *rule.MaxLatency
+}
+
+func changeMsg(cpu int, disabled []string) string {
+	if len(disabled) == 0 {
+		return fmt.Sprintf("CPU %d: no idle states to disable", cpu)
+	}
+	return fmt.Sprintf("CPU %d: disabled idle states %s",
+		cpu, strings.Join(disabled, ", "))
+}
@@ -0,0 +1,184 @@
+package cpuidle
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+type testState struct {
+	name    string
+	latency int
+}
+
+// setupCpuIdleDir creates a fake cpuidle directory for CPU 0 with the given
+// idle states and returns the path format for the ReaderWriter.
+func setupCpuIdleDir(t *testing.T, states []testState) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	for i, s := range states {
+		stateDir := filepath.Join(tmpDir, "0", "state"+strconv.Itoa(i))
+		if err := os.MkdirAll(stateDir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		files := map[string]string{
+			"name":    s.name + "\n",
+			"latency": strconv.Itoa(s.latency) + "\n",
+			"disable": "0\n",
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(stateDir, name),
+				[]byte(content), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+		}
+	}
+	return filepath.Join(tmpDir, "%d")
+}
+
+func readDisabled(t *testing.T, path string, states []testState) []string {
+	t.Helper()
+
+	var disabled []string
+	for i, s := range states {
+		content, err := os.ReadFile(filepath.Join(
+			strings.Replace(path, "%d", "0", 1),
+			"state"+strconv.Itoa(i), "disable"))
+		if err != nil {
+			t.Fatalf("failed to read file: %v", err)
+		}
+		if strings.TrimSpace(string(content)) == "1" {
+			disabled = append(disabled, s.name)
+		}
+	}
+	return disabled
+}
+
+func TestApplyCpuIdleConfig(t *testing.T) {
+	states := []testState{
+		{"POLL", 0},
+		{"C1", 2},
+		{"C1E", 10},
+		{"C6", 133},
+	}
+	maxLatency := 5
+
+	testCases := []struct {
+		name     string
+		rule     model.CpuIdleRule
+		expected []string
+	}{
+		{
+			name: "disable by name",
+			rule: model.CpuIdleRule{
+				CPUs:          "0",
+				DisableStates: []string{"C6"},
+			},
+			expected: []string{"C6"},
+		},
+		{
+			name: "disable by max latency",
+			rule: model.CpuIdleRule{
+				CPUs:       "0",
+				MaxLatency: &maxLatency,
+			},
+			expected: []string{"C1E", "C6"},
+		},
+		{
+			name: "disable by name and max latency",
+			rule: model.CpuIdleRule{
+				CPUs:          "0",
+				DisableStates: []string{"C1"},
+				MaxLatency:    &maxLatency,
+			},
+			expected: []string{"C1", "C1E", "C6"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := setupCpuIdleDir(t, states)
+			rw := ReaderWriter{CpuIdlePath: path}
+
+			err := rw.applyCpuIdleConfig(model.CpuIdle{"foo": tc.rule})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := readDisabled(t, path, states)
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Fatalf("expected disabled states %v, got %v",
+					tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestApplyCpuIdleConfigUnhappy(t *testing.T) {
+	states := []testState{
+		{"POLL", 0},
+		{"C1", 2},
+	}
+
+	testCases := []struct {
+		name string
+		path string
+		rule model.CpuIdleRule
+		err  string
+	}{
+		{
+			name: "unknown idle state",
+			rule: model.CpuIdleRule{
+				CPUs:          "0",
+				DisableStates: []string{"C10"},
+			},
+			err: `idle state "C10" not found`,
+		},
+		{
+			name: "cpuidle not available",
+			path: "/does/not/exist/%d",
+			rule: model.CpuIdleRule{
+				CPUs:          "0",
+				DisableStates: []string{"C1"},
+			},
+			err: "cpuidle is not available for CPU 0",
+		},
+		{
+			name: "invalid cpu list",
+			rule: model.CpuIdleRule{
+				CPUs:          "1-0",
+				DisableStates: []string{"C1"},
+			},
+			err: "start of range greater than end",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := setupCpuIdleDir(t, states)
+			if tc.path != "" {
+				path = tc.path
+			}
+			rw := ReaderWriter{CpuIdlePath: path}
+
+			err := rw.applyCpuIdleConfig(model.CpuIdle{"foo": tc.rule})
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestEmptyCpuIdleRules(t *testing.T) {
+	if err := ApplyCpuIdleConfig(&model.InternalConfig{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
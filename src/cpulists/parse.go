@@ -36,6 +36,18 @@ func ParseForCPUs(cpuLists string, totalCPUs int) (CPUs, error) {
 	for _, item := range items {
 		item = strings.TrimSpace(item)
 
+		// Handle topology selectors, e.g. "@node:0"
+		if strings.HasPrefix(item, selectorPrefix) {
+			selected, err := resolveSelector(item, totalCPUs)
+			if err != nil {
+				return nil, err
+			}
+			for cpu := range selected {
+				cpus[cpu] = true
+			}
+			continue
+		}
+
 		// Handle "all"
 		if item == "all" {
 			for i := range totalCPUs {
@@ -85,7 +97,8 @@ func ParseWithFlagsForCPUs(cpuLists string, validFlags []string, totalCPUs int)
 		err == nil ||
 		strings.Contains(parts[0], "-") ||
 		strings.Contains(parts[0], ":") ||
-		strings.Contains(parts[0], "/") {
+		strings.Contains(parts[0], "/") ||
+		strings.HasPrefix(parts[0], selectorPrefix) {
 		hasFlag = false
 	}
 
@@ -140,7 +153,7 @@ func handleCPUGroup(item string, cpus CPUs, t int) error {
 		return fmt.Errorf("invalid end of range: %s", startEnd[1])
 	}
 	if end >= t {
-		return fmt.Errorf("end of range greater than total CPUs: %s", item)
+		return fmt.Errorf("invalid range %s: CPU %d does not exist", item, end)
 	}
 
 	groupParts := strings.Split(groupPart, "/")
@@ -186,7 +199,7 @@ func handleCPURange(item string, cpus CPUs, t int) error {
 		return fmt.Errorf("invalid end of range: %s", parts[1])
 	}
 	if end >= t {
-		return fmt.Errorf("end of range greater than total CPUs: %s", item)
+		return fmt.Errorf("invalid range %s: CPU %d does not exist", item, end)
 	}
 	if start > end {
 		return fmt.Errorf("start of range greater than end: %s", item)
@@ -205,7 +218,7 @@ func handleSingleCPU(item string, cpus CPUs, t int) error {
 		return fmt.Errorf("invalid CPU: %s", item)
 	}
 	if cpu >= t {
-		return fmt.Errorf("CPU greater than total CPUs: %s", item)
+		return fmt.Errorf("CPU %d does not exist", cpu)
 	}
 	cpus[cpu] = true
 	return nil
@@ -255,3 +268,34 @@ func deduplicateCPUs(cpus []int) (cpulist []int) {
 	sort.Ints(cpulist)
 	return cpulist
 }
+
+// GenHexMask converts CPUs into the kernel's hexadecimal cpumask format:
+// comma separated groups of 32 bits, the most significant group first.
+// e.g. CPUs 0-3 -> "f", CPUs 0,32 -> "1,00000001"
+func GenHexMask(cpus CPUs) string {
+	if len(cpus) == 0 {
+		return "0"
+	}
+
+	highest := 0
+	for cpu := range cpus {
+		if cpu > highest {
+			highest = cpu
+		}
+	}
+
+	groups := make([]uint32, highest/32+1)
+	for cpu := range cpus {
+		groups[cpu/32] |= 1 << (cpu % 32)
+	}
+
+	parts := make([]string, 0, len(groups))
+	for i := len(groups) - 1; i >= 0; i-- {
+		if i == len(groups)-1 {
+			parts = append(parts, fmt.Sprintf("%x", groups[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%08x", groups[i]))
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -141,7 +141,7 @@ func TestParseCPUListsUnhappy(t *testing.T) {
 		{
 			"4",
 			4,
-			"CPU greater than total CPUs: 4",
+			"CPU 4 does not exist",
 		},
 		{
 			"a",
@@ -166,7 +166,7 @@ func TestParseCPUListsUnhappy(t *testing.T) {
 		{
 			"6-8",
 			8,
-			"end of range greater than total CPUs: 6-8",
+			"invalid range 6-8: CPU 8 does not exist",
 		},
 		{
 			"5-2",
@@ -388,3 +388,51 @@ func TestGenCPUlist(t *testing.T) {
 		})
 	}
 }
+
+func TestGenHexMask(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cpus   CPUs
+		result string
+	}{
+		{
+			name:   "TestEmptyCPUs",
+			cpus:   CPUs{},
+			result: "0",
+		},
+		{
+			name:   "TestSingleCPU",
+			cpus:   CPUs{0: true},
+			result: "1",
+		},
+		{
+			name:   "TestCPURange",
+			cpus:   CPUs{0: true, 1: true, 2: true, 3: true},
+			result: "f",
+		},
+		{
+			name:   "TestNonContiguousCPUs",
+			cpus:   CPUs{1: true, 4: true, 31: true},
+			result: "80000012",
+		},
+		{
+			name:   "TestMultipleGroups",
+			cpus:   CPUs{0: true, 32: true},
+			result: "1,00000001",
+		},
+		{
+			name:   "TestEmptyLowerGroup",
+			cpus:   CPUs{64: true},
+			result: "1,00000000,00000000",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := GenHexMask(tc.cpus)
+			if result != tc.result {
+				t.Errorf("Expected %s, got %s", tc.result, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package cpulists
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Topology selectors extend the CPU Lists format with items starting with
+// "@", which select CPUs by their topology instead of by their numbers:
+//
+//	@siblings:<cpus>   the CPUs and their SMT siblings, e.g. @siblings:2-3
+//	@socket:<ids>      the CPUs of the physical packages, e.g. @socket:1
+//	@node:<ids>        the CPUs of the NUMA nodes, e.g. @node:0
+//	@pcores            the performance cores of hybrid CPUs
+//	@ecores            the efficiency cores of hybrid CPUs
+//	@first-cores:<n>   all threads of the first n physical cores
+//	@last-cores:<n>    all threads of the last n physical cores
+//
+// The arguments are CPU Lists without commas, so selectors can be mixed with
+// plain items, e.g. "0,@siblings:2-3". The kernel doesn't understand them,
+// see Resolve.
+//
+// Documentation for the topology files:
+// https://docs.kernel.org/admin-guide/cputopology.html
+
+const selectorPrefix = "@"
+
+// Largest socket or NUMA node ID accepted in selectors
+const maxTopologyID = 4096
+
+// readCPUList reads a file holding a CPU list, e.g. 0-3,8-11
+func readCPUList(path string, totalCPUs int) (CPUs, error) {
+	list, err := utils.ReadTrimmed(path)
+	if err != nil {
+		return nil, err
+	}
+	if list == "" {
+		return make(CPUs), nil
+	}
+	return ParseForCPUs(list, totalCPUs)
+}
+
+func readInt(path string) (int, error) {
+	content, err := utils.ReadTrimmed(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(content)
+}
+
+func topologyFile(cpu int, name string) string {
+	return filepath.Join(SysCPUPath(), fmt.Sprintf("cpu%d", cpu), "topology", name)
+}
+
+// onlineTopologyCPUs returns the CPUs which report their topology, sorted.
+// The topology of offline CPUs isn't reported.
+func onlineTopologyCPUs(totalCPUs int) []int {
+	var cpus []int
+	for cpu := range totalCPUs {
+		if _, err := os.Stat(topologyFile(cpu, "thread_siblings_list")); err == nil {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus
+}
+
+// resolveSelector returns the CPUs selected by a topology selector
+func resolveSelector(item string, totalCPUs int) (CPUs, error) {
+	name, arg, hasArg := strings.Cut(strings.TrimPrefix(item, selectorPrefix), ":")
+
+	if (name == "pcores" || name == "ecores") && hasArg {
+		return nil, fmt.Errorf("invalid topology selector %s: no argument expected", item)
+	}
+
+	var cpus CPUs
+	var err error
+	switch name {
+	case "siblings":
+		cpus, err = selectSiblings(arg, totalCPUs)
+	case "socket":
+		cpus, err = selectSockets(arg, totalCPUs)
+	case "node":
+		cpus, err = selectNodes(arg, totalCPUs)
+	case "pcores":
+		cpus, err = selectHybridCores("cpu_core", true, totalCPUs)
+	case "ecores":
+		cpus, err = selectHybridCores("cpu_atom", false, totalCPUs)
+	case "first-cores", "last-cores":
+		cpus, err = selectCores(arg, name == "last-cores", totalCPUs)
+	default:
+		return nil, fmt.Errorf("unknown topology selector: %s", item)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid topology selector %s: %v", item, err)
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("topology selector %s matches no CPUs", item)
+	}
+	return cpus, nil
+}
+
+// selectSiblings returns the CPUs of the list along with their SMT siblings
+func selectSiblings(arg string, totalCPUs int) (CPUs, error) {
+	list, err := ParseForCPUs(arg, totalCPUs)
+	if err != nil {
+		return nil, err
+	}
+	cpus := make(CPUs)
+	for cpu := range list {
+		siblings, err := readCPUList(topologyFile(cpu, "thread_siblings_list"), totalCPUs)
+		if err != nil {
+			return nil, fmt.Errorf("no topology for CPU %d: %v", cpu, err)
+		}
+		for sibling := range siblings {
+			cpus[sibling] = true
+		}
+	}
+	return cpus, nil
+}
+
+// selectSockets returns the CPUs of the physical packages of the list
+func selectSockets(arg string, totalCPUs int) (CPUs, error) {
+	ids, err := ParseForCPUs(arg, maxTopologyID)
+	if err != nil {
+		return nil, err
+	}
+	cpus := make(CPUs)
+	for _, cpu := range onlineTopologyCPUs(totalCPUs) {
+		id, err := readInt(topologyFile(cpu, "physical_package_id"))
+		if err != nil {
+			return nil, fmt.Errorf("no package ID for CPU %d: %v", cpu, err)
+		}
+		if ids[id] {
+			cpus[cpu] = true
+		}
+	}
+	return cpus, nil
+}
+
+// selectNodes returns the CPUs of the NUMA nodes of the list
+func selectNodes(arg string, totalCPUs int) (CPUs, error) {
+	ids, err := ParseForCPUs(arg, maxTopologyID)
+	if err != nil {
+		return nil, err
+	}
+	cpus := make(CPUs)
+	for id := range ids {
+		path := filepath.Join(sysNodePath(), fmt.Sprintf("node%d", id), "cpulist")
+		nodeCPUs, err := readCPUList(path, totalCPUs)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("NUMA node %d does not exist", id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for cpu := range nodeCPUs {
+			cpus[cpu] = true
+		}
+	}
+	return cpus, nil
+}
+
+// selectHybridCores returns the performance or efficiency cores, from the
+// hybrid PMU devices on x86, e.g. /sys/devices/cpu_core/cpus, or else from
+// the CPU capacities on arm64, the performance cores having the highest one
+func selectHybridCores(pmu string, performance bool, totalCPUs int) (CPUs, error) {
+	cpus, err := readCPUList(filepath.Join(sysDevicesPath(), pmu, "cpus"), totalCPUs)
+	if err == nil {
+		return cpus, nil
+	}
+
+	capacities := make(map[int]int)
+	highest := 0
+	for cpu := range totalCPUs {
+		path := filepath.Join(SysCPUPath(), fmt.Sprintf("cpu%d", cpu), "cpu_capacity")
+		capacity, err := readInt(path)
+		if err != nil {
+			continue
+		}
+		capacities[cpu] = capacity
+		highest = max(highest, capacity)
+	}
+	if len(capacities) == 0 {
+		return nil, fmt.Errorf("no hybrid CPU topology found")
+	}
+
+	cpus = make(CPUs)
+	for cpu, capacity := range capacities {
+		if (capacity == highest) == performance {
+			cpus[cpu] = true
+		}
+	}
+	return cpus, nil
+}
+
+// selectCores returns all the threads of the first or last n physical cores,
+// ordered by their lowest CPU number
+func selectCores(arg string, last bool, totalCPUs int) (CPUs, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid number of cores: %q", arg)
+	}
+
+	// Physical cores, as the sets of their threads
+	var cores []CPUs
+	seen := make(CPUs)
+	for _, cpu := range onlineTopologyCPUs(totalCPUs) {
+		if seen[cpu] {
+			continue
+		}
+		siblings, err := readCPUList(topologyFile(cpu, "thread_siblings_list"), totalCPUs)
+		if err != nil {
+			return nil, err
+		}
+		siblings[cpu] = true
+		for sibling := range siblings {
+			seen[sibling] = true
+		}
+		cores = append(cores, siblings)
+	}
+	if n > len(cores) {
+		return nil, fmt.Errorf("%d cores requested, %d available", n, len(cores))
+	}
+
+	selected := cores[:n]
+	if last {
+		selected = cores[len(cores)-n:]
+	}
+	cpus := make(CPUs)
+	for _, core := range selected {
+		for cpu := range core {
+			cpus[cpu] = true
+		}
+	}
+	return cpus, nil
+}
+
+// HasSelectors returns true if a CPU Lists string uses topology selectors
+func HasSelectors(cpuLists string) bool {
+	for _, item := range strings.Split(cpuLists, ",") {
+		if strings.HasPrefix(strings.TrimSpace(item), selectorPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve replaces the topology selectors of a CPU Lists string with the
+// numeric CPU lists they select, as expected by the kernel. Other items,
+// e.g. isolcpus flags, are kept as is.
+func Resolve(cpuLists string) (string, error) {
+	if !HasSelectors(cpuLists) {
+		return cpuLists, nil
+	}
+	total, err := totalCPUs()
+	if err != nil {
+		return "", fmt.Errorf("failed to get total available CPUs: %v", err)
+	}
+	return ResolveForCPUs(cpuLists, total)
+}
+
+// ResolveForCPUs replaces the topology selectors of a CPU Lists string with
+// the numeric CPU lists they select
+func ResolveForCPUs(cpuLists string, totalCPUs int) (string, error) {
+	items := strings.Split(cpuLists, ",")
+	for i, item := range items {
+		item = strings.TrimSpace(item)
+		if !strings.HasPrefix(item, selectorPrefix) {
+			continue
+		}
+		cpus, err := resolveSelector(item, totalCPUs)
+		if err != nil {
+			return "", err
+		}
+		list := make([]int, 0, len(cpus))
+		for cpu := range cpus {
+			list = append(list, cpu)
+		}
+		sort.Ints(list)
+		items[i] = GenCPUlist(list)
+	}
+	return strings.Join(items, ","), nil
+}
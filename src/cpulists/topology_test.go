@@ -0,0 +1,153 @@
+package cpulists
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSysfsFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+// setupTopology creates a fake sysfs with 8 CPUs on 2 sockets and 2 NUMA
+// nodes, each core having 2 threads: CPU n and CPU n+4
+func setupTopology(t *testing.T) {
+	t.Helper()
+
+	t.Cleanup(SetSysfsRoot(t.TempDir()))
+
+	for cpu := range 8 {
+		core := cpu % 4
+		writeSysfsFile(t, topologyFile(cpu, "thread_siblings_list"),
+			fmt.Sprintf("%d,%d", core, core+4))
+		writeSysfsFile(t, topologyFile(cpu, "physical_package_id"),
+			fmt.Sprint(core/2))
+	}
+	writeSysfsFile(t, filepath.Join(sysNodePath(), "node0", "cpulist"), "0-1,4-5")
+	writeSysfsFile(t, filepath.Join(sysNodePath(), "node1", "cpulist"), "2-3,6-7")
+	writeSysfsFile(t, filepath.Join(sysDevicesPath(), "cpu_core", "cpus"), "0-1,4-5")
+	writeSysfsFile(t, filepath.Join(sysDevicesPath(), "cpu_atom", "cpus"), "2-3,6-7")
+}
+
+func TestTopologySelectors(t *testing.T) {
+	setupTopology(t)
+
+	testCases := []struct {
+		list     string
+		expected string
+	}{
+		{"@siblings:2-3", "2-3,6-7"},
+		{"@siblings:0", "0,4"},
+		{"@socket:1", "2-3,6-7"},
+		{"@node:0", "0-1,4-5"},
+		{"@node:0-1", "0-7"},
+		{"@pcores", "0-1,4-5"},
+		{"@ecores", "2-3,6-7"},
+		{"@first-cores:1", "0,4"},
+		{"@last-cores:2", "2-3,6-7"},
+		{"0,@siblings:3", "0,3,7"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.list, func(t *testing.T) {
+			cpus, err := ParseForCPUs(tc.list, 8)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			list := make([]int, 0, len(cpus))
+			for cpu := range cpus {
+				list = append(list, cpu)
+			}
+			if got := GenCPUlist(list); got != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestTopologySelectorsUnhappy(t *testing.T) {
+	setupTopology(t)
+
+	testCases := []struct {
+		list    string
+		wantErr string
+	}{
+		{"@cores", "unknown topology selector: @cores"},
+		{"@node:2", "NUMA node 2 does not exist"},
+		{"@socket:3", "matches no CPUs"},
+		{"@last-cores:5", "5 cores requested, 4 available"},
+		{"@first-cores:x", "invalid number of cores"},
+		{"@pcores:1", "no argument expected"},
+		{"@siblings:9", "CPU 9 does not exist"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.list, func(t *testing.T) {
+			_, err := ParseForCPUs(tc.list, 8)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHybridCoresByCapacity(t *testing.T) {
+	setupTopology(t)
+	for _, pmu := range []string{"cpu_core", "cpu_atom"} {
+		if err := os.RemoveAll(filepath.Join(sysDevicesPath(), pmu)); err != nil {
+			t.Fatalf("failed to remove dir: %v", err)
+		}
+	}
+	for cpu := range 8 {
+		capacity := "1024"
+		if cpu >= 6 {
+			capacity = "446"
+		}
+		writeSysfsFile(t, filepath.Join(SysCPUPath(), fmt.Sprintf("cpu%d", cpu),
+			"cpu_capacity"), capacity)
+	}
+
+	got, err := ResolveForCPUs("@ecores", 8)
+	if err != nil || got != "6-7" {
+		t.Fatalf("expected (\"6-7\", nil), got (%q, %v)", got, err)
+	}
+	got, err = ResolveForCPUs("@pcores", 8)
+	if err != nil || got != "0-5" {
+		t.Fatalf("expected (\"0-5\", nil), got (%q, %v)", got, err)
+	}
+}
+
+func TestResolveForCPUs(t *testing.T) {
+	setupTopology(t)
+
+	testCases := []struct {
+		list     string
+		expected string
+	}{
+		{"2-3", "2-3"},
+		{"@node:1", "2-3,6-7"},
+		{"managed_irq,domain,@socket:0", "managed_irq,domain,0-1,4-5"},
+	}
+	for _, tc := range testCases {
+		got, err := ResolveForCPUs(tc.list, 8)
+		if err != nil || got != tc.expected {
+			t.Errorf("%q: expected (%q, nil), got (%q, %v)", tc.list, tc.expected, got, err)
+		}
+	}
+
+	// Selectors aren't flags
+	cpus, flag, err := ParseWithFlagsForCPUs("@pcores,2", []string{"domain"}, 8)
+	if err != nil || flag != "" || len(cpus) != 5 {
+		t.Errorf("unexpected result: %v, %q, %v", cpus, flag, err)
+	}
+}
@@ -1,43 +1,194 @@
 package cpulists
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-var execCommand = func(name string, arg ...string) ([]byte, error) {
-	return exec.Command(name, arg...).Output()
+// Documentation for the CPU sets reported by the kernel:
+// https://docs.kernel.org/admin-guide/cputopology.html
+// https://docs.kernel.org/ABI/stable/sysfs-devices-system-cpu
+
+// Largest number of CPUs the kernel can be built for, see NR_CPUS
+const maxCPUs = 8192
+
+var sysfsRoot = "/sys"
+
+// SetSysfsRoot points the CPU enumeration and topology readers to another
+// sysfs root, e.g. a fake one in tests. It returns a function restoring the
+// previous root.
+func SetSysfsRoot(root string) (restore func()) {
+	prev := sysfsRoot
+	sysfsRoot = root
+	return func() { sysfsRoot = prev }
 }
 
-var totalCPUs = func() (int, error) {
-	output, err := execCommand("lscpu", "--json")
+// SysCPUPath returns the sysfs directory of the CPUs under the root set by
+// SetSysfsRoot, e.g. /sys/devices/system/cpu
+func SysCPUPath() string {
+	return filepath.Join(sysfsRoot, "devices", "system", "cpu")
+}
+
+func sysNodePath() string {
+	return filepath.Join(sysfsRoot, "devices", "system", "node")
+}
+
+func sysDevicesPath() string {
+	return filepath.Join(sysfsRoot, "devices")
+}
+
+// CPUSets are the sets of CPUs reported by the kernel
+type CPUSets struct {
+	// CPUs which can ever be available, including hotpluggable ones
+	Possible CPUs
+	// CPUs which are physically present in the system
+	Present CPUs
+	// CPUs which are online, i.e. being scheduled
+	Online CPUs
+	// CPUs which are present but offline
+	Offline CPUs
+	// CPUs isolated via isolcpus
+	Isolated CPUs
+	// CPUs in full dynamic ticks mode via nohz_full
+	NohzFull CPUs
+}
+
+// readKernelCPUList reads a sysfs file holding a CPU list, e.g. 0-3,8-11.
+// Empty lists are reported as an empty line, or as "(null)" by nohz_full.
+func readKernelCPUList(name string) (CPUs, error) {
+	path := filepath.Join(SysCPUPath(), name)
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	list := strings.TrimSpace(string(content))
+	if list == "" || list == "(null)" {
+		return make(CPUs), nil
+	}
+	cpus, err := ParseForCPUs(list, maxCPUs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", path, err)
 	}
+	return cpus, nil
+}
 
-	var result struct {
-		CPUs []struct {
-			Field    string `json:"field"`
-			Data     string `json:"data"`
-			Children []any  `json:"children"`
-		} `json:"lscpu"`
+// ReadCPUSets reads the sets of CPUs reported by the kernel. The isolated
+// and nohz_full sets are empty on kernels which don't report them.
+func ReadCPUSets() (CPUSets, error) {
+	var sets CPUSets
+	files := []struct {
+		name     string
+		set      *CPUs
+		optional bool
+	}{
+		{"possible", &sets.Possible, false},
+		{"present", &sets.Present, false},
+		{"online", &sets.Online, false},
+		{"offline", &sets.Offline, true},
+		{"isolated", &sets.Isolated, true},
+		{"nohz_full", &sets.NohzFull, true},
 	}
-	if err := json.Unmarshal(output, &result); err != nil {
-		return 0, err
+	for _, file := range files {
+		cpus, err := readKernelCPUList(file.name)
+		if err != nil && file.optional && errors.Is(err, os.ErrNotExist) {
+			cpus = make(CPUs)
+		} else if err != nil {
+			return CPUSets{}, err
+		}
+		*file.set = cpus
+	}
+	return sets, nil
+}
+
+// SplitOnline splits the CPUs into the online and the offline ones, and
+// fails when any of the CPUs doesn't exist. CPUs taken offline, e.g. by
+// a CPU hotplug rule, are skipped by the rules covering them, as their
+// settings can't be applied.
+func (s CPUSets) SplitOnline(cpus CPUs) (online CPUs, offline []int, err error) {
+	list := make([]int, 0, len(cpus))
+	for cpu := range cpus {
+		list = append(list, cpu)
 	}
+	sort.Ints(list)
 
-	for _, cpu := range result.CPUs {
-		if strings.TrimSpace(cpu.Field) == "CPU(s):" {
-			totalCPUs, err := strconv.Atoi(cpu.Data)
-			if err != nil {
-				return 0, err
-			}
-			return totalCPUs, nil
+	online = make(CPUs)
+	for _, cpu := range list {
+		if !s.Present[cpu] {
+			return nil, nil, fmt.Errorf("CPU %d does not exist", cpu)
+		}
+		if !s.Online[cpu] {
+			offline = append(offline, cpu)
+			continue
 		}
+		online[cpu] = true
 	}
+	return online, offline, nil
+}
 
-	return 0, fmt.Errorf("could not find total CPUs")
+// ParseOnline parses a CPU Lists string into the online CPUs and the
+// offline ones, and fails when any of the CPUs doesn't exist
+func ParseOnline(cpuLists string) (online CPUs, offline []int, err error) {
+	cpus, err := Parse(cpuLists)
+	if err != nil {
+		return nil, nil, err
+	}
+	sets, err := ReadCPUSets()
+	if err != nil {
+		return nil, nil, err
+	}
+	return sets.SplitOnline(cpus)
+}
+
+// LogOffline logs the offline CPUs skipped by a rule, if any
+func LogOffline(offline []int) {
+	if len(offline) > 0 {
+		log.Printf("Warning: skipping offline CPUs %s\n", GenCPUlist(offline))
+	}
+}
+
+// TotalCPUs returns the number of CPUs which can be named in CPU Lists,
+// i.e. the highest present CPU + 1
+func TotalCPUs() (int, error) {
+	return totalCPUs()
+}
+
+// ReadNodes returns the NUMA nodes of the machine, from their directories
+// under the sysfs root, e.g. /sys/devices/system/node/node0
+func ReadNodes() (CPUs, error) {
+	entries, err := os.ReadDir(sysNodePath())
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(CPUs)
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "node"))
+		if err != nil || !strings.HasPrefix(entry.Name(), "node") {
+			continue
+		}
+		nodes[id] = true
+	}
+	return nodes, nil
+}
+
+// totalCPUs returns the number of CPUs which can be named in CPU Lists,
+// i.e. the highest present CPU + 1, so "N" is the last present CPU
+var totalCPUs = func() (int, error) {
+	present, err := readKernelCPUList("present")
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for cpu := range present {
+		total = max(total, cpu+1)
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("no present CPUs found")
+	}
+	return total, nil
 }
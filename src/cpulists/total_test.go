@@ -1,11 +1,23 @@
 package cpulists
 
 import (
-	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
 
+// setupCPUSets creates a fake sysfs root with the given CPU set files
+func setupCPUSets(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	t.Cleanup(SetSysfsRoot(t.TempDir()))
+	for name, content := range files {
+		writeSysfsFile(t, filepath.Join(SysCPUPath(), name), content)
+	}
+}
+
 func TestTotalCPUs(t *testing.T) {
 	c, err := totalCPUs()
 	if err != nil {
@@ -16,52 +28,45 @@ func TestTotalCPUs(t *testing.T) {
 	}
 }
 
-func TestTotalCPUsUnhappy(t *testing.T) {
-	type testCase struct {
-		name       string
-		mockOutput []byte
-		mockErr    error
-		expectErr  string
+func TestTotalCPUsFakeSysfs(t *testing.T) {
+	// Possible CPUs beyond the present ones can't be named
+	setupCPUSets(t, map[string]string{
+		"possible": "0-63",
+		"present":  "0-7",
+	})
+
+	total, err := totalCPUs()
+	if err != nil || total != 8 {
+		t.Fatalf("expected (8, nil), got (%d, %v)", total, err)
 	}
+}
 
-	testCases := []testCase{
-		{
-			name:       "command error",
-			mockOutput: nil,
-			mockErr:    errors.New("fake command error"),
-			expectErr:  "fake command error",
-		},
+func TestTotalCPUsUnhappy(t *testing.T) {
+	testCases := []struct {
+		name      string
+		files     map[string]string
+		expectErr string
+	}{
 		{
-			name:       "invalid json output",
-			mockOutput: []byte("not-json"),
-			expectErr:  "invalid character",
+			name:      "missing present file",
+			files:     map[string]string{},
+			expectErr: "no such file or directory",
 		},
 		{
-			name: "invalid CPU number",
-			mockOutput: []byte(`{
-				"lscpu": [
-					{ "field": "CPU(s):", "data": "not-an-int", "children": [] }
-				]
-			}`),
-			expectErr: "invalid syntax",
+			name:      "invalid present file",
+			files:     map[string]string{"present": "0-a"},
+			expectErr: "invalid end of range",
 		},
 		{
-			name: "missing CPU field",
-			mockOutput: []byte(`{
-				"lscpu": [
-					{ "field": "Model name:", "data": "Intel Xeon", "children": [] }
-				]
-			}`),
-			expectErr: "could not find total CPUs",
+			name:      "no present CPUs",
+			files:     map[string]string{"present": ""},
+			expectErr: "no present CPUs found",
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			// override the execCommand used by totalCPUs
-			execCommand = func(_ string, _ ...string) ([]byte, error) {
-				return tc.mockOutput, tc.mockErr
-			}
+			setupCPUSets(t, tc.files)
 
 			_, err := totalCPUs()
 			if err == nil {
@@ -73,3 +78,96 @@ func TestTotalCPUsUnhappy(t *testing.T) {
 		})
 	}
 }
+
+func TestReadCPUSets(t *testing.T) {
+	setupCPUSets(t, map[string]string{
+		"possible":  "0-15",
+		"present":   "0-7",
+		"online":    "0-5",
+		"offline":   "6-15",
+		"isolated":  "4-5",
+		"nohz_full": "(null)",
+	})
+
+	sets, err := ReadCPUSets()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sets.Possible) != 16 || len(sets.Present) != 8 || len(sets.Online) != 6 ||
+		len(sets.Offline) != 10 || len(sets.Isolated) != 2 || len(sets.NohzFull) != 0 {
+		t.Fatalf("unexpected CPU sets: %+v", sets)
+	}
+
+	testCases := []struct {
+		cpus      CPUs
+		online    CPUs
+		offline   []int
+		expectErr string
+	}{
+		{CPUs{0: true, 5: true}, CPUs{0: true, 5: true}, nil, ""},
+		{CPUs{5: true, 6: true, 7: true}, CPUs{5: true}, []int{6, 7}, ""},
+		{CPUs{8: true}, nil, nil, "CPU 8 does not exist"},
+	}
+	for _, tc := range testCases {
+		online, offline, err := sets.SplitOnline(tc.cpus)
+		if tc.expectErr != "" {
+			if err == nil || err.Error() != tc.expectErr {
+				t.Errorf("%v: expected error %q, got: %v", tc.cpus, tc.expectErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.cpus, err)
+			continue
+		}
+		if !reflect.DeepEqual(online, tc.online) || !reflect.DeepEqual(offline, tc.offline) {
+			t.Errorf("%v: expected (%v, %v), got (%v, %v)",
+				tc.cpus, tc.online, tc.offline, online, offline)
+		}
+	}
+}
+
+func TestReadCPUSetsOptionalFiles(t *testing.T) {
+	// Older kernels don't report the isolated and nohz_full CPUs
+	setupCPUSets(t, map[string]string{
+		"possible": "0-3",
+		"present":  "0-3",
+		"online":   "0-3",
+	})
+
+	sets, err := ReadCPUSets()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sets.Offline) != 0 || len(sets.Isolated) != 0 || len(sets.NohzFull) != 0 {
+		t.Fatalf("unexpected CPU sets: %+v", sets)
+	}
+
+	if err := os.Remove(filepath.Join(SysCPUPath(), "online")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	if _, err := ReadCPUSets(); err == nil {
+		t.Fatalf("expected error for missing online file")
+	}
+}
+
+func TestParseOnline(t *testing.T) {
+	setupCPUSets(t, map[string]string{
+		"possible": "0-3",
+		"present":  "0-3",
+		"online":   "0-2",
+	})
+
+	online, offline, err := ParseOnline("0-2")
+	if err != nil || len(online) != 3 || len(offline) != 0 {
+		t.Fatalf("expected CPUs 0-2 online, got (%v, %v, %v)", online, offline, err)
+	}
+	// The offline CPU of the expansion is skipped
+	online, offline, err = ParseOnline("all")
+	if err != nil || len(online) != 3 || !reflect.DeepEqual(offline, []int{3}) {
+		t.Fatalf("expected CPU 3 to be skipped, got (%v, %v, %v)", online, offline, err)
+	}
+	if _, _, err := ParseOnline("4"); err == nil || err.Error() != "CPU 4 does not exist" {
+		t.Fatalf("expected missing CPU error, got: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package hotplug
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Documentation for CPU hotplug:
+// https://docs.kernel.org/core-api/cpu_hotplug.html
+
+type ReaderWriter struct {
+	OnlinePath string
+}
+
+// defaultReaderWriter uses the sysfs root of the CPU enumeration, so the
+// CPUs taken offline are seen by the rules applied afterwards
+func defaultReaderWriter() ReaderWriter {
+	return ReaderWriter{
+		OnlinePath: filepath.Join(cpulists.SysCPUPath(), "cpu%d", "online"),
+	}
+}
+
+// ReadOnline returns true if the CPU is online.
+// CPUs which can't be hotplugged don't expose the online file and are
+// always online.
+func (rw ReaderWriter) ReadOnline(cpu int) (bool, error) {
+	path := fmt.Sprintf(rw.OnlinePath, cpu)
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return strings.TrimSpace(string(content)) == "1", nil
+}
+
+func (rw ReaderWriter) WriteOnline(cpu int, online bool) error {
+	path := fmt.Sprintf(rw.OnlinePath, cpu)
+	value := "0"
+	if online {
+		value = "1"
+	}
+
+	return utils.WriteOnly(path, value)
+}
+
+// ApplyHotplugConfig sets CPUs online or offline.
+// It must run before the other runtime rules, since IRQ affinities and
+// cpufreq settings are lost or reset when a CPU goes offline.
+func ApplyHotplugConfig(config *model.InternalConfig) error {
+	utils.PrintTitle("CPU Hotplug")
+	if len(config.Data.CpuHotplug) == 0 {
+		log.Println("No CPU hotplug rules found in config")
+		return nil
+	}
+	return defaultReaderWriter().applyHotplugConfig(config.Data.CpuHotplug)
+}
+
+// Apply changes based on YAML config
+func (rw ReaderWriter) applyHotplugConfig(rules model.CpuHotplug) error {
+	for _, label := range rules.Labels() {
+		rule := rules[label]
+		log.Printf("Rule: %s\n", label)
+
+		cpus, err := cpulists.Parse(rule.CPUs)
+		if err != nil {
+			return err
+		}
+
+		sortedCPUs := make([]int, 0, len(cpus))
+		for cpu := range cpus {
+			sortedCPUs = append(sortedCPUs, cpu)
+		}
+		sort.Ints(sortedCPUs)
+
+		online := rule.State == model.CpuOnline
+		var changed, unchanged []int
+		for _, cpu := range sortedCPUs {
+			current, err := rw.ReadOnline(cpu)
+			if err != nil {
+				return err
+			}
+			if current == online {
+				unchanged = append(unchanged, cpu)
+				continue
+			}
+			if err := rw.WriteOnline(cpu, online); err != nil {
+				return fmt.Errorf(
+					"failed to apply CPU hotplug rule #%s for CPU %d: %v",
+					label, cpu, err)
+			}
+			changed = append(changed, cpu)
+		}
+		logChanges(changed, unchanged, rule.State)
+	}
+	return nil
+}
+
+func logChanges(changed, unchanged []int, state string) {
+	var msgs []string
+	if len(changed) > 0 {
+		msgs = append(msgs, fmt.Sprintf("Set CPU%s %s %s",
+			pluralSuffix(changed), cpulists.GenCPUlist(changed), state))
+	}
+	if len(unchanged) > 0 {
+		msgs = append(msgs, fmt.Sprintf("CPU%s %s already %s",
+			pluralSuffix(unchanged), cpulists.GenCPUlist(unchanged), state))
+	}
+	utils.LogTreeStyle(msgs)
+}
+
+func pluralSuffix(cpus []int) string {
+	if len(cpus) == 1 {
+		return ""
+	}
+	return "s"
+}
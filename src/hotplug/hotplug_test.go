@@ -0,0 +1,98 @@
+package hotplug
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+func setupOnlineFile(t *testing.T, content string) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	cpuDir := filepath.Join(tmpDir, "0")
+	if err := os.Mkdir(cpuDir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cpuDir, "online"),
+		[]byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	return filepath.Join(tmpDir, "%d", "online")
+}
+
+func TestApplyHotplugConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		prev     string
+		state    string
+		expected string
+	}{
+		{"online to offline", "1\n", model.CpuOffline, "0"},
+		{"offline to online", "0\n", model.CpuOnline, "1"},
+		{"already online", "1\n", model.CpuOnline, "1\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := setupOnlineFile(t, tc.prev)
+			rw := ReaderWriter{OnlinePath: path}
+
+			err := rw.applyHotplugConfig(model.CpuHotplug{
+				"foo": {CPUs: "0", State: tc.state},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			content, err := os.ReadFile(strings.Replace(path, "%d", "0", 1))
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if string(content) != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, string(content))
+			}
+		})
+	}
+}
+
+func TestReadOnlineNotHotpluggable(t *testing.T) {
+	rw := ReaderWriter{OnlinePath: "/does/not/exist/%d/online"}
+
+	online, err := rw.ReadOnline(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !online {
+		t.Fatalf("expected CPU without online file to be online")
+	}
+}
+
+func TestApplyHotplugConfigUnhappy(t *testing.T) {
+	path := setupOnlineFile(t, "1\n")
+	// Make the online file unwritable by replacing it with a directory
+	onlineFile := strings.Replace(path, "%d", "0", 1)
+	if err := os.Remove(onlineFile); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	if err := os.Mkdir(onlineFile, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	rw := ReaderWriter{OnlinePath: path}
+
+	err := rw.applyHotplugConfig(model.CpuHotplug{
+		"foo": {CPUs: "0", State: model.CpuOffline},
+	})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestEmptyHotplugRules(t *testing.T) {
+	if err := ApplyHotplugConfig(&model.InternalConfig{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package hugepages
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Documentation for the hugepages sysfs files:
+// https://docs.kernel.org/admin-guide/mm/hugetlbpage.html
+
+type ReaderWriter struct {
+	// Directory of the system wide hugepage pools
+	MMPath string
+	// Directory of the NUMA nodes, each with its own hugepage pools
+	NodePath string
+	// Strict turns any shortfall in the allocated hugepages into an error
+	Strict bool
+}
+
+var hugepagesReaderWriter = ReaderWriter{
+	MMPath:   "/sys/kernel/mm/hugepages",
+	NodePath: "/sys/devices/system/node",
+}
+
+// writeFile is a variable, so tests can simulate the kernel allocating fewer
+// hugepages than requested
+var writeFile = utils.WriteOnly
+
+// poolDir returns the directory of the hugepage pool of a size in kB, on a
+// node or system wide when node is -1
+func (rw ReaderWriter) poolDir(sizeKB, node int) string {
+	pool := fmt.Sprintf("hugepages-%dkB", sizeKB)
+	if node == -1 {
+		return filepath.Join(rw.MMPath, pool)
+	}
+	return filepath.Join(rw.NodePath, fmt.Sprintf("node%d", node), "hugepages", pool)
+}
+
+// ReadNrHugepages reads the number of hugepages of a pool
+func (rw ReaderWriter) ReadNrHugepages(sizeKB, node int) (int, error) {
+	path := filepath.Join(rw.poolDir(sizeKB, node), "nr_hugepages")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	return count, nil
+}
+
+// WriteNrHugepages requests a number of hugepages for a pool, and returns
+// the number actually allocated by the kernel, which can be lower when the
+// memory is fragmented
+func (rw ReaderWriter) WriteNrHugepages(sizeKB, node, count int) (int, error) {
+	dir := rw.poolDir(sizeKB, node)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if node != -1 {
+			if _, err := os.Stat(filepath.Dir(filepath.Dir(dir))); errors.Is(err, os.ErrNotExist) {
+				return 0, fmt.Errorf("NUMA node %d does not exist", node)
+			}
+		}
+		return 0, fmt.Errorf("hugepage size %s is not supported",
+			model.FormatHugepageSize(sizeKB))
+	}
+
+	if err := writeFile(filepath.Join(dir, "nr_hugepages"), strconv.Itoa(count)); err != nil {
+		return 0, err
+	}
+	return rw.ReadNrHugepages(sizeKB, node)
+}
+
+func ApplyHugepagesConfig(config *model.InternalConfig) error {
+	utils.PrintTitle("Hugepages")
+	if len(config.Data.Hugepages) == 0 {
+		log.Println("No hugepages rules found in config")
+		return nil
+	}
+	rw := hugepagesReaderWriter
+	rw.Strict = config.Strict
+	return rw.applyHugepagesConfig(config.Data.Hugepages)
+}
+
+// Apply changes based on YAML config
+func (rw ReaderWriter) applyHugepagesConfig(rules model.Hugepages) error {
+	for _, label := range rules.Labels() {
+		rule := rules[label]
+		log.Printf("Rule: %s\n", label)
+
+		msgs, err := rw.applyRule(rule)
+		if err != nil {
+			return fmt.Errorf("failed to apply hugepages rule #%s: %v", label, err)
+		}
+		utils.LogTreeStyle(msgs)
+	}
+	return nil
+}
+
+// applyRule reserves the hugepages of a rule, returning a message per pool
+func (rw ReaderWriter) applyRule(rule model.HugepagesRule) ([]string, error) {
+	sizeKB, err := model.ParseHugepageSize(rule.Size)
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := rule.ParseNodes()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []int{-1}
+	if cpus != nil {
+		nodes = nodes[:0]
+		for node := range cpus {
+			nodes = append(nodes, node)
+		}
+		sort.Ints(nodes)
+	}
+
+	size := model.FormatHugepageSize(sizeKB)
+	var msgs []string
+	for _, node := range nodes {
+		where := "system wide"
+		if node != -1 {
+			where = fmt.Sprintf("on node %d", node)
+		}
+
+		allocated, err := rw.WriteNrHugepages(sizeKB, node, rule.Count)
+		if err != nil {
+			return nil, err
+		}
+		if allocated == rule.Count {
+			msgs = append(msgs, fmt.Sprintf("Reserved %d hugepages of %s %s",
+				allocated, size, where))
+			continue
+		}
+
+		msg := fmt.Sprintf("%d hugepages of %s reserved %s, requested %d",
+			allocated, size, where, rule.Count)
+		if rw.Strict {
+			return nil, errors.New(msg)
+		}
+		if allocated < rule.Count {
+			// Not enough contiguous free memory
+			msg += ": the memory may be too fragmented, consider reserving them at boot"
+		} else {
+			// Hugepages in use can't be freed
+			msg += ": some of them are in use"
+		}
+		msgs = append(msgs, "Warning: "+msg)
+	}
+	return msgs, nil
+}
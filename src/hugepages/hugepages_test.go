@@ -0,0 +1,156 @@
+package hugepages
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// setupPools creates fake system wide and per node hugepage pools of 2M and
+// 1G on nodes 0 and 1
+func setupPools(t *testing.T) ReaderWriter {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	rw := ReaderWriter{
+		MMPath:   filepath.Join(tmpDir, "mm"),
+		NodePath: filepath.Join(tmpDir, "node"),
+	}
+	for _, size := range []int{2048, 1048576} {
+		for _, node := range []int{-1, 0, 1} {
+			dir := rw.poolDir(size, node)
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "nr_hugepages"),
+				[]byte("0\n"), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+		}
+	}
+	return rw
+}
+
+func TestApplyHugepagesConfig(t *testing.T) {
+	rw := setupPools(t)
+
+	err := rw.applyHugepagesConfig(model.Hugepages{
+		"small": {Size: "2M", Count: 512},
+		"large": {Size: "1G", Count: 4, Nodes: "1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		size, node, count int
+	}{
+		{2048, -1, 512},
+		{1048576, 1, 4},
+		{1048576, 0, 0},
+		{1048576, -1, 0},
+	}
+	for _, e := range expected {
+		got, err := rw.ReadNrHugepages(e.size, e.node)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != e.count {
+			t.Errorf("size %dkB node %d: expected %d, got %d",
+				e.size, e.node, e.count, got)
+		}
+	}
+}
+
+func TestApplyRuleMismatch(t *testing.T) {
+	prev := writeFile
+	t.Cleanup(func() { writeFile = prev })
+
+	testCases := []struct {
+		name      string
+		allocated string
+		strict    bool
+		wantMsg   string
+		wantErr   string
+	}{
+		{
+			name:      "fragmented memory",
+			allocated: "1",
+			wantMsg:   "Warning: 1 hugepages of 1G reserved on node 0, requested 4: the memory may be too fragmented",
+		},
+		{
+			name:      "hugepages in use",
+			allocated: "6",
+			wantMsg:   "Warning: 6 hugepages of 1G reserved on node 0, requested 4: some of them are in use",
+		},
+		{
+			name:      "strict",
+			allocated: "1",
+			strict:    true,
+			wantErr:   "1 hugepages of 1G reserved on node 0, requested 4",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := setupPools(t)
+			rw.Strict = tc.strict
+			writeFile = func(path, _ string) error {
+				return utils.WriteOnly(path, tc.allocated)
+			}
+
+			msgs, err := rw.applyRule(model.HugepagesRule{Size: "1G", Count: 4, Nodes: "0"})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(msgs) != 1 || !strings.HasPrefix(msgs[0], tc.wantMsg) {
+				t.Fatalf("expected message %q, got %v", tc.wantMsg, msgs)
+			}
+		})
+	}
+}
+
+func TestApplyRuleErrors(t *testing.T) {
+	rw := setupPools(t)
+
+	testCases := []struct {
+		name    string
+		rule    model.HugepagesRule
+		wantErr string
+	}{
+		{
+			name:    "unsupported size",
+			rule:    model.HugepagesRule{Size: "16G", Count: 1},
+			wantErr: "hugepage size 16G is not supported",
+		},
+		{
+			name:    "missing node",
+			rule:    model.HugepagesRule{Size: "2M", Count: 1, Nodes: "2"},
+			wantErr: "NUMA node 2 does not exist",
+		},
+		{
+			name:    "unsupported size on node",
+			rule:    model.HugepagesRule{Size: "16G", Count: 1, Nodes: "0"},
+			wantErr: "hugepage size 16G is not supported",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := rw.applyRule(tc.rule)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
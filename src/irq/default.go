@@ -0,0 +1,159 @@
+package irq
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// ApplyIRQDefaults sets the default affinity of newly registered IRQs and
+// moves the active non-managed IRQs off the CPUs set in remove-from-cpus
+func ApplyIRQDefaults(config *model.InternalConfig) error {
+	utils.PrintTitle("IRQ Default Affinity")
+	if config.Data.IRQAffinity.IsEmpty() {
+		log.Println("No IRQ affinity options found in config")
+		return nil
+	}
+
+	sets, err := cpulists.ReadCPUSets()
+	if err != nil {
+		return err
+	}
+	total, err := cpulists.TotalCPUs()
+	if err != nil {
+		return err
+	}
+	return applyIRQDefaults(config.Data.IRQAffinity, sets.Online, total,
+		&realIRQReaderWriter{})
+}
+
+// applyIRQDefaults applies the IRQ affinity options, where all is the set of
+// CPUs online in the system and total the number of CPUs which can be named
+// in CPU Lists, which is more than the online CPUs when some are missing
+func applyIRQDefaults(
+	cfg model.IRQs,
+	all cpulists.CPUs,
+	total int,
+	handler IRQReaderWriter,
+) error {
+	removed := make(cpulists.CPUs)
+	if cfg.IsolateCPU != "" {
+		cpus, err := cpulists.ParseForCPUs(cfg.IsolateCPU, total)
+		if err != nil {
+			return err
+		}
+		removed = cpus
+	}
+
+	// The housekeeping CPUs are the ones set in handle-on-cpus, or all the
+	// CPUs except the ones set in remove-from-cpus
+	housekeeping := make(cpulists.CPUs)
+	if cfg.IRQHandler != "" {
+		cpus, err := cpulists.ParseForCPUs(cfg.IRQHandler, total)
+		if err != nil {
+			return err
+		}
+		housekeeping = cpus
+	} else {
+		for cpu := range all {
+			if !removed[cpu] {
+				housekeeping[cpu] = true
+			}
+		}
+	}
+	if len(housekeeping) == 0 {
+		return fmt.Errorf("no CPUs left to handle IRQs")
+	}
+
+	mask := cpulists.GenHexMask(housekeeping)
+	if err := handler.WriteDefaultAffinity(mask); err != nil {
+		return err
+	}
+	msgs := []string{fmt.Sprintf("Set default IRQ affinity to CPUs %s (mask %s)",
+		canonicalCPUList(housekeeping), mask)}
+
+	if len(removed) > 0 {
+		moved, managed, err := removeIRQsFromCPUs(removed, housekeeping, handler)
+		if err != nil {
+			return err
+		}
+		if len(moved) > 0 {
+			msgs = append(msgs, fmt.Sprintf("Moved IRQs %s off CPUs %s",
+				cpulists.GenCPUlist(moved), canonicalCPUList(removed)))
+		} else {
+			msgs = append(msgs, fmt.Sprintf("No IRQs to move off CPUs %s",
+				canonicalCPUList(removed)))
+		}
+		if len(managed) > 0 {
+			msgs = append(msgs, fmt.Sprintf("Ignored managed IRQs %s on CPUs %s",
+				cpulists.GenCPUlist(managed), canonicalCPUList(removed)))
+		}
+	}
+	utils.LogTreeStyle(msgs)
+	return nil
+}
+
+// removeIRQsFromCPUs removes the given CPUs from the affinity of all active
+// IRQs. IRQs left with no CPUs are moved to the housekeeping CPUs.
+// It returns the managed IRQs on the removed CPUs, as they can't be moved.
+func removeIRQsFromCPUs(
+	removed, housekeeping cpulists.CPUs,
+	handler IRQReaderWriter,
+) (moved, managed []int, err error) {
+	irqs, err := handler.ReadIRQs()
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(irqs, func(i, j int) bool {
+		return irqs[i].Number < irqs[j].Number
+	})
+
+	for _, irq := range irqs {
+		affinity, err := handler.ReadCPUAffinity(irq.Number)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The affinity may name possible CPUs which are not present
+		current, err := cpulists.ParseForCPUs(affinity, maxCPUInList(affinity)+1)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid affinity of IRQ %d: %v",
+				irq.Number, err)
+		}
+
+		remaining := make(cpulists.CPUs)
+		for cpu := range current {
+			if !removed[cpu] {
+				remaining[cpu] = true
+			}
+		}
+		if len(remaining) == len(current) {
+			continue // Not on any of the removed CPUs
+		}
+		if irq.Managed {
+			managed = append(managed, irq.Number)
+			continue
+		}
+		if len(remaining) == 0 {
+			remaining = housekeeping
+		}
+
+		success, managedIRQ, err := handler.WriteCPUAffinity(irq.Number,
+			canonicalCPUList(remaining))
+		if err != nil {
+			return nil, nil, err
+		}
+		// Without debugfs, managed IRQs are only detected when the kernel
+		// rejects the write
+		if managedIRQ {
+			managed = append(managed, irq.Number)
+		}
+		if success {
+			moved = append(moved, irq.Number)
+		}
+	}
+	return moved, managed, nil
+}
@@ -0,0 +1,165 @@
+package irq
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+)
+
+func TestApplyIRQDefaults(t *testing.T) {
+	all, err := cpulists.ParseForCPUs("0-7", 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name            string
+		cfg             model.IRQs
+		affinity        map[int]string
+		defaultAffinity string
+		wantAffinity    map[int]string
+		err             string
+	}{
+		{
+			name:            "default affinity only",
+			cfg:             model.IRQs{IRQHandler: "0-1"},
+			affinity:        map[int]string{10: "0-7", 11: "4"},
+			defaultAffinity: "3",
+			wantAffinity:    map[int]string{10: "0-7", 11: "4"},
+		},
+		{
+			name:            "remove from CPUs with handlers",
+			cfg:             model.IRQs{IsolateCPU: "4-7", IRQHandler: "0-1"},
+			affinity:        map[int]string{10: "0-7", 11: "4", 12: "2"},
+			defaultAffinity: "3",
+			wantAffinity:    map[int]string{10: "0-3", 11: "0-1", 12: "2"},
+		},
+		{
+			name:            "remove from CPUs without handlers",
+			cfg:             model.IRQs{IsolateCPU: "4-7"},
+			affinity:        map[int]string{10: "5-6", 11: "3-4"},
+			defaultAffinity: "f",
+			wantAffinity:    map[int]string{10: "0-3", 11: "3"},
+		},
+		{
+			name: "no CPUs left",
+			cfg:  model.IRQs{IsolateCPU: "0-7"},
+			err:  "no CPUs left to handle IRQs",
+		},
+		{
+			name:     "invalid current affinity",
+			cfg:      model.IRQs{IsolateCPU: "4-7"},
+			affinity: map[int]string{10: "garbage"},
+			err:      "invalid affinity of IRQ 10",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &mockIRQReaderWriter{
+				IRQs:            map[uint]IRQInfo{},
+				WrittenAffinity: tc.affinity,
+			}
+			for irq := range tc.affinity {
+				handler.IRQs[uint(irq)] = IRQInfo{Number: irq, Actions: "dev"}
+			}
+
+			err := applyIRQDefaults(tc.cfg, all, 8, handler)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if handler.DefaultAffinity != tc.defaultAffinity {
+				t.Errorf("expected default affinity %q, got %q",
+					tc.defaultAffinity, handler.DefaultAffinity)
+			}
+			for irq, want := range tc.wantAffinity {
+				if got := handler.WrittenAffinity[irq]; got != want {
+					t.Errorf("IRQ %d: expected affinity %q, got %q", irq, want, got)
+				}
+			}
+		})
+	}
+}
+
+// CPU Lists are parsed against the highest present CPU, not against the
+// number of online CPUs
+func TestApplyIRQDefaultsSparseCPUs(t *testing.T) {
+	all, err := cpulists.ParseForCPUs("0-3,6-7", 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := &mockIRQReaderWriter{
+		IRQs:            map[uint]IRQInfo{10: {Number: 10, Actions: "dev"}},
+		WrittenAffinity: map[int]string{10: "6-7"},
+	}
+
+	err = applyIRQDefaults(model.IRQs{IsolateCPU: "6-7"}, all, 8, handler)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if handler.DefaultAffinity != "f" {
+		t.Errorf("expected default affinity %q, got %q", "f", handler.DefaultAffinity)
+	}
+	if got := handler.WrittenAffinity[10]; got != "0-3" {
+		t.Errorf("expected affinity %q, got %q", "0-3", got)
+	}
+}
+
+// The affinities may name possible CPUs which are not present, like the
+// default one on machines with CPU hotplug slots
+func TestApplyIRQDefaultsPossibleCPUs(t *testing.T) {
+	all := cpulists.CPUs{0: true, 1: true, 2: true, 3: true}
+	handler := &mockIRQReaderWriter{
+		IRQs:            map[uint]IRQInfo{10: {Number: 10, Actions: "dev"}},
+		WrittenAffinity: map[int]string{10: "0-7"},
+	}
+
+	err := applyIRQDefaults(model.IRQs{IsolateCPU: "2-3"}, all, 4, handler)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := handler.WrittenAffinity[10]; got != "0-1,4-7" {
+		t.Errorf("expected affinity %q, got %q", "0-1,4-7", got)
+	}
+}
+
+// Only the managed IRQs on the removed CPUs are reported, whether debugfs
+// reports them or the kernel rejects their affinity
+func TestRemoveIRQsFromCPUsManaged(t *testing.T) {
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			10: {Number: 10, Actions: "nvme0q1", Managed: true},
+			11: {Number: 11, Actions: "nvme0q2", Managed: true},
+			12: {Number: 12, Actions: "nvme1q1"},
+			13: {Number: 13, Actions: "nvme1q2"},
+			14: {Number: 14, Actions: "eth0"},
+		},
+		WrittenAffinity: map[int]string{
+			10: "0", 11: "3", 12: "1", 13: "2-3", 14: "2-3",
+		},
+		RejectedIRQs: map[int]bool{12: true, 13: true},
+	}
+
+	removed := cpulists.CPUs{2: true, 3: true}
+	housekeeping := cpulists.CPUs{0: true, 1: true}
+	moved, managed, err := removeIRQsFromCPUs(removed, housekeeping, handler)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(moved, []int{14}) {
+		t.Errorf("expected moved IRQs [14], got %v", moved)
+	}
+	if !slices.Equal(managed, []int{11, 13}) {
+		t.Errorf("expected managed IRQs [11 13], got %v", managed)
+	}
+}
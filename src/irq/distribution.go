@@ -0,0 +1,160 @@
+package irq
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Multi-queue device drivers name their IRQ handlers after the queue index,
+// e.g. eth0-TxRx-3, nvme0q3 or iwlwifi:queue_3. The index follows a
+// separator, or the q of NVMe queues, so eth0 or i8042 have no index.
+var queueIndexRegex = regexp.MustCompile(`(?:[-_:.]|\dq)(\d+)$`)
+
+// queueIndex returns the queue index of an IRQ, parsed from its first action
+func queueIndex(actions string) (int, bool) {
+	action, _, _ := strings.Cut(actions, ",")
+	match := queueIndexRegex.FindStringSubmatch(strings.TrimSpace(action))
+	if match == nil {
+		return 0, false
+	}
+	index, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return index, true
+}
+
+// sortByQueue sorts the IRQs by queue index, then by number. IRQs without
+// a queue index go last.
+func sortByQueue(irqs []IRQInfo) {
+	sort.SliceStable(irqs, func(i, j int) bool {
+		qi, iok := queueIndex(irqs[i].Actions)
+		qj, jok := queueIndex(irqs[j].Actions)
+		if iok != jok {
+			return iok
+		}
+		if iok && qi != qj {
+			return qi < qj
+		}
+		return irqs[i].Number < irqs[j].Number
+	})
+}
+
+// distributeIRQs assigns each IRQ to a single CPU, according to the
+// distribution mode
+func distributeIRQs(irqs []IRQInfo, cpus cpulists.CPUs, mode string) map[int]int {
+	sorted := append([]IRQInfo(nil), irqs...)
+	sortByQueue(sorted)
+
+	cpuList := make([]int, 0, len(cpus))
+	for cpu := range cpus {
+		cpuList = append(cpuList, cpu)
+	}
+	sort.Ints(cpuList)
+
+	assignments := make(map[int]int, len(sorted))
+	for i, irq := range sorted {
+		var index int
+		switch mode {
+		case model.DistributionSpread:
+			index = i * len(cpuList) / len(sorted)
+		default:
+			index = i % len(cpuList)
+		}
+		assignments[irq.Number] = cpuList[index]
+	}
+	return assignments
+}
+
+// irqAffinities returns the CPU list to be written to each of the IRQs
+// matched by a rule, along with the offline CPUs of the rule, which are left
+// out of the lists
+func irqAffinities(rule model.IRQTuning, matched []IRQInfo) (map[int]string, []int, error) {
+	// The kernel doesn't understand the topology selectors, and rejects
+	// affinities with offline CPUs only
+	cpus, offline, err := cpulists.ParseOnline(rule.CPUs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cpus) == 0 {
+		return nil, nil, fmt.Errorf("all CPUs are offline")
+	}
+
+	affinities := make(map[int]string, len(matched))
+	if rule.Distribution == "" {
+		affinity := canonicalCPUList(cpus)
+		for _, irq := range matched {
+			affinities[irq.Number] = affinity
+		}
+		return affinities, offline, nil
+	}
+
+	// Managed IRQs are left out, as their affinity can't be set
+	var distributed []IRQInfo
+	for _, irq := range matched {
+		if !irq.Managed {
+			distributed = append(distributed, irq)
+		}
+	}
+	for irq, cpu := range distributeIRQs(distributed, cpus, rule.Distribution) {
+		affinities[irq] = strconv.Itoa(cpu)
+	}
+	return affinities, offline, nil
+}
+
+// matchingIRQInfos returns the IRQs matched by a rule
+func matchingIRQInfos(irqs []IRQInfo, rule model.IRQTuning) ([]IRQInfo, error) {
+	m, err := newIRQMatcher(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []IRQInfo
+	for _, irq := range irqs {
+		if m.matches(irq) {
+			matched = append(matched, irq)
+		}
+	}
+	return matched, nil
+}
+
+// distributeRule writes the single CPU of its affinity to each of the IRQs
+// matched by a rule, logs the resulting mapping and returns the managed IRQs
+func distributeRule(matched []IRQInfo, affinities map[int]string, handler IRQReaderWriter) ([]int, error) {
+	sorted := append([]IRQInfo(nil), matched...)
+	sortByQueue(sorted)
+
+	var msgs []string
+	var managed []int
+	for _, irq := range sorted {
+		if irq.Managed {
+			managed = append(managed, irq.Number)
+			continue
+		}
+		cpu := affinities[irq.Number]
+		success, managedIRQ, err := handler.WriteCPUAffinity(irq.Number, cpu)
+		if err != nil {
+			return nil, err
+		}
+		if managedIRQ {
+			managed = append(managed, irq.Number)
+		}
+		if success {
+			msgs = append(msgs, fmt.Sprintf("Assigned IRQ %d (%s) to CPU %s",
+				irq.Number, irq.Actions, cpu))
+		}
+	}
+	if len(managed) > 0 {
+		msgs = append(msgs, fmt.Sprintf("Ignored managed IRQs: %s",
+			cpulists.GenCPUlist(managed)))
+	}
+	utils.LogTreeStyle(msgs)
+	return managed, nil
+}
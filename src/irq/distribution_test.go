@@ -0,0 +1,215 @@
+package irq
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+)
+
+func TestQueueIndex(t *testing.T) {
+	testCases := []struct {
+		actions string
+		index   int
+		found   bool
+	}{
+		{actions: "eth0-TxRx-3", index: 3, found: true},
+		{actions: "nvme0q12", index: 12, found: true},
+		{actions: "iwlwifi:queue_2", index: 2, found: true},
+		{actions: "eth0-rx-1, eth0-tx-1", index: 1, found: true},
+		{actions: "virtio0-input.0", index: 0, found: true},
+		{actions: "i8042", found: false},
+		{actions: "eth0", found: false},
+		{actions: "acpi", found: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.actions, func(t *testing.T) {
+			index, found := queueIndex(tc.actions)
+			if found != tc.found || index != tc.index {
+				t.Fatalf("expected (%d, %v), got (%d, %v)",
+					tc.index, tc.found, index, found)
+			}
+		})
+	}
+}
+
+func TestDistributeIRQs(t *testing.T) {
+	irqs := []IRQInfo{
+		{Number: 50, Actions: "eth0-TxRx-3"},
+		{Number: 51, Actions: "eth0-TxRx-2"},
+		{Number: 52, Actions: "eth0-TxRx-1"},
+		{Number: 53, Actions: "eth0-TxRx-0"},
+		{Number: 54, Actions: "eth0-misc"},
+	}
+	cpus := cpulists.CPUs{2: true, 3: true, 4: true, 5: true}
+
+	testCases := []struct {
+		name     string
+		irqs     []IRQInfo
+		mode     string
+		expected map[int]int
+	}{
+		{
+			name: "round-robin",
+			irqs: irqs,
+			mode: model.DistributionRoundRobin,
+			expected: map[int]int{
+				53: 2, 52: 3, 51: 4, 50: 5, 54: 2,
+			},
+		},
+		{
+			name: "spread with more IRQs than CPUs",
+			irqs: irqs,
+			mode: model.DistributionSpread,
+			expected: map[int]int{
+				53: 2, 52: 2, 51: 3, 50: 4, 54: 5,
+			},
+		},
+		{
+			name: "spread with less IRQs than CPUs",
+			irqs: irqs[2:4],
+			mode: model.DistributionSpread,
+			expected: map[int]int{
+				53: 2, 52: 4,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assignments := distributeIRQs(tc.irqs, cpus, tc.mode)
+			if len(assignments) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, assignments)
+			}
+			for irq, cpu := range tc.expected {
+				if assignments[irq] != cpu {
+					t.Errorf("IRQ %d: expected CPU %d, got %d",
+						irq, cpu, assignments[irq])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyIRQConfigDistribution(t *testing.T) {
+	config := &model.InternalConfig{
+		Data: model.Config{
+			Interrupts: model.Interrupts{
+				"eth0-queues": {
+					CPUs:         "0",
+					Filter:       model.IRQFilter{Actions: "eth0-TxRx"},
+					Distribution: model.DistributionSpread,
+				},
+			},
+		},
+	}
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			40: {Number: 40, Actions: "eth0-TxRx-1"},
+			41: {Number: 41, Actions: "eth0-TxRx-0"},
+			42: {Number: 42, Actions: "ahci"},
+		},
+	}
+
+	if err := applyIRQConfig(config, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[int]string{40: "0", 41: "0"}
+	if len(handler.WrittenAffinity) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, handler.WrittenAffinity)
+	}
+	for irq, cpus := range expected {
+		if handler.WrittenAffinity[irq] != cpus {
+			t.Errorf("IRQ %d: expected %q, got %q",
+				irq, cpus, handler.WrittenAffinity[irq])
+		}
+	}
+}
+
+// Managed IRQs don't take a CPU of the distribution
+func TestIRQAffinitiesManaged(t *testing.T) {
+	setupSysfs(t)
+
+	rule := model.IRQTuning{
+		CPUs:         "0-1",
+		Distribution: model.DistributionRoundRobin,
+	}
+	matched := []IRQInfo{
+		{Number: 40, Actions: "nvme0q0", Managed: true},
+		{Number: 41, Actions: "nvme0q1"},
+		{Number: 42, Actions: "nvme0q2"},
+	}
+
+	affinities, _, err := irqAffinities(rule, matched)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[int]string{41: "0", 42: "1"}
+	if !maps.Equal(affinities, expected) {
+		t.Fatalf("expected %v, got %v", expected, affinities)
+	}
+}
+
+// Offline CPUs are left out of the affinities of all the rules
+func TestIRQAffinitiesOfflineCPUs(t *testing.T) {
+	setupSysfs(t)
+	online := filepath.Join(cpulists.SysCPUPath(), "online")
+	if err := os.WriteFile(online, []byte("0-2\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	matched := []IRQInfo{
+		{Number: 40, Actions: "eth0-TxRx-0"},
+		{Number: 41, Actions: "eth0-TxRx-1"},
+		{Number: 42, Actions: "eth0-TxRx-2"},
+	}
+	testCases := []struct {
+		name     string
+		rule     model.IRQTuning
+		expected map[int]string
+		err      string
+	}{
+		{
+			name:     "plain",
+			rule:     model.IRQTuning{CPUs: "1-3"},
+			expected: map[int]string{40: "1-2", 41: "1-2", 42: "1-2"},
+		},
+		{
+			name:     "distributed",
+			rule:     model.IRQTuning{CPUs: "1-3", Distribution: model.DistributionRoundRobin},
+			expected: map[int]string{40: "1", 41: "2", 42: "1"},
+		},
+		{
+			name: "all offline",
+			rule: model.IRQTuning{CPUs: "3"},
+			err:  "all CPUs are offline",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			affinities, offline, err := irqAffinities(tc.rule, matched)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !maps.Equal(affinities, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, affinities)
+			}
+			if !slices.Equal(offline, []int{3}) {
+				t.Fatalf("expected offline CPUs [3], got %v", offline)
+			}
+		})
+	}
+}
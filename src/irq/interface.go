@@ -4,6 +4,9 @@ package irq
 type IRQReaderWriter interface {
 	ReadIRQs() ([]IRQInfo, error)
 	WriteCPUAffinity(irqNum int, cpus string) (success bool, managedIRQ bool, err error)
+	ReadCPUAffinity(irqNum int) (cpus string, err error)
+	ReadEffectiveAffinity(irqNum int) (cpus string, err error)
+	WriteDefaultAffinity(mask string) error
 }
 
 // IRQInfo represents information about an IRQ.
@@ -15,5 +18,11 @@ type IRQInfo struct {
 	Name     string
 	Type     string
 	Wakeup   string
+	// Managed IRQs have their affinity set by the kernel, and can't be moved
+	Managed bool
+	// PCI device of the IRQ, empty for non-PCI IRQs
+	PCIAddress string
+	Driver     string
+	Netdevs    []string
 	// PerCPuCount string // ** NOTE: Not needed for now
 }
@@ -0,0 +1,47 @@
+package irq
+
+import (
+	"sort"
+)
+
+// IRQAffinity is an active IRQ along with its current affinity
+type IRQAffinity struct {
+	IRQInfo
+	Affinity string
+	// Effective affinity, empty when not reported by the kernel
+	EffectiveAffinity string
+}
+
+// ReadIRQAffinities reads the active IRQs and their current affinities,
+// sorted by IRQ number
+func ReadIRQAffinities() ([]IRQAffinity, error) {
+	return readIRQAffinities(&realIRQReaderWriter{})
+}
+
+func readIRQAffinities(handler IRQReaderWriter) ([]IRQAffinity, error) {
+	irqs, err := handler.ReadIRQs()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(irqs, func(i, j int) bool {
+		return irqs[i].Number < irqs[j].Number
+	})
+
+	affinities := make([]IRQAffinity, 0, len(irqs))
+	for _, irq := range irqs {
+		affinity, err := handler.ReadCPUAffinity(irq.Number)
+		if err != nil {
+			return nil, err
+		}
+		effective, err := handler.ReadEffectiveAffinity(irq.Number)
+		if err != nil {
+			return nil, err
+		}
+		affinities = append(affinities, IRQAffinity{
+			IRQInfo:           irq,
+			Affinity:          affinity,
+			EffectiveAffinity: effective,
+		})
+	}
+	return affinities, nil
+}
@@ -0,0 +1,38 @@
+package irq
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadIRQAffinities(t *testing.T) {
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			41: {Number: 41, Actions: "eth0-tx-0"},
+			40: {Number: 40, Actions: "eth0-rx-0"},
+		},
+		WrittenAffinity:   map[int]string{40: "0-3", 41: "2"},
+		EffectiveAffinity: map[int]string{40: "1"},
+	}
+
+	affinities, err := readIRQAffinities(handler)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(affinities) != 2 {
+		t.Fatalf("expected 2 IRQs, got %v", affinities)
+	}
+	if affinities[0].Number != 40 || affinities[0].Affinity != "0-3" ||
+		affinities[0].EffectiveAffinity != "1" {
+		t.Errorf("unexpected IRQ 40: %+v", affinities[0])
+	}
+	if affinities[1].Number != 41 || affinities[1].Affinity != "2" ||
+		affinities[1].EffectiveAffinity != "2" {
+		t.Errorf("unexpected IRQ 41: %+v", affinities[1])
+	}
+
+	handler.Errors = map[string]error{"ReadCPUAffinity": errors.New("boom")}
+	if _, err := readIRQAffinities(handler); err == nil {
+		t.Fatalf("expected error")
+	}
+}
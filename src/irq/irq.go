@@ -1,13 +1,16 @@
 package irq
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/canonical/rt-conf/src/cpulists"
 	"github.com/canonical/rt-conf/src/debug"
@@ -49,6 +52,16 @@ var (
 	sysKernelIRQ = model.SysKernelIRQ
 )
 
+// maxIRQs returns the upper bound of the IRQ numbers, used to parse the
+// IRQ number lists of the filters
+var maxIRQs = func() (int, error) {
+	highest, err := model.GetHigherIRQ()
+	if err != nil {
+		return 0, err
+	}
+	return highest + 1, nil
+}
+
 var writeFile = func(path string, content []byte, perm os.FileMode) error {
 	return os.WriteFile(path, content, perm)
 }
@@ -63,16 +76,51 @@ func (w *realIRQReaderWriter) WriteCPUAffinity(irqNum int, cpus string) (success
 	affinityFile := fmt.Sprintf("%s/%d/smp_affinity_list", procIRQ, irqNum)
 	err = writeFile(affinityFile, []byte(cpus), 0o644)
 	if err != nil {
-		if strings.Contains(err.Error(), "input/output error") {
+		// The kernel rejects affinity changes of managed IRQs with EIO,
+		// for the ones which weren't detected up front
+		if errors.Is(err, syscall.EIO) {
 			return false, true, nil
-		} else {
-			err = fmt.Errorf("error writing to %s: %v", affinityFile, err)
-			return false, false, err
 		}
+		err = fmt.Errorf("error writing to %s: %v", affinityFile, err)
+		return false, false, err
 	}
 	return true, false, nil
 }
 
+// ReadCPUAffinity reads the CPU affinity from `/proc/irq/<irq>/smp_affinity_list`
+func (r *realIRQReaderWriter) ReadCPUAffinity(irqNum int) (string, error) {
+	affinityFile := fmt.Sprintf("%s/%d/smp_affinity_list", procIRQ, irqNum)
+	content, err := os.ReadFile(affinityFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %v", affinityFile, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// WriteDefaultAffinity writes the hex CPU mask to `/proc/irq/default_smp_affinity`
+func (w *realIRQReaderWriter) WriteDefaultAffinity(mask string) error {
+	affinityFile := fmt.Sprintf("%s/default_smp_affinity", procIRQ)
+	if err := writeFile(affinityFile, []byte(mask), 0o644); err != nil {
+		return fmt.Errorf("error writing to %s: %v", affinityFile, err)
+	}
+	return nil
+}
+
+// ReadEffectiveAffinity reads the CPUs actually handling the IRQ from
+// `/proc/irq/<irq>/effective_affinity_list`. It returns an empty string when
+// the kernel doesn't report the effective affinity.
+func (r *realIRQReaderWriter) ReadEffectiveAffinity(irqNum int) (string, error) {
+	affinityFile := fmt.Sprintf("%s/%d/effective_affinity_list", procIRQ, irqNum)
+	content, err := os.ReadFile(affinityFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %v", affinityFile, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
 func (r *realIRQReaderWriter) ReadIRQs() ([]IRQInfo, error) {
 	var irqInfos []IRQInfo
 
@@ -130,21 +178,37 @@ func (r *realIRQReaderWriter) ReadIRQs() ([]IRQInfo, error) {
 			}
 			// Only append active IRQs
 			if !nonActiveIRQ {
+				irqInfo.Managed = isManagedIRQ(irqInfo.Number)
 				irqInfos = append(irqInfos, irqInfo)
 			}
 		}
 	}
-	return irqInfos, err
+
+	devices, err := readPCIDevices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PCI devices: %v", err)
+	}
+	for i := range irqInfos {
+		if dev, ok := devices[irqInfos[i].Number]; ok {
+			irqInfos[i].PCIAddress = dev.Address
+			irqInfos[i].Driver = dev.Driver
+			irqInfos[i].Netdevs = dev.Netdevs
+		}
+	}
+	return irqInfos, nil
 }
 
 func ApplyIRQConfig(config *model.InternalConfig) error {
 	utils.PrintTitle("IRQ Tuning")
+	handler := &realIRQReaderWriter{}
 	if len(config.Data.Interrupts) == 0 {
-		// If no IRQ tuning is specified, skip the process
 		log.Println("No IRQ tuning rules found in config")
-		return nil
+	} else if err := applyIRQConfig(config, handler); err != nil {
+		return err
 	}
-	return applyIRQConfig(config, &realIRQReaderWriter{})
+	// The isolated CPUs are banned from balancing even without IRQ tuning
+	// rules
+	return handleIrqbalance(config, handler)
 }
 
 // Apply changes based on YAML config
@@ -161,65 +225,231 @@ func applyIRQConfig(
 		return fmt.Errorf("no IRQs found")
 	}
 
-	// Range over IRQ tuning array
-	for label, irqTuning := range config.Data.Interrupts {
+	// Rules matching each IRQ, in the order they were applied
+	matchedBy := make(map[int][]string)
+	// Managed IRQs matched by any rule
+	managed := make(IRQs)
+
+	// Range over IRQ tuning rules, the highest priority last
+	for _, label := range config.Data.Interrupts.Labels() {
+		irqTuning := config.Data.Interrupts[label]
 		log.Printf("Rule: %s\n", label)
 
-		matchingIRQs, err := filterIRQs(irqs, irqTuning.Filter)
+		matched, err := matchingIRQInfos(irqs, irqTuning)
 		if err != nil {
 			return fmt.Errorf("failed to filter IRQs: %v", err)
 		}
+		for _, irq := range matched {
+			matchedBy[irq.Number] = append(matchedBy[irq.Number], label)
+		}
 
-		if len(matchingIRQs) == 0 {
+		if len(matched) == 0 {
 			log.Println("WARN: no IRQs matched the filter")
 			// TODO: confirm if it should fail when nothing is matched
 			return fmt.Errorf("no IRQs matched the filter: %v",
-				irqTuning.Filter)
+				irqTuning.AllFilters())
+		}
+
+		affinities, offline, err := irqAffinities(irqTuning, matched)
+		if err != nil {
+			return fmt.Errorf("irq tuning rule #%s: %v", label, err)
+		}
+		cpulists.LogOffline(offline)
+
+		if irqTuning.Distribution != "" {
+			managedIRQs, err := distributeRule(matched, affinities, handler)
+			if err != nil {
+				return err
+			}
+			for _, irqNum := range managedIRQs {
+				managed[irqNum] = true
+			}
+			continue
+		}
+
+		// All the IRQs are written the online CPUs of the rule
+		affinity := affinities[matched[0].Number]
+		cpus, err := cpulists.Parse(affinity)
+		if err != nil {
+			return err
 		}
 
 		// cleanup managed IRQs map
 		managedIRQs := make([]int, 0, len(irqs))
 		setIRQs := make([]int, 0, len(irqs))
-		for irqNum := range matchingIRQs {
-			success, managedIRQ, err := handler.WriteCPUAffinity(irqNum, irqTuning.CPUs)
+		for _, irq := range matched {
+			if irq.Managed {
+				managedIRQs = append(managedIRQs, irq.Number)
+				continue
+			}
+			success, managedIRQ, err := handler.WriteCPUAffinity(irq.Number, affinity)
 			if err != nil {
 				return err
 			}
 			if managedIRQ {
-				managedIRQs = append(managedIRQs, irqNum)
+				managedIRQs = append(managedIRQs, irq.Number)
 			}
 			if success {
-				setIRQs = append(setIRQs, irqNum)
+				setIRQs = append(setIRQs, irq.Number)
 			}
 		}
+		for _, irqNum := range managedIRQs {
+			managed[irqNum] = true
+		}
 
-		cpus, err := cpulists.Parse(irqTuning.CPUs)
+		effective, err := effectiveAffinityMsgs(setIRQs, cpus, handler)
 		if err != nil {
 			return err
 		}
-		logChanges(setIRQs, managedIRQs, cpus, irqTuning.CPUs)
+		logChanges(setIRQs, managedIRQs, cpus, affinity, effective)
 	}
-	return nil
-}
 
-// filterIRQs filters IRQs based on the provided filters (matches any filter).
-func filterIRQs(irqs []IRQInfo, filter model.IRQFilter) (IRQs, error) {
-	matchingIRQs := make(IRQs)
+	logOverlaps(matchedBy)
+
+	managedList := make([]int, 0, len(managed))
+	for irqNum := range managed {
+		managedList = append(managedList, irqNum)
+	}
+	sort.Ints(managedList)
+	return managedIRQAdvice(config, managedList, handler)
+}
 
-	for _, irq := range irqs {
-		if matchesAnyFilter(irq, filter) {
-			matchingIRQs[irq.Number] = true
+// logOverlaps reports the IRQs matched by more than one rule, along with
+// the rule which was applied last and won
+func logOverlaps(matchedBy map[int][]string) {
+	irqNums := make([]int, 0, len(matchedBy))
+	for irqNum, labels := range matchedBy {
+		if len(labels) > 1 {
+			irqNums = append(irqNums, irqNum)
 		}
 	}
+	if len(irqNums) == 0 {
+		return
+	}
+	sort.Ints(irqNums)
+
+	msgs := make([]string, 0, len(irqNums))
+	for _, irqNum := range irqNums {
+		labels := matchedBy[irqNum]
+		msgs = append(msgs, fmt.Sprintf("IRQ %d matched by rules #%s, #%s won",
+			irqNum, strings.Join(labels, ", #"), labels[len(labels)-1]))
+	}
+	log.Println("WARNING: overlapping IRQ tuning rules")
+	utils.LogTreeStyle(msgs)
+}
+
+// filterIRQs filters IRQs based on the filters of a rule (matches any filter).
+func filterIRQs(irqs []IRQInfo, rule model.IRQTuning) (IRQs, error) {
+	matched, err := matchingIRQInfos(irqs, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	matchingIRQs := make(IRQs, len(matched))
+	for _, irq := range matched {
+		matchingIRQs[irq.Number] = true
+	}
 	return matchingIRQs, nil
 }
 
-// matchesAnyFilter checks if an IRQ matches any of the given filters.
-func matchesAnyFilter(irq IRQInfo, filter model.IRQFilter) bool {
-	return matchesRegex(irq.Actions, filter.Actions) &&
-		matchesRegex(irq.ChipName, filter.ChipName) &&
-		matchesRegex(irq.Name, filter.Name) &&
-		matchesRegex(irq.Type, filter.Type)
+// irqMatcher matches IRQs against the filters of a rule, with the IRQ
+// number lists parsed once instead of once per IRQ
+type irqMatcher struct {
+	filters []parsedFilter
+}
+
+type parsedFilter struct {
+	model.IRQFilter
+	// IRQ numbers of the filter, nil if any number matches
+	numbers IRQs
+	exclude []parsedFilter
+}
+
+func newIRQMatcher(rule model.IRQTuning) (irqMatcher, error) {
+	// The upper bound of the IRQ numbers is only read if needed
+	total := -1
+	var parse func(filter model.IRQFilter) (parsedFilter, error)
+	parse = func(filter model.IRQFilter) (parsedFilter, error) {
+		parsed := parsedFilter{IRQFilter: filter}
+		if filter.Numbers != "" {
+			if total == -1 {
+				var err error
+				if total, err = maxIRQs(); err != nil {
+					return parsedFilter{}, fmt.Errorf(
+						"failed to get the number of IRQs: %v", err)
+				}
+			}
+			numbers, err := cpulists.ParseForCPUs(filter.Numbers, total)
+			if err != nil {
+				return parsedFilter{}, fmt.Errorf("invalid IRQ numbers %q: %v",
+					filter.Numbers, err)
+			}
+			parsed.numbers = IRQs(numbers)
+		}
+		for _, exclude := range filter.Exclude {
+			parsedExclude, err := parse(exclude)
+			if err != nil {
+				return parsedFilter{}, err
+			}
+			parsed.exclude = append(parsed.exclude, parsedExclude)
+		}
+		return parsed, nil
+	}
+
+	var m irqMatcher
+	for _, filter := range rule.AllFilters() {
+		parsed, err := parse(filter)
+		if err != nil {
+			return irqMatcher{}, err
+		}
+		m.filters = append(m.filters, parsed)
+	}
+	return m, nil
+}
+
+// matches checks if an IRQ matches any of the filters of the rule.
+func (m irqMatcher) matches(irq IRQInfo) bool {
+	for _, filter := range m.filters {
+		if filter.matches(irq) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches checks if an IRQ matches all the fields of the filter and none
+// of its exclude filters.
+func (f parsedFilter) matches(irq IRQInfo) bool {
+	matches := matchesRegex(irq.Actions, f.Actions) &&
+		matchesRegex(irq.ChipName, f.ChipName) &&
+		matchesRegex(irq.Name, f.Name) &&
+		matchesRegex(irq.Type, f.Type) &&
+		(f.numbers == nil || f.numbers[irq.Number]) &&
+		matchesRegex(irq.PCIAddress, f.PCIAddress) &&
+		matchesRegex(irq.Driver, f.Driver) &&
+		matchesAnyRegex(irq.Netdevs, f.Netdev)
+	if !matches {
+		return false
+	}
+	for _, exclude := range f.exclude {
+		if exclude.matches(irq) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAnyRegex checks if any of the values matches a regex pattern.
+func matchesAnyRegex(values []string, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	for _, value := range values {
+		if matchesRegex(value, pattern) {
+			return true
+		}
+	}
+	return false
 }
 
 // matchesRegex checks if a field matches a regex pattern.
@@ -231,7 +461,7 @@ func matchesRegex(value, pattern string) bool {
 	return err == nil && match
 }
 
-func logChanges(changed, managed []int, cpuList cpulists.CPUs, cpus string) {
+func logChanges(changed, managed []int, cpuList cpulists.CPUs, cpus string, effective []string) {
 	pluralSuffix := "s"
 	if len(cpuList) == 1 {
 		pluralSuffix = ""
@@ -247,5 +477,6 @@ func logChanges(changed, managed []int, cpuList cpulists.CPUs, cpus string) {
 		msgs = append(msgs, fmt.Sprintf("Ignored managed IRQs: %s",
 			cpulists.GenCPUlist(managed)))
 	}
+	msgs = append(msgs, effective...)
 	utils.LogTreeStyle(msgs)
 }
@@ -1,11 +1,14 @@
 package irq
 
 import (
+	"bytes"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/canonical/rt-conf/src/model"
@@ -15,7 +18,13 @@ import (
 type mockIRQReaderWriter struct {
 	IRQs            map[uint]IRQInfo
 	WrittenAffinity map[int]string
-	Errors          map[string]error
+	// Effective affinity of the IRQs, the written one if not set
+	EffectiveAffinity map[int]string
+	DefaultAffinity   string
+	Errors            map[string]error
+	// IRQs whose affinity writes are rejected, as for managed IRQs which
+	// aren't reported by debugfs
+	RejectedIRQs map[int]bool
 }
 
 func (m *mockIRQReaderWriter) ReadIRQs() ([]IRQInfo, error) {
@@ -35,6 +44,9 @@ func (m *mockIRQReaderWriter) WriteCPUAffinity(irqNum int, cpus string) (
 	if err, ok := m.Errors["WriteCPUAffinity"]; ok {
 		return false, false, err
 	}
+	if m.RejectedIRQs[irqNum] {
+		return false, true, nil
+	}
 	if m.WrittenAffinity == nil {
 		m.WrittenAffinity = make(map[int]string)
 	}
@@ -44,6 +56,31 @@ func (m *mockIRQReaderWriter) WriteCPUAffinity(irqNum int, cpus string) (
 	return true, false, nil
 }
 
+func (m *mockIRQReaderWriter) ReadCPUAffinity(irqNum int) (string, error) {
+	if err, ok := m.Errors["ReadCPUAffinity"]; ok {
+		return "", err
+	}
+	return m.WrittenAffinity[irqNum], nil
+}
+
+func (m *mockIRQReaderWriter) ReadEffectiveAffinity(irqNum int) (string, error) {
+	if err, ok := m.Errors["ReadEffectiveAffinity"]; ok {
+		return "", err
+	}
+	if affinity, ok := m.EffectiveAffinity[irqNum]; ok {
+		return affinity, nil
+	}
+	return m.WrittenAffinity[irqNum], nil
+}
+
+func (m *mockIRQReaderWriter) WriteDefaultAffinity(mask string) error {
+	if err, ok := m.Errors["WriteDefaultAffinity"]; ok {
+		return err
+	}
+	m.DefaultAffinity = mask
+	return nil
+}
+
 type IRQTestCase struct {
 	Yaml    string
 	Handler IRQReaderWriter
@@ -209,15 +246,31 @@ func TestWriteCPUAffinityFileNotFound(t *testing.T) {
 }
 
 func TestWriteCPUAffinityInputOutputErrorIgnored(t *testing.T) {
+	prev := writeFile
+	t.Cleanup(func() { writeFile = prev })
+
 	writer := &realIRQReaderWriter{}
-	writeFile = func(_ string, _ []byte, _ os.FileMode) error {
-		return fmt.Errorf("input/output error") // Simulated /proc error
+	writeFile = func(path string, _ []byte, _ os.FileMode) error {
+		// Simulated /proc error
+		return &os.PathError{Op: "write", Path: path, Err: syscall.EIO}
 	}
 
-	_, _, err := writer.WriteCPUAffinity(1, "0")
+	_, managed, err := writer.WriteCPUAffinity(1, "0")
 	if err != nil {
 		t.Fatalf("expected nil, got error: %v", err)
 	}
+	if !managed {
+		t.Fatalf("expected the IRQ to be reported as managed")
+	}
+
+	// Only the EIO errno means a managed IRQ, not the error message
+	writeFile = func(_ string, _ []byte, _ os.FileMode) error {
+		return fmt.Errorf("input/output error")
+	}
+	_, _, err = writer.WriteCPUAffinity(1, "0")
+	if err == nil {
+		t.Fatalf("expected an error, got nil")
+	}
 }
 
 // Sanity: return nil even if file already has the value
@@ -372,3 +425,183 @@ func TestApplyIRQConfig(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestMatchesNumbers(t *testing.T) {
+	prev := maxIRQs
+	maxIRQs = func() (int, error) { return 64, nil }
+	t.Cleanup(func() { maxIRQs = prev })
+
+	irqs := []IRQInfo{
+		{Number: 9, Actions: "acpi"},
+		{Number: 24, Actions: "eth0"},
+		{Number: 31, Actions: "eth1"},
+		{Number: 40},
+		{Number: 41},
+	}
+
+	testCases := []struct {
+		name     string
+		filter   model.IRQFilter
+		expected []int
+	}{
+		{
+			name:     "numbers only",
+			filter:   model.IRQFilter{Numbers: "24-31,40"},
+			expected: []int{24, 31, 40},
+		},
+		{
+			name:     "numbers and actions",
+			filter:   model.IRQFilter{Numbers: "24-31,40", Actions: "eth1"},
+			expected: []int{31},
+		},
+		{
+			name:     "last IRQ",
+			filter:   model.IRQFilter{Numbers: "63"},
+			expected: []int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matched, err := filterIRQs(irqs, model.IRQTuning{Filter: tc.filter})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(matched) != len(tc.expected) {
+				t.Fatalf("expected IRQs %v, got %v", tc.expected, matched)
+			}
+			for _, irq := range tc.expected {
+				if !matched[irq] {
+					t.Errorf("expected IRQ %d to match, got %v", irq, matched)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchesNumbersInvalid(t *testing.T) {
+	prev := maxIRQs
+	maxIRQs = func() (int, error) { return 64, nil }
+	t.Cleanup(func() { maxIRQs = prev })
+
+	irqs := []IRQInfo{{Number: 9, Actions: "acpi"}}
+	rule := model.IRQTuning{
+		Filter: model.IRQFilter{
+			Actions: "acpi",
+			Exclude: []model.IRQFilter{{Numbers: "100"}},
+		},
+	}
+	_, err := filterIRQs(irqs, rule)
+	if err == nil || !strings.Contains(err.Error(), `invalid IRQ numbers "100"`) {
+		t.Fatalf("expected invalid IRQ numbers error, got: %v", err)
+	}
+
+	maxIRQs = func() (int, error) { return 0, fmt.Errorf("no interrupts") }
+	_, err = filterIRQs(irqs, model.IRQTuning{Filter: model.IRQFilter{Numbers: "1"}})
+	if err == nil || !strings.Contains(err.Error(), "no interrupts") {
+		t.Fatalf("expected error reading the number of IRQs, got: %v", err)
+	}
+}
+
+func TestMatchesAnyFilter(t *testing.T) {
+	irqs := []IRQInfo{
+		{Number: 30, ChipName: "IR-PCI-MSIX", Actions: "nvme0q1"},
+		{Number: 31, ChipName: "IR-PCI-MSIX", Actions: "eth0-rx-0"},
+		{Number: 32, ChipName: "IR-PCI-MSI", Actions: "xhci_hcd"},
+		{Number: 33, ChipName: "IR-IO-APIC", Actions: "i8042"},
+	}
+
+	testCases := []struct {
+		name     string
+		rule     model.IRQTuning
+		expected []int
+	}{
+		{
+			name: "exclude",
+			rule: model.IRQTuning{Filter: model.IRQFilter{
+				ChipName: "MSI",
+				Exclude:  []model.IRQFilter{{Actions: "nvme"}},
+			}},
+			expected: []int{31, 32},
+		},
+		{
+			name: "alternative filters",
+			rule: model.IRQTuning{Filters: []model.IRQFilter{
+				{Actions: "nvme"},
+				{ChipName: "APIC"},
+			}},
+			expected: []int{30, 33},
+		},
+		{
+			name: "filter and alternative filters",
+			rule: model.IRQTuning{
+				Filter:  model.IRQFilter{Actions: "xhci"},
+				Filters: []model.IRQFilter{{Actions: "i8042"}},
+			},
+			expected: []int{32, 33},
+		},
+		{
+			name: "alternative filters with exclude",
+			rule: model.IRQTuning{Filters: []model.IRQFilter{
+				{
+					ChipName: "MSIX",
+					Exclude: []model.IRQFilter{
+						{Actions: "nvme"},
+						{Actions: "rx"},
+					},
+				},
+				{Actions: "i8042"},
+			}},
+			expected: []int{33},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matched, err := filterIRQs(irqs, tc.rule)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(matched) != len(tc.expected) {
+				t.Fatalf("expected IRQs %v, got %v", tc.expected, matched)
+			}
+			for _, irq := range tc.expected {
+				if !matched[irq] {
+					t.Errorf("expected IRQ %d to match, got %v", irq, matched)
+				}
+			}
+		})
+	}
+}
+
+// The CPU list written to the IRQs is logged, not the one of the rule
+func TestApplyIRQConfigLogsWrittenCPUs(t *testing.T) {
+	setupSysfs(t)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	config := &model.InternalConfig{
+		Data: model.Config{
+			Interrupts: model.Interrupts{
+				"network": {CPUs: "all", Filter: model.IRQFilter{Actions: "eth0"}},
+			},
+		},
+	}
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			40: {Number: 40, Actions: "eth0-TxRx-0"},
+		},
+	}
+
+	if err := applyIRQConfig(config, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := handler.WrittenAffinity[40]; got != "0-3" {
+		t.Fatalf("expected affinity %q, got %q", "0-3", got)
+	}
+	if !strings.Contains(buf.String(), "Assigned IRQs 40 to CPUs 0-3") {
+		t.Fatalf("expected the written CPUs to be logged, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,242 @@
+package irq
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// irqbalance periodically rewrites the IRQ affinities, undoing the ones set
+// by rt-conf, unless the CPUs and IRQs are banned from balancing.
+// See: https://github.com/Irqbalance/irqbalance
+
+var procPath = "/proc"
+
+// irqbalanceRunning returns true if an irqbalance process is running
+func irqbalanceRunning() (bool, error) {
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		comm, err := os.ReadFile(filepath.Join(procPath, entry.Name(), "comm"))
+		if err != nil {
+			continue // Not a process, or it exited meanwhile
+		}
+		if strings.TrimSpace(string(comm)) == "irqbalance" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// bannedCPUs returns the CPUs which irqbalance must not move IRQs to:
+// the CPUs isolated or in full dynamic ticks mode in the kernel command line
+// config and in the running kernel
+func bannedCPUs(cfg model.Config) ([]int, error) {
+	banned := make(cpulists.CPUs)
+	for _, p := range cfg.KernelCmdline.Parameters {
+		key, value, found := strings.Cut(p, "=")
+		if !found {
+			continue
+		}
+		var cpus cpulists.CPUs
+		var err error
+		switch key {
+		case "isolcpus":
+			cpus, _, err = cpulists.ParseWithFlags(value,
+				[]string{"domain", "nohz", "managed_irq"})
+		case "nohz_full":
+			cpus, err = cpulists.Parse(value)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+		for cpu := range cpus {
+			banned[cpu] = true
+		}
+	}
+
+	// Kernels which don't report the CPU sets, e.g. in containers, leave
+	// only the CPUs set in the config
+	if sets, err := cpulists.ReadCPUSets(); err == nil {
+		for cpu := range sets.Isolated {
+			banned[cpu] = true
+		}
+		for cpu := range sets.NohzFull {
+			banned[cpu] = true
+		}
+	}
+
+	list := make([]int, 0, len(banned))
+	for cpu := range banned {
+		list = append(list, cpu)
+	}
+	sort.Ints(list)
+	return list, nil
+}
+
+// GenIrqbalanceConfig generates the irqbalance environment settings which
+// ban the given CPUs and IRQs from balancing
+func GenIrqbalanceConfig(cpus []int, irqs []int) string {
+	return UpdateIrqbalanceConfig("", cpus, irqs)
+}
+
+// UpdateIrqbalanceConfig updates the content of an irqbalance environment
+// file, e.g. /etc/default/irqbalance, to ban the given CPUs and IRQs from
+// balancing. Only the banned CPUs and the --banirq options are replaced,
+// the other settings are kept.
+func UpdateIrqbalanceConfig(content string, cpus []int, irqs []int) string {
+	sorted := append([]int(nil), irqs...)
+	sort.Ints(sorted)
+	var args []string
+	for _, irq := range sorted {
+		args = append(args, fmt.Sprintf("--banirq=%d", irq))
+	}
+
+	// Without banned CPUs, irqbalance bans the isolated and the full
+	// dynamic ticks CPUs of the running kernel
+	var cpuListLine string
+	if len(cpus) > 0 {
+		cpuListLine = "IRQBALANCE_BANNED_CPULIST=" + cpulists.GenCPUlist(cpus)
+	}
+
+	var existing, lines []string
+	if content != "" {
+		existing = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	argsSet := false
+	for _, line := range existing {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "IRQBALANCE_BANNED_CPULIST", "IRQBALANCE_BANNED_CPUS":
+			// The deprecated banned CPUs mask is replaced by the list
+			if cpuListLine != "" {
+				lines = append(lines, cpuListLine)
+				cpuListLine = ""
+			}
+		case "IRQBALANCE_ARGS":
+			if argsSet {
+				continue
+			}
+			var kept []string
+			for _, arg := range strings.Fields(strings.Trim(value, `"'`)) {
+				if !strings.HasPrefix(arg, "--banirq=") {
+					kept = append(kept, arg)
+				}
+			}
+			lines = append(lines, fmt.Sprintf("IRQBALANCE_ARGS=\"%s\"",
+				strings.Join(append(kept, args...), " ")))
+			argsSet = true
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if cpuListLine != "" {
+		lines = append(lines, cpuListLine)
+	}
+	if !argsSet {
+		lines = append(lines, fmt.Sprintf("IRQBALANCE_ARGS=\"%s\"",
+			strings.Join(args, " ")))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// matchedIRQs returns the IRQs matched by any of the IRQ tuning rules
+func matchedIRQs(irqs []IRQInfo, rules model.Interrupts) ([]int, error) {
+	matchers := make([]irqMatcher, 0, len(rules))
+	for _, label := range rules.Labels() {
+		m, err := newIRQMatcher(rules[label])
+		if err != nil {
+			return nil, fmt.Errorf("irq tuning rule #%s: %v", label, err)
+		}
+		matchers = append(matchers, m)
+	}
+
+	var matched []int
+	for _, irq := range irqs {
+		for _, m := range matchers {
+			if m.matches(irq) {
+				matched = append(matched, irq.Number)
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+// handleIrqbalance updates the irqbalance configuration file if one is set,
+// otherwise it warns about a running irqbalance
+func handleIrqbalance(config *model.InternalConfig, handler IRQReaderWriter) error {
+	running, err := irqbalanceRunning()
+	if err != nil {
+		return fmt.Errorf("failed to detect irqbalance: %v", err)
+	}
+	if !running && config.IrqbalanceCfgFile == "" {
+		return nil
+	}
+
+	var irqs []int
+	if len(config.Data.Interrupts) > 0 {
+		infos, err := handler.ReadIRQs()
+		if err != nil {
+			return err
+		}
+		irqs, err = matchedIRQs(infos, config.Data.Interrupts)
+		if err != nil {
+			return err
+		}
+	}
+	cpus, err := bannedCPUs(config.Data)
+	if err != nil {
+		return err
+	}
+	if len(cpus) == 0 && len(irqs) == 0 {
+		return nil // Nothing to ban from balancing
+	}
+
+	if config.IrqbalanceCfgFile != "" {
+		existing, err := os.ReadFile(config.IrqbalanceCfgFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read %s file: %v",
+				config.IrqbalanceCfgFile, err)
+		}
+		content := UpdateIrqbalanceConfig(string(existing), cpus, irqs)
+		if err := os.WriteFile(config.IrqbalanceCfgFile, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("failed to write to %s file: %v",
+				config.IrqbalanceCfgFile, err)
+		}
+		log.Printf("Updated irqbalance configuration file: %s\n",
+			config.IrqbalanceCfgFile)
+		if running {
+			utils.LogTreeStyle([]string{
+				"irqbalance is running, restart it to apply the changes:",
+				"sudo systemctl restart irqbalance",
+			})
+		}
+		return nil
+	}
+
+	content := GenIrqbalanceConfig(cpus, irqs)
+	log.Println("WARNING: irqbalance is running and may override the IRQ affinities set by rt-conf")
+	msgs := []string{
+		"Either stop it: sudo systemctl disable --now irqbalance",
+		"Or ban the CPUs and IRQs from balancing in /etc/default/irqbalance,",
+		"e.g. by setting --irqbalance-file=/etc/default/irqbalance:",
+	}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		msgs = append(msgs, "\t"+line)
+	}
+	utils.LogTreeStyle(msgs)
+	return nil
+}
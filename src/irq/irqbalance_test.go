@@ -0,0 +1,191 @@
+package irq
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+)
+
+// setupProcDir creates a fake /proc with one process per given command name
+func setupProcDir(t *testing.T, comms ...string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	for i, comm := range comms {
+		dir := filepath.Join(tmpDir, strings.Repeat("1", i+1))
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "comm"),
+			[]byte(comm+"\n"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	prev := procPath
+	procPath = tmpDir
+	t.Cleanup(func() { procPath = prev })
+}
+
+func TestIrqbalanceRunning(t *testing.T) {
+	setupProcDir(t, "systemd", "irqbalance")
+	running, err := irqbalanceRunning()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !running {
+		t.Fatalf("expected irqbalance to be detected")
+	}
+
+	setupProcDir(t, "systemd", "bash")
+	running, err = irqbalanceRunning()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if running {
+		t.Fatalf("expected irqbalance not to be detected")
+	}
+}
+
+func TestGenIrqbalanceConfig(t *testing.T) {
+	content := GenIrqbalanceConfig([]int{2, 3, 5}, []int{40, 33})
+
+	expected := []string{
+		"IRQBALANCE_BANNED_CPULIST=2-3,5",
+		`IRQBALANCE_ARGS="--banirq=33 --banirq=40"`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(content, line+"\n") {
+			t.Errorf("expected line %q, got:\n%s", line, content)
+		}
+	}
+}
+
+func TestUpdateIrqbalanceConfig(t *testing.T) {
+	existing := "# irqbalance is a daemon process that distributes interrupts\n" +
+		"#IRQBALANCE_ONESHOT=\n" +
+		"IRQBALANCE_BANNED_CPUS=0000000f\n" +
+		"IRQBALANCE_ARGS=\"--policyscript=/etc/irq.sh --banirq=7\"\n"
+
+	content := UpdateIrqbalanceConfig(existing, []int{2, 3}, []int{40, 33})
+	expected := "# irqbalance is a daemon process that distributes interrupts\n" +
+		"#IRQBALANCE_ONESHOT=\n" +
+		"IRQBALANCE_BANNED_CPULIST=2-3\n" +
+		"IRQBALANCE_ARGS=\"--policyscript=/etc/irq.sh --banirq=33 --banirq=40\"\n"
+	if content != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	// Updating again doesn't change anything
+	if again := UpdateIrqbalanceConfig(content, []int{2, 3}, []int{33, 40}); again != content {
+		t.Fatalf("expected:\n%s\ngot:\n%s", content, again)
+	}
+
+	// Settings missing from the file are appended
+	content = UpdateIrqbalanceConfig("#IRQBALANCE_ONESHOT=\n", []int{1}, nil)
+	expected = "#IRQBALANCE_ONESHOT=\n" +
+		"IRQBALANCE_BANNED_CPULIST=1\n" +
+		"IRQBALANCE_ARGS=\"\"\n"
+	if content != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
+
+// setupSysfs points cpulists to a fake sysfs root with 4 online CPUs, none
+// of them isolated in the running kernel
+func setupSysfs(t *testing.T) {
+	t.Helper()
+
+	root := t.TempDir()
+	t.Cleanup(cpulists.SetSysfsRoot(root))
+	cpuDir := filepath.Join(root, "devices", "system", "cpu")
+	if err := os.MkdirAll(cpuDir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	for _, name := range []string{"possible", "present", "online"} {
+		if err := os.WriteFile(filepath.Join(cpuDir, name), []byte("0-3\n"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+}
+
+func TestHandleIrqbalanceWritesFile(t *testing.T) {
+	setupProcDir(t, "irqbalance")
+	setupSysfs(t)
+
+	cfgFile := filepath.Join(t.TempDir(), "irqbalance")
+	config := &model.InternalConfig{
+		Data: model.Config{
+			KernelCmdline: model.KernelCmdline{
+				Parameters: []string{"isolcpus=managed_irq,0"},
+			},
+			Interrupts: model.Interrupts{
+				"network": {CPUs: "0", Filter: model.IRQFilter{Actions: "eth"}},
+			},
+		},
+		IrqbalanceCfgFile: cfgFile,
+	}
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			10: {Number: 10, Actions: "eth0"},
+			11: {Number: 11, Actions: "nvme0q0"},
+		},
+	}
+
+	if err := handleIrqbalance(config, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(cfgFile)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	for _, line := range []string{
+		"IRQBALANCE_BANNED_CPULIST=0",
+		`IRQBALANCE_ARGS="--banirq=10"`,
+	} {
+		if !strings.Contains(string(content), line) {
+			t.Errorf("expected line %q, got:\n%s", line, string(content))
+		}
+	}
+}
+
+// The isolated CPUs are banned from balancing without IRQ tuning rules too,
+// keeping the other settings of the file
+func TestHandleIrqbalanceWithoutRules(t *testing.T) {
+	setupProcDir(t, "systemd")
+	setupSysfs(t)
+
+	cfgFile := filepath.Join(t.TempDir(), "irqbalance")
+	if err := os.WriteFile(cfgFile, []byte("IRQBALANCE_ONESHOT=yes\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	config := &model.InternalConfig{
+		Data: model.Config{
+			KernelCmdline: model.KernelCmdline{
+				Parameters: []string{"nohz_full=2-3"},
+			},
+		},
+		IrqbalanceCfgFile: cfgFile,
+	}
+
+	// The IRQs are not read without IRQ tuning rules
+	if err := handleIrqbalance(config, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(cfgFile)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	expected := "IRQBALANCE_ONESHOT=yes\n" +
+		"IRQBALANCE_BANNED_CPULIST=2-3\n" +
+		"IRQBALANCE_ARGS=\"\"\n"
+	if string(content) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
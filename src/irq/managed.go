@@ -0,0 +1,130 @@
+package irq
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Managed IRQs, e.g. the queue IRQs of NVMe drives, have their affinity
+// spread over the CPUs by the kernel and can't be moved from userspace.
+// The isolcpus=managed_irq kernel parameter keeps them off the isolated
+// CPUs, as long as a housekeeping CPU is in their affinity.
+// See: https://docs.kernel.org/admin-guide/kernel-parameters.html (isolcpus)
+
+// The IRQ state, including the managed flag, is exposed in debugfs when the
+// kernel is built with CONFIG_GENERIC_IRQ_DEBUGFS
+var debugIRQ = "/sys/kernel/debug/irq/irqs"
+
+// isManagedIRQ returns true if debugfs reports the IRQ as managed. It
+// returns false when debugfs isn't available, in which case managed IRQs
+// are detected when the kernel rejects their affinity with EIO, see
+// WriteCPUAffinity.
+func isManagedIRQ(irqNum int) bool {
+	content, err := os.ReadFile(filepath.Join(debugIRQ, strconv.Itoa(irqNum)))
+	if err != nil {
+		return false
+	}
+	return slices.Contains(strings.Fields(string(content)), "IRQD_AFFINITY_MANAGED")
+}
+
+// effectiveAffinityMsgs reports the IRQs for which the kernel picked a
+// subset of the requested CPUs
+func effectiveAffinityMsgs(irqNums []int, cpus cpulists.CPUs, handler IRQReaderWriter) ([]string, error) {
+	requested := canonicalCPUList(cpus)
+
+	var msgs []string
+	for _, irqNum := range irqNums {
+		effective, err := handler.ReadEffectiveAffinity(irqNum)
+		if err != nil {
+			return nil, err
+		}
+		if effective == "" || effective == requested {
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("IRQ %d is effectively handled on CPUs %s",
+			irqNum, effective))
+	}
+	return msgs, nil
+}
+
+// managedIRQAdvice warns about the managed IRQs handled on isolated CPUs
+// and suggests the kernel parameter which keeps them off
+func managedIRQAdvice(config *model.InternalConfig, managed []int, handler IRQReaderWriter) error {
+	if len(managed) == 0 {
+		return nil
+	}
+	for _, p := range config.Data.KernelCmdline.Parameters {
+		if strings.HasPrefix(p, "isolcpus=managed_irq,") {
+			return nil
+		}
+	}
+
+	isolated, err := bannedCPUs(config.Data)
+	if err != nil {
+		return err
+	}
+	if len(isolated) == 0 {
+		return nil
+	}
+
+	var onIsolated []int
+	for _, irqNum := range managed {
+		affinity, err := handler.ReadEffectiveAffinity(irqNum)
+		if err != nil {
+			return err
+		}
+		if affinity == "" {
+			affinity, err = handler.ReadCPUAffinity(irqNum)
+			if err != nil {
+				return err
+			}
+		}
+		cpus, err := cpulists.ParseForCPUs(affinity, maxCPUInList(affinity)+1)
+		if err != nil {
+			return fmt.Errorf("invalid affinity of IRQ %d: %v", irqNum, err)
+		}
+		for _, cpu := range isolated {
+			if cpus[cpu] {
+				onIsolated = append(onIsolated, irqNum)
+				break
+			}
+		}
+	}
+	if len(onIsolated) == 0 {
+		return nil
+	}
+
+	isolatedList := cpulists.GenCPUlist(isolated)
+	log.Printf("WARNING: managed IRQs %s are handled on isolated CPUs %s\n",
+		cpulists.GenCPUlist(onIsolated), isolatedList)
+	utils.LogTreeStyle([]string{
+		"Their affinity is set by the kernel and can't be changed at runtime",
+		fmt.Sprintf("To keep them off the isolated CPUs, set isolcpus=managed_irq,%s",
+			isolatedList),
+		"in the kernel-cmdline parameters",
+	})
+	return nil
+}
+
+// maxCPUInList returns the highest number in a canonical CPU list,
+// as read from procfs
+func maxCPUInList(list string) int {
+	highest := 0
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '-'
+	}) {
+		if num, err := strconv.Atoi(item); err == nil && num > highest {
+			highest = num
+		}
+	}
+	return highest
+}
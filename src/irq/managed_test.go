@@ -0,0 +1,152 @@
+package irq
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+)
+
+func TestIsManagedIRQ(t *testing.T) {
+	tmpDir := t.TempDir()
+	prev := debugIRQ
+	debugIRQ = tmpDir
+	t.Cleanup(func() { debugIRQ = prev })
+
+	files := map[string]string{
+		"40": "handler:  handle_edge_irq\ndstate:   0x3740200\n" +
+			"            IRQD_ACTIVATED\n            IRQD_AFFINITY_MANAGED\n",
+		"41": "handler:  handle_edge_irq\ndstate:   0x3400200\n" +
+			"            IRQD_ACTIVATED\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name),
+			[]byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	if !isManagedIRQ(40) {
+		t.Errorf("expected IRQ 40 to be managed")
+	}
+	if isManagedIRQ(41) {
+		t.Errorf("expected IRQ 41 not to be managed")
+	}
+	// No debugfs entry
+	if isManagedIRQ(42) {
+		t.Errorf("expected IRQ 42 not to be managed")
+	}
+}
+
+func TestReadEffectiveAffinity(t *testing.T) {
+	tmpDir := t.TempDir()
+	prev := procIRQ
+	procIRQ = tmpDir
+	t.Cleanup(func() { procIRQ = prev })
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "40"), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "40", "effective_affinity_list"),
+		[]byte("2\n"), 0o444); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	r := &realIRQReaderWriter{}
+	affinity, err := r.ReadEffectiveAffinity(40)
+	if err != nil || affinity != "2" {
+		t.Fatalf("expected (\"2\", nil), got (%q, %v)", affinity, err)
+	}
+
+	// Not reported by the kernel
+	affinity, err = r.ReadEffectiveAffinity(41)
+	if err != nil || affinity != "" {
+		t.Fatalf("expected (\"\", nil), got (%q, %v)", affinity, err)
+	}
+}
+
+func TestEffectiveAffinityMsgs(t *testing.T) {
+	handler := &mockIRQReaderWriter{
+		WrittenAffinity:   map[int]string{40: "2-3", 41: "2-3", 42: "2-3"},
+		EffectiveAffinity: map[int]string{40: "2", 42: ""},
+	}
+
+	msgs, err := effectiveAffinityMsgs([]int{40, 41, 42},
+		cpulists.CPUs{2: true, 3: true}, handler)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"IRQ 40 is effectively handled on CPUs 2"}
+	if len(msgs) != len(expected) || msgs[0] != expected[0] {
+		t.Fatalf("expected %v, got %v", expected, msgs)
+	}
+}
+
+func TestApplyIRQConfigManagedIRQs(t *testing.T) {
+	setupSysfs(t)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	testCases := []struct {
+		name   string
+		params []string
+		advice bool
+	}{
+		{
+			name:   "managed IRQ on isolated CPU",
+			params: []string{"isolcpus=0"},
+			advice: true,
+		},
+		{
+			name:   "managed_irq already set",
+			params: []string{"isolcpus=managed_irq,0"},
+		},
+		{
+			name: "no isolated CPUs",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf.Reset()
+			config := &model.InternalConfig{
+				Data: model.Config{
+					KernelCmdline: model.KernelCmdline{Parameters: tc.params},
+					Interrupts: model.Interrupts{
+						"storage": {CPUs: "0", Filter: model.IRQFilter{Actions: "nvme"}},
+					},
+				},
+			}
+			handler := &mockIRQReaderWriter{
+				IRQs: map[uint]IRQInfo{
+					50: {Number: 50, Actions: "nvme0q0"},
+					51: {Number: 51, Actions: "nvme0q1", Managed: true},
+				},
+				EffectiveAffinity: map[int]string{51: "0"},
+			}
+
+			if err := applyIRQConfig(config, handler); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, ok := handler.WrittenAffinity[51]; ok {
+				t.Errorf("expected managed IRQ 51 not to be written")
+			}
+			if handler.WrittenAffinity[50] != "0" {
+				t.Errorf("expected IRQ 50 to be written")
+			}
+
+			advice := strings.Contains(buf.String(),
+				"managed IRQs 51 are handled on isolated CPUs 0")
+			if advice != tc.advice {
+				t.Errorf("expected advice: %v, got log:\n%s", tc.advice, buf.String())
+			}
+		})
+	}
+}
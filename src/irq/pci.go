@@ -0,0 +1,114 @@
+package irq
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The IRQ numbers of a PCI device are listed in
+// /sys/bus/pci/devices/<address>/msi_irqs/ for MSI and MSI-X interrupts,
+// and in /sys/bus/pci/devices/<address>/irq for the legacy INTx interrupt.
+// See: https://www.kernel.org/doc/Documentation/ABI/testing/sysfs-bus-pci
+
+var (
+	sysBusPCIDevices = "/sys/bus/pci/devices"
+	sysClassNet      = "/sys/class/net"
+)
+
+// pciDevice holds the PCI device information of an IRQ
+type pciDevice struct {
+	Address string
+	Driver  string
+	Netdevs []string
+}
+
+// readPCIDevices maps the IRQ numbers to the PCI devices they belong to.
+// It returns an empty map on systems without PCI devices.
+func readPCIDevices() (map[int]pciDevice, error) {
+	devices := make(map[int]pciDevice)
+
+	entries, err := os.ReadDir(sysBusPCIDevices)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return devices, nil
+		}
+		return nil, err
+	}
+
+	netdevs, err := readNetdevs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		address := entry.Name()
+		devPath := filepath.Join(sysBusPCIDevices, address)
+
+		dev := pciDevice{Address: address, Netdevs: netdevs[address]}
+		// The driver link is missing if no driver is bound to the device
+		if driver, err := os.Readlink(filepath.Join(devPath, "driver")); err == nil {
+			dev.Driver = filepath.Base(driver)
+		}
+
+		for _, irq := range readDeviceIRQs(devPath) {
+			devices[irq] = dev
+		}
+	}
+	return devices, nil
+}
+
+// readDeviceIRQs returns the MSI and legacy IRQ numbers of a PCI device
+func readDeviceIRQs(devPath string) []int {
+	var irqs []int
+
+	msiIRQs, err := os.ReadDir(filepath.Join(devPath, "msi_irqs"))
+	if err == nil {
+		for _, msi := range msiIRQs {
+			num, err := strconv.Atoi(msi.Name())
+			if err != nil {
+				continue
+			}
+			irqs = append(irqs, num)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(devPath, "irq"))
+	if err == nil {
+		// IRQ 0 means the device has no legacy interrupt
+		num, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err == nil && num > 0 {
+			irqs = append(irqs, num)
+		}
+	}
+	return irqs
+}
+
+// readNetdevs maps the PCI addresses to the names of their network interfaces
+func readNetdevs() (map[string][]string, error) {
+	netdevs := make(map[string][]string)
+
+	entries, err := os.ReadDir(sysClassNet)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return netdevs, nil
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		// Virtual interfaces, such as lo, have no device link
+		device, err := os.Readlink(filepath.Join(sysClassNet, entry.Name(), "device"))
+		if err != nil {
+			continue
+		}
+		address := filepath.Base(device)
+		netdevs[address] = append(netdevs[address], entry.Name())
+	}
+	for _, names := range netdevs {
+		sort.Strings(names)
+	}
+	return netdevs, nil
+}
@@ -0,0 +1,210 @@
+package irq
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+type pciDirEntry struct {
+	Address string
+	Driver  string
+	MSIIRQs []string
+	IRQ     string
+	Netdevs []string
+}
+
+// setupPCITestDir creates fake /sys/bus/pci/devices and /sys/class/net trees
+func setupPCITestDir(t *testing.T, entries []pciDirEntry) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	devicesDir := filepath.Join(tmpDir, "devices")
+	netDir := filepath.Join(tmpDir, "net")
+	driversDir := filepath.Join(tmpDir, "drivers")
+	for _, dir := range []string{devicesDir, netDir, driversDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+
+	for _, e := range entries {
+		devPath := filepath.Join(devicesDir, e.Address)
+		if err := os.MkdirAll(filepath.Join(devPath, "msi_irqs"), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		for _, irq := range e.MSIIRQs {
+			if err := os.WriteFile(filepath.Join(devPath, "msi_irqs", irq),
+				[]byte("msix\n"), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+		}
+		if e.IRQ != "" {
+			if err := os.WriteFile(filepath.Join(devPath, "irq"),
+				[]byte(e.IRQ+"\n"), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+		}
+		if e.Driver != "" {
+			if err := os.Symlink(filepath.Join(driversDir, e.Driver),
+				filepath.Join(devPath, "driver")); err != nil {
+				t.Fatalf("failed to create symlink: %v", err)
+			}
+		}
+		for _, netdev := range e.Netdevs {
+			if err := os.MkdirAll(filepath.Join(netDir, netdev), 0o755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			if err := os.Symlink(devPath,
+				filepath.Join(netDir, netdev, "device")); err != nil {
+				t.Fatalf("failed to create symlink: %v", err)
+			}
+		}
+	}
+	// A virtual interface without device link
+	if err := os.MkdirAll(filepath.Join(netDir, "lo"), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+
+	prevDevices, prevNet := sysBusPCIDevices, sysClassNet
+	sysBusPCIDevices, sysClassNet = devicesDir, netDir
+	t.Cleanup(func() {
+		sysBusPCIDevices, sysClassNet = prevDevices, prevNet
+	})
+}
+
+func TestReadIRQsPCIDevices(t *testing.T) {
+	setupIRQTestDir(t, []irqDirEntry{
+		{Number: 9, Files: map[string]string{"actions": "acpi"}},
+		{Number: 16, Files: map[string]string{"actions": "i801_smbus"}},
+		{Number: 40, Files: map[string]string{"actions": "eth1-rx-0"}},
+		{Number: 41, Files: map[string]string{"actions": "eth1-tx-0"}},
+		{Number: 50, Files: map[string]string{"actions": "nvme0q0"}},
+	})
+	setupPCITestDir(t, []pciDirEntry{
+		{
+			Address: "0000:03:00.0",
+			Driver:  "igb",
+			MSIIRQs: []string{"40", "41"},
+			IRQ:     "0",
+			Netdevs: []string{"eth1"},
+		},
+		{
+			Address: "0000:04:00.0",
+			Driver:  "nvme",
+			MSIIRQs: []string{"50"},
+		},
+		{
+			Address: "0000:00:1f.4",
+			IRQ:     "16",
+		},
+	})
+
+	r := &realIRQReaderWriter{}
+	irqs, err := r.ReadIRQs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[int]IRQInfo{
+		9:  {},
+		16: {PCIAddress: "0000:00:1f.4"},
+		40: {PCIAddress: "0000:03:00.0", Driver: "igb", Netdevs: []string{"eth1"}},
+		41: {PCIAddress: "0000:03:00.0", Driver: "igb", Netdevs: []string{"eth1"}},
+		50: {PCIAddress: "0000:04:00.0", Driver: "nvme"},
+	}
+	if len(irqs) != len(expected) {
+		t.Fatalf("expected %d IRQs, got %d", len(expected), len(irqs))
+	}
+	for _, irq := range irqs {
+		want := expected[irq.Number]
+		if irq.PCIAddress != want.PCIAddress || irq.Driver != want.Driver ||
+			len(irq.Netdevs) != len(want.Netdevs) {
+			t.Errorf("IRQ %d: expected %+v, got %+v", irq.Number, want, irq)
+			continue
+		}
+		for i := range want.Netdevs {
+			if irq.Netdevs[i] != want.Netdevs[i] {
+				t.Errorf("IRQ %d: expected netdevs %v, got %v",
+					irq.Number, want.Netdevs, irq.Netdevs)
+			}
+		}
+	}
+}
+
+func TestReadPCIDevicesNoPCIBus(t *testing.T) {
+	prev := sysBusPCIDevices
+	sysBusPCIDevices = "/does/not/exist"
+	t.Cleanup(func() { sysBusPCIDevices = prev })
+
+	devices, err := readPCIDevices()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(devices) != 0 {
+		t.Fatalf("expected no devices, got %v", devices)
+	}
+}
+
+func TestMatchesPCIFilters(t *testing.T) {
+	irqs := []IRQInfo{
+		{Number: 9, Actions: "acpi"},
+		{Number: 40, Actions: "eth1-rx-0", PCIAddress: "0000:03:00.0",
+			Driver: "igb", Netdevs: []string{"eth1"}},
+		{Number: 41, Actions: "eth1-tx-0", PCIAddress: "0000:03:00.0",
+			Driver: "igb", Netdevs: []string{"eth1"}},
+		{Number: 50, Actions: "nvme0q0", PCIAddress: "0000:04:00.0",
+			Driver: "nvme"},
+	}
+
+	testCases := []struct {
+		name     string
+		filter   model.IRQFilter
+		expected []int
+	}{
+		{
+			name:     "pci address",
+			filter:   model.IRQFilter{PCIAddress: "0000:04:00.0"},
+			expected: []int{50},
+		},
+		{
+			name:     "driver",
+			filter:   model.IRQFilter{Driver: "^igb$"},
+			expected: []int{40, 41},
+		},
+		{
+			name:     "netdev",
+			filter:   model.IRQFilter{Netdev: "^eth1$"},
+			expected: []int{40, 41},
+		},
+		{
+			name:     "netdev and actions",
+			filter:   model.IRQFilter{Netdev: "eth1", Actions: "rx"},
+			expected: []int{40},
+		},
+		{
+			name:     "unknown netdev",
+			filter:   model.IRQFilter{Netdev: "eth9"},
+			expected: []int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matched, err := filterIRQs(irqs, model.IRQTuning{Filter: tc.filter})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(matched) != len(tc.expected) {
+				t.Fatalf("expected IRQs %v, got %v", tc.expected, matched)
+			}
+			for _, irq := range tc.expected {
+				if !matched[irq] {
+					t.Errorf("expected IRQ %d to match, got %v", irq, matched)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package irq
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// CheckIRQStatus reports the IRQs whose affinity no longer matches the
+// IRQ tuning rules, e.g. because irqbalance moved them
+func CheckIRQStatus(config *model.InternalConfig) error {
+	utils.PrintTitle("IRQ Tuning Status")
+	if len(config.Data.Interrupts) == 0 {
+		log.Println("No IRQ tuning rules found in config")
+		return nil
+	}
+
+	running, err := irqbalanceRunning()
+	if err != nil {
+		return fmt.Errorf("failed to detect irqbalance: %v", err)
+	}
+	if running {
+		log.Println("WARNING: irqbalance is running")
+	}
+
+	return checkIRQStatus(config.Data.Interrupts, &realIRQReaderWriter{})
+}
+
+func checkIRQStatus(rules model.Interrupts, handler IRQReaderWriter) error {
+	irqs, err := handler.ReadIRQs()
+	if err != nil {
+		return err
+	}
+	sort.Slice(irqs, func(i, j int) bool {
+		return irqs[i].Number < irqs[j].Number
+	})
+
+	owners, err := irqOwners(irqs, rules)
+	if err != nil {
+		return err
+	}
+
+	mismatches := 0
+	for _, label := range rules.Labels() {
+		rule := rules[label]
+		log.Printf("Rule: %s\n", label)
+
+		// IRQs are checked against the winning rule only
+		var matched, overridden []IRQInfo
+		ruleIRQs, err := matchingIRQInfos(irqs, rule)
+		if err != nil {
+			return err
+		}
+		for _, irq := range ruleIRQs {
+			if owners[irq.Number] == label {
+				matched = append(matched, irq)
+			} else {
+				overridden = append(overridden, irq)
+			}
+		}
+		affinities, _, err := irqAffinities(rule, matched)
+		if err != nil {
+			return fmt.Errorf("irq tuning rule #%s: %v", label, err)
+		}
+
+		var matching, managed []int
+		var msgs []string
+		for _, irq := range matched {
+			// The affinity of managed IRQs can't be set, so apply skips them
+			if irq.Managed {
+				managed = append(managed, irq.Number)
+				continue
+			}
+			// The kernel reports the affinity list in its canonical form,
+			// as written
+			expected := affinities[irq.Number]
+
+			affinity, err := handler.ReadCPUAffinity(irq.Number)
+			if err != nil {
+				return err
+			}
+			if affinity != expected {
+				mismatches++
+				msgs = append(msgs, fmt.Sprintf(
+					"Warning: IRQ %d (%s) is on CPUs %s, expected %s",
+					irq.Number, irq.Actions, affinity, expected))
+				continue
+			}
+			matching = append(matching, irq.Number)
+		}
+		if len(matching) > 0 {
+			msgs = append([]string{fmt.Sprintf("IRQs %s are on CPUs %s",
+				cpulists.GenCPUlist(matching), rule.CPUs)}, msgs...)
+		}
+		if len(managed) > 0 {
+			msgs = append(msgs, fmt.Sprintf("Ignored managed IRQs: %s",
+				cpulists.GenCPUlist(managed)))
+		}
+		for _, irq := range overridden {
+			msgs = append(msgs, fmt.Sprintf("IRQ %d (%s) is overridden by rule #%s",
+				irq.Number, irq.Actions, owners[irq.Number]))
+		}
+		if len(msgs) == 0 {
+			msgs = append(msgs, "No IRQs matched the filter")
+		}
+		utils.LogTreeStyle(msgs)
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("%d IRQs no longer match their rules", mismatches)
+	}
+	return nil
+}
+
+// irqOwners maps the IRQs to the rule which wins over the others matching
+// them: the one applied last
+func irqOwners(irqs []IRQInfo, rules model.Interrupts) (map[int]string, error) {
+	owners := make(map[int]string)
+	for _, label := range rules.Labels() {
+		matching, err := matchingIRQInfos(irqs, rules[label])
+		if err != nil {
+			return nil, fmt.Errorf("irq tuning rule #%s: %v", label, err)
+		}
+		for _, irq := range matching {
+			owners[irq.Number] = label
+		}
+	}
+	return owners, nil
+}
+
+func canonicalCPUList(cpus cpulists.CPUs) string {
+	list := make([]int, 0, len(cpus))
+	for cpu := range cpus {
+		list = append(list, cpu)
+	}
+	return cpulists.GenCPUlist(list)
+}
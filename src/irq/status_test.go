@@ -0,0 +1,95 @@
+package irq
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+func TestCheckIRQStatus(t *testing.T) {
+	rules := model.Interrupts{
+		"network": {CPUs: "0", Filter: model.IRQFilter{Actions: "eth"}},
+	}
+
+	testCases := []struct {
+		name     string
+		affinity map[int]string
+		err      string
+	}{
+		{
+			name:     "affinity matches",
+			affinity: map[int]string{10: "0", 11: "0"},
+		},
+		{
+			name:     "affinity changed",
+			affinity: map[int]string{10: "0", 11: "5"},
+			err:      "1 IRQs no longer match their rules",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &mockIRQReaderWriter{
+				IRQs: map[uint]IRQInfo{
+					10: {Number: 10, Actions: "eth0"},
+					11: {Number: 11, Actions: "eth1"},
+				},
+				WrittenAffinity: tc.affinity,
+			}
+
+			err := checkIRQStatus(rules, handler)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+// Managed IRQs are skipped by apply, so they are not reported as mismatches
+func TestCheckIRQStatusManaged(t *testing.T) {
+	rules := model.Interrupts{
+		"storage": {CPUs: "0", Filter: model.IRQFilter{Actions: "nvme"}},
+	}
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			50: {Number: 50, Actions: "nvme0q0"},
+			51: {Number: 51, Actions: "nvme0q1", Managed: true},
+		},
+		WrittenAffinity: map[int]string{50: "0", 51: "0-7"},
+	}
+
+	if err := checkIRQStatus(rules, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestIRQOwners(t *testing.T) {
+	irqs := []IRQInfo{
+		{Number: 10, Actions: "eth0-rx-0"},
+		{Number: 11, Actions: "eth0-tx-0"},
+		{Number: 12, Actions: "nvme0q0"},
+	}
+	rules := model.Interrupts{
+		"network": {CPUs: "0", Filter: model.IRQFilter{Actions: "eth0"}},
+		"rx":      {CPUs: "0", Filter: model.IRQFilter{Actions: "rx"}, Priority: 1},
+		"all":     {CPUs: "0", Priority: -1},
+	}
+
+	owners, err := irqOwners(irqs, rules)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[int]string{10: "rx", 11: "network", 12: "all"}
+	for irq, label := range expected {
+		if owners[irq] != label {
+			t.Errorf("IRQ %d: expected rule #%s, got #%s", irq, label, owners[irq])
+		}
+	}
+}
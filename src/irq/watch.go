@@ -0,0 +1,175 @@
+package irq
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// The kernel doesn't emit uevents when an IRQ gets registered or when a
+// handler gets attached to it, so new IRQs are detected by polling
+// /sys/kernel/irq.
+
+// irqWatcher keeps track of the active IRQs between polls
+type irqWatcher struct {
+	rules   model.Interrupts
+	handler IRQReaderWriter
+	// Active IRQs mapped to their actions. An IRQ is considered new when it
+	// becomes active or when its actions change, e.g. a device driver
+	// requested it after the previous poll.
+	seen map[int]string
+}
+
+// WatchIRQs applies the IRQ tuning rules to the IRQs which show up while
+// running, until the context is done
+func WatchIRQs(ctx context.Context, config *model.InternalConfig, interval time.Duration) error {
+	utils.PrintTitle("IRQ Watch")
+	if len(config.Data.Interrupts) == 0 {
+		return fmt.Errorf("no IRQ tuning rules found in config")
+	}
+	return watchIRQs(ctx, config.Data.Interrupts, &realIRQReaderWriter{}, interval)
+}
+
+func watchIRQs(
+	ctx context.Context,
+	rules model.Interrupts,
+	handler IRQReaderWriter,
+	interval time.Duration,
+) error {
+	w := &irqWatcher{
+		rules:   rules,
+		handler: handler,
+		seen:    make(map[int]string),
+	}
+
+	log.Printf("Watching for new IRQs every %v\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			log.Println("Stopped watching for new IRQs")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll applies the matching rules to the IRQs which are new since the
+// previous poll. Distributed rules matching a new IRQ are applied again to
+// all of their IRQs, so the IRQs stay spread over the CPUs of the rule.
+// Failing to write the affinity of an IRQ doesn't stop the watch.
+func (w *irqWatcher) poll() error {
+	irqs, err := w.handler.ReadIRQs()
+	if err != nil {
+		return fmt.Errorf("failed to read IRQs: %v", err)
+	}
+	sort.Slice(irqs, func(i, j int) bool {
+		return irqs[i].Number < irqs[j].Number
+	})
+
+	active := make(map[int]string, len(irqs))
+	isNew := make(map[int]bool)
+	for _, irq := range irqs {
+		active[irq.Number] = irq.Actions
+		if actions, ok := w.seen[irq.Number]; !ok || actions != irq.Actions {
+			isNew[irq.Number] = true
+		}
+	}
+	// Forget the IRQs which are no longer active, so they get handled
+	// again if they come back
+	w.seen = active
+	if len(isNew) == 0 {
+		return nil
+	}
+
+	// IRQs are only written by the rule which wins over the others
+	// matching them
+	owners, err := irqOwners(irqs, w.rules)
+	if err != nil {
+		return err
+	}
+
+	msgs := make(map[int][]string)
+	// The highest priority rule is applied last and wins
+	for _, label := range w.rules.Labels() {
+		rule := w.rules[label]
+		matched, err := matchingIRQInfos(irqs, rule)
+		if err != nil {
+			return err
+		}
+
+		var owned []IRQInfo
+		hasNew := false
+		for _, irq := range matched {
+			if irq.Managed {
+				if isNew[irq.Number] {
+					msgs[irq.Number] = append(msgs[irq.Number], fmt.Sprintf(
+						"Ignored managed IRQ, matched by rule #%s", label))
+				}
+				continue
+			}
+			if owners[irq.Number] == label {
+				owned = append(owned, irq)
+				hasNew = hasNew || isNew[irq.Number]
+			}
+		}
+		if !hasNew {
+			continue
+		}
+
+		affinities, _, err := irqAffinities(rule, owned)
+		if err != nil {
+			for _, irq := range owned {
+				if isNew[irq.Number] {
+					msgs[irq.Number] = append(msgs[irq.Number], fmt.Sprintf(
+						"Error: failed to apply rule #%s: %v", label, err))
+				}
+			}
+			continue
+		}
+		for _, irq := range owned {
+			cpus := affinities[irq.Number]
+			if !isNew[irq.Number] {
+				// Only the distribution may have changed for the IRQs
+				// which were already handled
+				if rule.Distribution == "" {
+					continue
+				}
+				current, err := w.handler.ReadCPUAffinity(irq.Number)
+				if err == nil && current == cpus {
+					continue
+				}
+			}
+			success, managed, err := w.handler.WriteCPUAffinity(irq.Number, cpus)
+			switch {
+			case err != nil:
+				msgs[irq.Number] = append(msgs[irq.Number], fmt.Sprintf(
+					"Error: failed to apply rule #%s: %v", label, err))
+			case managed:
+				msgs[irq.Number] = append(msgs[irq.Number], fmt.Sprintf(
+					"Ignored managed IRQ, matched by rule #%s", label))
+			case success:
+				msgs[irq.Number] = append(msgs[irq.Number], fmt.Sprintf(
+					"Assigned to CPUs %s by rule #%s", cpus, label))
+			}
+		}
+	}
+
+	for _, irq := range irqs {
+		if len(msgs[irq.Number]) > 0 {
+			log.Printf("IRQ %d (%s):\n", irq.Number, irq.Actions)
+			utils.LogTreeStyle(msgs[irq.Number])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package irq
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+func TestIRQWatcherPoll(t *testing.T) {
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			10: {Number: 10, Actions: "eth0-TxRx-0"},
+			11: {Number: 11, Actions: "nvme0q0"},
+		},
+	}
+	w := &irqWatcher{
+		rules: model.Interrupts{
+			"network": {
+				CPUs:   "0",
+				Filter: model.IRQFilter{Actions: "eth"},
+			},
+		},
+		handler: handler,
+		seen:    make(map[int]string),
+	}
+
+	// First poll handles all active IRQs
+	if err := w.poll(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(handler.WrittenAffinity) != 1 || handler.WrittenAffinity[10] != "0" {
+		t.Fatalf("expected IRQ 10 to be assigned, got %v", handler.WrittenAffinity)
+	}
+
+	// Known IRQs are not written again
+	handler.WrittenAffinity = nil
+	if err := w.poll(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(handler.WrittenAffinity) != 0 {
+		t.Fatalf("expected no writes, got %v", handler.WrittenAffinity)
+	}
+
+	// A new IRQ and an IRQ with a new action
+	handler.IRQs[12] = IRQInfo{Number: 12, Actions: "eth1-TxRx-0"}
+	handler.IRQs[11] = IRQInfo{Number: 11, Actions: "nvme0q0, eth2"}
+	if err := w.poll(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(handler.WrittenAffinity) != 2 ||
+		handler.WrittenAffinity[11] != "0" ||
+		handler.WrittenAffinity[12] != "0" {
+		t.Fatalf("expected IRQs 11 and 12 to be assigned, got %v",
+			handler.WrittenAffinity)
+	}
+}
+
+// A new IRQ matched by a distributed rule moves the other IRQs of the rule,
+// so they stay spread according to their queue index
+func TestIRQWatcherPollDistributed(t *testing.T) {
+	setupSysfs(t)
+
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			10: {Number: 10, Actions: "eth0-TxRx-1"},
+			11: {Number: 11, Actions: "eth0-TxRx-2"},
+		},
+	}
+	w := &irqWatcher{
+		rules: model.Interrupts{
+			"network": {
+				CPUs:         "0-3",
+				Filter:       model.IRQFilter{Actions: "eth0"},
+				Distribution: model.DistributionRoundRobin,
+			},
+		},
+		handler: handler,
+		seen:    make(map[int]string),
+	}
+
+	if err := w.poll(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[int]string{10: "0", 11: "1"}
+	if !maps.Equal(handler.WrittenAffinity, expected) {
+		t.Fatalf("expected %v, got %v", expected, handler.WrittenAffinity)
+	}
+
+	handler.IRQs[12] = IRQInfo{Number: 12, Actions: "eth0-TxRx-0"}
+	if err := w.poll(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = map[int]string{12: "0", 10: "1", 11: "2"}
+	if !maps.Equal(handler.WrittenAffinity, expected) {
+		t.Fatalf("expected %v, got %v", expected, handler.WrittenAffinity)
+	}
+}
+
+// Failing to write an affinity is logged, and doesn't stop the watch
+func TestIRQWatcherPollWriteError(t *testing.T) {
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			10: {Number: 10, Actions: "eth0"},
+		},
+		Errors: map[string]error{
+			"WriteCPUAffinity": fmt.Errorf("invalid argument"),
+		},
+	}
+	w := &irqWatcher{
+		rules: model.Interrupts{
+			"network": {CPUs: "0", Filter: model.IRQFilter{Actions: "eth"}},
+		},
+		handler: handler,
+		seen:    make(map[int]string),
+	}
+
+	if err := w.poll(); err != nil {
+		t.Fatalf("expected the error to be logged, got: %v", err)
+	}
+	if _, ok := w.seen[10]; !ok {
+		t.Fatalf("expected IRQ 10 to be handled")
+	}
+}
+
+func TestWatchIRQsStops(t *testing.T) {
+	handler := &mockIRQReaderWriter{
+		IRQs: map[uint]IRQInfo{
+			10: {Number: 10, Actions: "eth0"},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := watchIRQs(ctx, model.Interrupts{
+		"network": {CPUs: "0", Filter: model.IRQFilter{Actions: "eth"}},
+	}, handler, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if handler.WrittenAffinity[10] != "0" {
+		t.Fatalf("expected IRQ 10 to be assigned before stopping, got %v",
+			handler.WrittenAffinity)
+	}
+}
+
+func TestWatchIRQsNoRules(t *testing.T) {
+	err := WatchIRQs(context.Background(), &model.InternalConfig{}, time.Second)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
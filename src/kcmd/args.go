@@ -15,14 +15,28 @@ var kcmdSys = map[system.SystemType]func(*model.InternalConfig) ([]string, error
 	system.UbuntuCore: UpdateUbuntuCore,
 }
 
+// SupportedSystem returns true if the kernel command line parameters can be
+// applied on the given system
+func SupportedSystem(sys system.SystemType) bool {
+	_, ok := kcmdSys[sys]
+	return ok
+}
+
 func ProcessKcmdArgs(c *model.InternalConfig) ([]string, error) {
 	utils.PrintTitle("Kernel Command Line Parameters")
+	c.Data.KernelCmdline = c.Data.BootKernelCmdline()
 	if len(c.Data.KernelCmdline.Parameters) == 0 {
 		// No kernel command line options to process
 		log.Println("No kernel command line options to process")
 		return nil, nil
 	}
 
+	cmdline, err := c.Data.KernelCmdline.ResolveSelectors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve topology selectors: %v", err)
+	}
+	c.Data.KernelCmdline = cmdline
+
 	var msgs []string
 	sys, err := system.DetectSystem()
 	if err != nil {
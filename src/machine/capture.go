@@ -0,0 +1,351 @@
+package machine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpuidle"
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/irq"
+	"github.com/canonical/rt-conf/src/system"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Capture describes the local machine
+func Capture() (*Machine, error) {
+	m, err := CaptureFrom("/sys")
+	if err != nil {
+		return nil, err
+	}
+	if m.Hostname, err = os.Hostname(); err != nil {
+		return nil, fmt.Errorf("failed to read hostname: %v", err)
+	}
+	if m.Kernel, err = utils.ReadOptional("/proc/sys/kernel/osrelease"); err != nil {
+		return nil, fmt.Errorf("failed to read kernel release: %v", err)
+	}
+	if m.Cmdline, err = utils.ReadOptional("/proc/cmdline"); err != nil {
+		return nil, fmt.Errorf("failed to read kernel cmdline: %v", err)
+	}
+	if m.KernelConfig, err = ReadKernelConfig(m.Kernel); err != nil {
+		return nil, fmt.Errorf("failed to read kernel config: %v", err)
+	}
+
+	// Captured with the IRQ tuning reader, which also reads the affinities
+	// and PCI devices
+	irqs, err := irq.ReadIRQAffinities()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IRQs: %v", err)
+	}
+	m.IRQs = make([]IRQ, 0, len(irqs))
+	for _, info := range irqs {
+		m.IRQs = append(m.IRQs, IRQ{
+			Number:            info.Number,
+			Actions:           info.Actions,
+			ChipName:          info.ChipName,
+			Name:              info.Name,
+			Type:              info.Type,
+			Managed:           info.Managed,
+			PCIAddress:        info.PCIAddress,
+			Driver:            info.Driver,
+			Netdevs:           info.Netdevs,
+			Affinity:          info.Affinity,
+			EffectiveAffinity: info.EffectiveAffinity,
+		})
+	}
+
+	sys, err := system.DetectSystem()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect bootloader: %v", err)
+	}
+	m.Bootloader = sys.String()
+	return m, nil
+}
+
+// CaptureFrom describes the machine from the sysfs tree at root
+func CaptureFrom(root string) (*Machine, error) {
+	var m Machine
+	cpuFiles := map[string]*string{
+		"possible":  &m.CPUs.Possible,
+		"present":   &m.CPUs.Present,
+		"online":    &m.CPUs.Online,
+		"isolated":  &m.CPUs.Isolated,
+		"nohz_full": &m.CPUs.NohzFull,
+	}
+	for name, value := range cpuFiles {
+		content, err := utils.ReadOptional(filepath.Join(cpuDir(root), name))
+		if err != nil {
+			return nil, err
+		}
+		*value = content
+	}
+	if m.CPUs.NohzFull == "(null)" {
+		m.CPUs.NohzFull = ""
+	}
+	if m.CPUs.Present == "" {
+		return nil, fmt.Errorf("no present CPUs found in %s", cpuDir(root))
+	}
+	online, err := cpuNumbers(m.CPUs.Online)
+	if err != nil {
+		return nil, fmt.Errorf("invalid online CPUs: %v", err)
+	}
+
+	realtime, err := utils.ReadOptional(filepath.Join(root, "kernel", "realtime"))
+	if err != nil {
+		return nil, err
+	}
+	m.Realtime = realtime == "1"
+
+	m.CPUs.PCores, err = utils.ReadOptional(filepath.Join(root, "devices", "cpu_core", "cpus"))
+	if err != nil {
+		return nil, err
+	}
+	m.CPUs.ECores, err = utils.ReadOptional(filepath.Join(root, "devices", "cpu_atom", "cpus"))
+	if err != nil {
+		return nil, err
+	}
+
+	if m.CPUs.Topology, err = captureTopology(root, online); err != nil {
+		return nil, err
+	}
+	if m.Nodes, err = captureNodes(root); err != nil {
+		return nil, err
+	}
+	if m.IRQs, err = captureIRQs(root); err != nil {
+		return nil, err
+	}
+	if m.CPUFreq, err = captureCPUFreq(root); err != nil {
+		return nil, err
+	}
+	m.Governors = availableGovernors(m.CPUFreq)
+	if m.CPUIdle, err = captureCPUIdle(root, online); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// cpuNumbers returns the CPUs of a CPU list, sorted
+func cpuNumbers(list string) ([]int, error) {
+	if list == "" {
+		return nil, nil
+	}
+	cpus, err := cpulists.ParseForCPUs(list, maxCPUs)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]int, 0, len(cpus))
+	for cpu := range cpus {
+		numbers = append(numbers, cpu)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// captureTopology reads the topology of the online CPUs, the only ones
+// reporting it
+func captureTopology(root string, online []int) ([]CPUTopology, error) {
+	var topology []CPUTopology
+	for _, cpu := range online {
+		dir := filepath.Join(cpuDir(root), fmt.Sprintf("cpu%d", cpu))
+		siblings, err := utils.ReadOptional(filepath.Join(dir, "topology", "thread_siblings_list"))
+		if err != nil {
+			return nil, err
+		}
+		if siblings == "" {
+			continue
+		}
+		topo := CPUTopology{CPU: cpu, Siblings: siblings}
+		if topo.Package, err = readInt(filepath.Join(dir, "topology", "physical_package_id")); err != nil {
+			return nil, err
+		}
+		if topo.Capacity, err = readInt(filepath.Join(dir, "cpu_capacity")); err != nil {
+			return nil, err
+		}
+		topology = append(topology, topo)
+	}
+	return topology, nil
+}
+
+// readInt reads a sysfs file holding an integer, 0 if it doesn't exist
+func readInt(path string) (int, error) {
+	content, err := utils.ReadOptional(path)
+	if err != nil || content == "" {
+		return 0, err
+	}
+	value, err := strconv.Atoi(content)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	return value, nil
+}
+
+// numberedEntries returns the numbers of the entries of dir named after
+// prefix and a number, e.g. node0, sorted
+func numberedEntries(dir, prefix string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", dir, err)
+	}
+	var numbers []int
+	for _, entry := range entries {
+		num, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), prefix))
+		if err != nil || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		numbers = append(numbers, num)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+func captureNodes(root string) (map[int]string, error) {
+	dir := filepath.Join(root, "devices", "system", "node")
+	nodes, err := numberedEntries(dir, "node")
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	cpus := make(map[int]string, len(nodes))
+	for _, node := range nodes {
+		if cpus[node], err = utils.ReadOptional(filepath.Join(dir, fmt.Sprintf("node%d", node), "cpulist")); err != nil {
+			return nil, err
+		}
+	}
+	return cpus, nil
+}
+
+func captureIRQs(root string) ([]IRQ, error) {
+	dir := filepath.Join(root, "kernel", "irq")
+	numbers, err := numberedEntries(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	var irqs []IRQ
+	for _, num := range numbers {
+		actions, err := utils.ReadOptional(filepath.Join(dir, strconv.Itoa(num), "actions"))
+		if err != nil {
+			return nil, err
+		}
+		irqs = append(irqs, IRQ{Number: num, Actions: actions})
+	}
+	return irqs, nil
+}
+
+func captureCPUFreq(root string) ([]CPUFreq, error) {
+	dir := filepath.Join(cpuDir(root), "cpufreq")
+	ids, err := numberedEntries(dir, "policy")
+	if err != nil {
+		return nil, err
+	}
+	var policies []CPUFreq
+	for _, id := range ids {
+		policyDir := filepath.Join(dir, fmt.Sprintf("policy%d", id))
+		policy := CPUFreq{Policy: id}
+		files := map[string]*string{
+			"related_cpus":     &policy.CPUs,
+			"scaling_driver":   &policy.Driver,
+			"scaling_governor": &policy.Governor,
+		}
+		for name, value := range files {
+			if *value, err = utils.ReadOptional(filepath.Join(policyDir, name)); err != nil {
+				return nil, err
+			}
+		}
+		// The related CPUs are separated by spaces, e.g. 0 1 2 3
+		var cpus []int
+		for _, field := range strings.Fields(policy.CPUs) {
+			cpu, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid related CPUs of policy%d: %v", id, err)
+			}
+			cpus = append(cpus, cpu)
+		}
+		sort.Ints(cpus)
+		policy.CPUs = cpulists.GenCPUlist(cpus)
+
+		available, err := utils.ReadOptional(filepath.Join(policyDir, "scaling_available_governors"))
+		if err != nil {
+			return nil, err
+		}
+		policy.Governors = strings.Fields(available)
+		sort.Strings(policy.Governors)
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// availableGovernors returns the governors available on any policy
+func availableGovernors(policies []CPUFreq) []string {
+	seen := make(map[string]bool)
+	var governors []string
+	for _, policy := range policies {
+		for _, governor := range policy.Governors {
+			if !seen[governor] {
+				seen[governor] = true
+				governors = append(governors, governor)
+			}
+		}
+	}
+	sort.Strings(governors)
+	return governors
+}
+
+// captureCPUIdle reads the idle states of the first online CPU, and on
+// which CPUs they are disabled
+func captureCPUIdle(root string, online []int) (*CPUIdle, error) {
+	var idle CPUIdle
+	var err error
+	idleDir := filepath.Join(cpuDir(root), "cpuidle")
+	if idle.Driver, err = utils.ReadOptional(filepath.Join(idleDir, "current_driver")); err != nil {
+		return nil, err
+	}
+	if idle.Governor, err = utils.ReadOptional(filepath.Join(idleDir, "current_governor")); err != nil {
+		return nil, err
+	}
+	// Read-only on older kernels
+	if idle.Governor == "" {
+		if idle.Governor, err = utils.ReadOptional(filepath.Join(idleDir, "current_governor_ro")); err != nil {
+			return nil, err
+		}
+	}
+
+	rw := cpuidle.ReaderWriter{
+		CpuIdlePath: filepath.Join(cpuDir(root), "cpu%d", "cpuidle"),
+	}
+	if len(online) > 0 {
+		if _, err := os.Stat(fmt.Sprintf(rw.CpuIdlePath, online[0])); err == nil {
+			states, err := rw.ReadStates(online[0])
+			if err != nil {
+				return nil, err
+			}
+			for _, state := range states {
+				var disabled []int
+				for _, cpu := range online {
+					value, err := utils.ReadOptional(filepath.Join(fmt.Sprintf(rw.CpuIdlePath, cpu),
+						state.Dir, "disable"))
+					if err != nil {
+						return nil, err
+					}
+					if value == "1" {
+						disabled = append(disabled, cpu)
+					}
+				}
+				idle.States = append(idle.States, IdleState{
+					Name:     state.Name,
+					Latency:  state.Latency,
+					Disabled: cpulists.GenCPUlist(disabled),
+				})
+			}
+		}
+	}
+
+	if idle.Driver == "" && idle.Governor == "" && len(idle.States) == 0 {
+		return nil, nil
+	}
+	return &idle, nil
+}
@@ -0,0 +1,53 @@
+package machine
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/canonical/rt-conf/src/kcmd"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/system"
+)
+
+// Check validates the parts of the config which depend on the machine but
+// aren't covered by the config validation, which must run under Use
+func (m *Machine) Check(cfg *model.Config) error {
+	if len(cfg.BootKernelCmdline().Parameters) > 0 {
+		sys, err := system.ParseSystemType(m.Bootloader)
+		if err != nil {
+			return fmt.Errorf("invalid bootloader: %v", err)
+		}
+		if !kcmd.SupportedSystem(sys) {
+			return fmt.Errorf("kernel command line parameters are not supported on %s systems", sys)
+		}
+	}
+
+	if len(m.Governors) > 0 {
+		labels := make([]string, 0, len(cfg.CpuGovernance))
+		for label := range cfg.CpuGovernance {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			gov := cfg.CpuGovernance[label].ScalGov
+			if gov != "" && !slices.Contains(m.Governors, gov) {
+				return fmt.Errorf("cpu governance rule #%s: scaling governor %s is not available, available: %v",
+					label, gov, m.Governors)
+			}
+		}
+	}
+
+	for _, label := range cfg.Hugepages.Labels() {
+		nodes, err := cfg.Hugepages[label].ParseNodes()
+		if err != nil {
+			return fmt.Errorf("hugepages rule #%s: %v", label, err)
+		}
+		for node := range nodes {
+			if _, ok := m.Nodes[node]; !ok {
+				return fmt.Errorf("hugepages rule #%s: NUMA node %d does not exist", label, node)
+			}
+		}
+	}
+	return nil
+}
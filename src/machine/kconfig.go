@@ -0,0 +1,103 @@
+package machine
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// KernelConfigOptions are the kernel config options relevant to real-time,
+// reported by the inventory
+var KernelConfigOptions = []string{
+	"CONFIG_PREEMPT_RT",
+	"CONFIG_PREEMPT",
+	"CONFIG_HZ",
+	"CONFIG_NO_HZ_FULL",
+	"CONFIG_RCU_NOCB_CPU",
+	"CONFIG_CPU_ISOLATION",
+	"CONFIG_IRQ_FORCED_THREADING",
+	"CONFIG_REGMAP_IRQ",
+	"CONFIG_HOTPLUG_CPU",
+	"CONFIG_CPU_FREQ",
+	"CONFIG_CPU_IDLE",
+	"CONFIG_NUMA",
+	"CONFIG_TRANSPARENT_HUGEPAGE",
+	"CONFIG_HUGETLBFS",
+}
+
+// Locations of the kernel config, variables for the tests
+var (
+	bootDir        = "/boot"
+	procConfigGzip = "/proc/config.gz"
+)
+
+// ReadKernelConfig reads the KernelConfigOptions from the config of the
+// kernel release, in /boot or else /proc/config.gz. It returns nil when
+// neither is available.
+func ReadKernelConfig(release string) (map[string]string, error) {
+	path := filepath.Join(bootDir, "config-"+release)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return readKernelConfigGzip()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", path, err)
+	}
+	defer f.Close()
+	return parseKernelConfig(f, KernelConfigOptions)
+}
+
+func readKernelConfigGzip() (map[string]string, error) {
+	f, err := os.Open(procConfigGzip)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", procConfigGzip, err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", procConfigGzip, err)
+	}
+	defer r.Close()
+	return parseKernelConfig(r, KernelConfigOptions)
+}
+
+// parseKernelConfig returns the values of the given options, n for the
+// options which are not set. Options missing from the config are omitted.
+func parseKernelConfig(r io.Reader, options []string) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var name, value string
+		if unset, ok := strings.CutPrefix(line, "# "); ok {
+			var found bool
+			if name, found = strings.CutSuffix(unset, " is not set"); !found {
+				continue
+			}
+			value = "n"
+		} else {
+			var found bool
+			if name, value, found = strings.Cut(line, "="); !found {
+				continue
+			}
+			value = strings.Trim(value, `"`)
+		}
+		if slices.Contains(options, name) {
+			values[name] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading kernel config: %v", err)
+	}
+	return values, nil
+}
@@ -0,0 +1,77 @@
+package machine
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testKernelConfig = `#
+# Automatically generated file; DO NOT EDIT.
+#
+CONFIG_HZ=1000
+CONFIG_PREEMPT_RT=y
+# CONFIG_NO_HZ_FULL is not set
+CONFIG_REGMAP_IRQ=m
+CONFIG_LOCALVERSION=""
+CONFIG_DEFAULT_HOSTNAME="(none)"
+`
+
+func TestParseKernelConfig(t *testing.T) {
+	values, err := parseKernelConfig(strings.NewReader(testKernelConfig), KernelConfigOptions)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"CONFIG_HZ":         "1000",
+		"CONFIG_PREEMPT_RT": "y",
+		"CONFIG_NO_HZ_FULL": "n",
+		"CONFIG_REGMAP_IRQ": "m",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+}
+
+func TestReadKernelConfig(t *testing.T) {
+	dir := t.TempDir()
+	prevBoot, prevProc := bootDir, procConfigGzip
+	bootDir = dir
+	procConfigGzip = filepath.Join(dir, "config.gz")
+	t.Cleanup(func() { bootDir, procConfigGzip = prevBoot, prevProc })
+
+	// Neither available
+	values, err := ReadKernelConfig("6.8.0-rt")
+	if err != nil || values != nil {
+		t.Fatalf("expected (nil, nil), got (%v, %v)", values, err)
+	}
+
+	f, err := os.Create(procConfigGzip)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	w := gzip.NewWriter(f)
+	if _, err := w.Write([]byte("CONFIG_PREEMPT_RT=y\n")); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	w.Close()
+	f.Close()
+
+	values, err = ReadKernelConfig("6.8.0-rt")
+	if err != nil || values["CONFIG_PREEMPT_RT"] != "y" {
+		t.Fatalf("expected CONFIG_PREEMPT_RT from %s, got (%v, %v)", procConfigGzip, values, err)
+	}
+
+	// /boot takes precedence
+	if err := os.WriteFile(filepath.Join(dir, "config-6.8.0-rt"),
+		[]byte("# CONFIG_PREEMPT_RT is not set\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	values, err = ReadKernelConfig("6.8.0-rt")
+	if err != nil || values["CONFIG_PREEMPT_RT"] != "n" {
+		t.Fatalf("expected CONFIG_PREEMPT_RT from /boot, got (%v, %v)", values, err)
+	}
+}
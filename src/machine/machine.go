@@ -0,0 +1,153 @@
+// This package describes a target machine, so configs can be validated
+// offline, e.g. in CI, against the machine they are meant for instead of
+// the local one.
+
+package machine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v4"
+)
+
+// Largest number of CPUs the kernel can be built for, see NR_CPUS
+const maxCPUs = 8192
+
+// Machine describes the parts of a machine which the config validation
+// depends on, as captured on the target by rt-conf inventory. The encoded
+// fields are sorted, so descriptions of the same machine compare equal.
+type Machine struct {
+	Hostname string `yaml:"hostname,omitempty" json:"hostname,omitempty"`
+	// Kernel release, e.g. 6.8.0-1009-realtime
+	Kernel string `yaml:"kernel,omitempty" json:"kernel,omitempty"`
+	// Whether the kernel is a PREEMPT_RT one, see /sys/kernel/realtime
+	Realtime bool `yaml:"realtime" json:"realtime"`
+	// Kernel command line the machine was booted with
+	Cmdline string `yaml:"cmdline,omitempty" json:"cmdline,omitempty"`
+	// Kernel config options relevant to real-time, e.g. CONFIG_NO_HZ_FULL,
+	// with their values, n for the options which are not set
+	KernelConfig map[string]string `yaml:"kernel-config,omitempty" json:"kernel-config,omitempty"`
+	// Bootloader, e.g. grub, see system.SystemType
+	Bootloader string `yaml:"bootloader" json:"bootloader"`
+	CPUs       CPUs   `yaml:"cpus" json:"cpus"`
+	// CPU lists of the NUMA nodes
+	Nodes map[int]string `yaml:"nodes,omitempty" json:"nodes,omitempty"`
+	IRQs  []IRQ          `yaml:"irqs,omitempty" json:"irqs,omitempty"`
+	// Available cpufreq scaling governors, on any policy
+	Governors []string  `yaml:"governors,omitempty" json:"governors,omitempty"`
+	CPUFreq   []CPUFreq `yaml:"cpufreq,omitempty" json:"cpufreq,omitempty"`
+	CPUIdle   *CPUIdle  `yaml:"cpuidle,omitempty" json:"cpuidle,omitempty"`
+}
+
+// CPUs describes the CPU sets and topology, the CPU lists being in the
+// format reported by the kernel, e.g. 0-3,8-11
+type CPUs struct {
+	Possible string `yaml:"possible" json:"possible"`
+	Present  string `yaml:"present" json:"present"`
+	Online   string `yaml:"online" json:"online"`
+	Isolated string `yaml:"isolated,omitempty" json:"isolated,omitempty"`
+	NohzFull string `yaml:"nohz-full,omitempty" json:"nohz-full,omitempty"`
+	// Performance and efficiency cores of hybrid CPUs
+	PCores   string        `yaml:"pcores,omitempty" json:"pcores,omitempty"`
+	ECores   string        `yaml:"ecores,omitempty" json:"ecores,omitempty"`
+	Topology []CPUTopology `yaml:"topology,omitempty" json:"topology,omitempty"`
+}
+
+// CPUTopology describes the topology of an online CPU
+type CPUTopology struct {
+	CPU     int `yaml:"cpu" json:"cpu"`
+	Package int `yaml:"package" json:"package"`
+	// SMT siblings, including the CPU itself
+	Siblings string `yaml:"siblings" json:"siblings"`
+	// Capacity of the CPU relative to the others, reported on arm64
+	Capacity int `yaml:"capacity,omitempty" json:"capacity,omitempty"`
+}
+
+// IRQ describes an active IRQ. Only the number and actions are needed for
+// validation, the rest is captured for bug reports.
+type IRQ struct {
+	Number     int      `yaml:"number" json:"number"`
+	Actions    string   `yaml:"actions,omitempty" json:"actions,omitempty"`
+	ChipName   string   `yaml:"chip-name,omitempty" json:"chip-name,omitempty"`
+	Name       string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Type       string   `yaml:"type,omitempty" json:"type,omitempty"`
+	Managed    bool     `yaml:"managed,omitempty" json:"managed,omitempty"`
+	PCIAddress string   `yaml:"pci-address,omitempty" json:"pci-address,omitempty"`
+	Driver     string   `yaml:"driver,omitempty" json:"driver,omitempty"`
+	Netdevs    []string `yaml:"netdevs,omitempty" json:"netdevs,omitempty"`
+	// Current affinity, and effective one when reported by the kernel
+	Affinity          string `yaml:"affinity,omitempty" json:"affinity,omitempty"`
+	EffectiveAffinity string `yaml:"effective-affinity,omitempty" json:"effective-affinity,omitempty"`
+}
+
+// CPUFreq describes a cpufreq policy
+type CPUFreq struct {
+	Policy    int      `yaml:"policy" json:"policy"`
+	CPUs      string   `yaml:"cpus" json:"cpus"`
+	Driver    string   `yaml:"driver,omitempty" json:"driver,omitempty"`
+	Governor  string   `yaml:"governor,omitempty" json:"governor,omitempty"`
+	Governors []string `yaml:"governors,omitempty" json:"governors,omitempty"`
+}
+
+// CPUIdle describes the cpuidle driver and the idle states of the online
+// CPUs, which all share the same states
+type CPUIdle struct {
+	Driver   string      `yaml:"driver,omitempty" json:"driver,omitempty"`
+	Governor string      `yaml:"governor,omitempty" json:"governor,omitempty"`
+	States   []IdleState `yaml:"states,omitempty" json:"states,omitempty"`
+}
+
+// IdleState describes an idle state
+type IdleState struct {
+	Name string `yaml:"name" json:"name"`
+	// Exit latency in microseconds
+	Latency int `yaml:"latency" json:"latency"`
+	// CPUs on which the state is disabled
+	Disabled string `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+// Load reads a machine description file, in YAML or JSON
+func Load(path string) (*Machine, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	var m Machine
+	// JSON is mostly YAML, but the YAML decoder doesn't turn the quoted
+	// JSON object keys into the integers of the node numbers
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		err = json.Unmarshal(content, &m)
+	} else {
+		err = yaml.Unmarshal(content, &m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal machine description: %v", err)
+	}
+	if m.CPUs.Present == "" {
+		return nil, fmt.Errorf("invalid machine description %s: no present CPUs", path)
+	}
+	return &m, nil
+}
+
+// Marshal encodes the machine description in the given format, yaml or
+// json. Both can be loaded as a validation target.
+func (m *Machine) Marshal(format string) ([]byte, error) {
+	var content []byte
+	var err error
+	switch format {
+	case "yaml":
+		content, err = yaml.Marshal(m)
+	case "json":
+		content, err = json.MarshalIndent(m, "", "  ")
+		content = append(content, '\n')
+	default:
+		return nil, fmt.Errorf("unsupported format: %q, expected yaml or json", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal machine description: %v", err)
+	}
+	return content, nil
+}
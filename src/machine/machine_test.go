@@ -0,0 +1,220 @@
+package machine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+)
+
+func testMachine() *Machine {
+	return &Machine{
+		Bootloader: "grub",
+		CPUs: CPUs{
+			Possible: "0-7",
+			Present:  "0-7",
+			Online:   "0-3",
+			Isolated: "2-3",
+			NohzFull: "2-3",
+			Topology: []CPUTopology{
+				{CPU: 0, Package: 0, Siblings: "0-1"},
+				{CPU: 1, Package: 0, Siblings: "0-1"},
+				{CPU: 2, Package: 0, Siblings: "2-3"},
+				{CPU: 3, Package: 0, Siblings: "2-3"},
+			},
+		},
+		Realtime:  true,
+		Nodes:     map[int]string{0: "0-3"},
+		IRQs:      []IRQ{{Number: 24, Actions: "nvme0q0"}, {Number: 25}},
+		Governors: []string{"performance", "powersave", "schedutil"},
+		CPUFreq: []CPUFreq{
+			{Policy: 0, CPUs: "0-1", Driver: "intel_pstate", Governor: "powersave",
+				Governors: []string{"performance", "powersave"}},
+			{Policy: 2, CPUs: "2-3", Driver: "intel_pstate", Governor: "performance",
+				Governors: []string{"performance", "schedutil"}},
+		},
+		CPUIdle: &CPUIdle{
+			Driver:   "intel_idle",
+			Governor: "menu",
+			States: []IdleState{
+				{Name: "POLL", Latency: 0},
+				{Name: "C1", Latency: 2},
+				{Name: "C6", Latency: 170, Disabled: "2-3"},
+			},
+		},
+	}
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	want := testMachine()
+	root := t.TempDir()
+	if err := want.WriteSysfs(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := CaptureFrom(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Not read from sysfs
+	got.Bootloader = want.Bootloader
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestCaptureFromEmpty(t *testing.T) {
+	if _, err := CaptureFrom(t.TempDir()); err == nil {
+		t.Fatalf("expected error for missing CPUs")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "machine")
+	for _, format := range []string{"yaml", "json"} {
+		content, err := testMachine().Marshal(format)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+
+		m, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+		if !reflect.DeepEqual(m, testMachine()) {
+			t.Errorf("%s: expected %+v, got %+v", format, testMachine(), m)
+		}
+	}
+
+	if _, err := testMachine().Marshal("xml"); err == nil {
+		t.Errorf("expected error for unsupported format")
+	}
+
+	if err := os.WriteFile(path, []byte("bootloader: grub\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "no present CPUs") {
+		t.Fatalf("expected error for missing CPUs, got: %v", err)
+	}
+}
+
+func TestUse(t *testing.T) {
+	restore, err := testMachine().Use()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(restore)
+
+	sets, err := cpulists.ReadCPUSets()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sets.Present) != 8 || len(sets.Online) != 4 || len(sets.Isolated) != 2 {
+		t.Errorf("unexpected CPU sets: %+v", sets)
+	}
+	if _, err := os.Stat(filepath.Join(model.SysKernelIRQ, "24", "actions")); err != nil {
+		t.Errorf("expected the target IRQs to be used: %v", err)
+	}
+
+	// Validation against the target, not the local machine
+	if _, _, err := cpulists.ParseOnline("3"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, offline, _ := cpulists.ParseOnline("5"); len(offline) != 1 {
+		t.Errorf("expected CPU 5 to be offline")
+	}
+	if _, _, err := cpulists.ParseOnline("9"); err == nil {
+		t.Errorf("expected error for missing CPU")
+	}
+	if _, err := cpulists.Resolve("@siblings:2"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  model.Config
+		err  string
+	}{
+		{
+			name: "valid",
+			cfg: model.Config{
+				KernelCmdline: model.KernelCmdline{Parameters: []string{"nohz=on"}},
+				CpuGovernance: model.PwrMgmt{"rt": {CPUs: "2-3", ScalGov: "performance"}},
+				Hugepages:     model.Hugepages{"db": {Size: "2M", Count: 4, Nodes: "0"}},
+			},
+		},
+		{
+			name: "unavailable governor",
+			cfg: model.Config{
+				CpuGovernance: model.PwrMgmt{"rt": {CPUs: "2-3", ScalGov: "ondemand"}},
+			},
+			err: "scaling governor ondemand is not available",
+		},
+		{
+			name: "missing NUMA node",
+			cfg: model.Config{
+				Hugepages: model.Hugepages{"db": {Size: "2M", Count: 4, Nodes: "1"}},
+			},
+			err: "NUMA node 1 does not exist",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := testMachine().Check(&tc.cfg)
+			if tc.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckBootloader(t *testing.T) {
+	cfg := &model.Config{
+		KernelCmdline: model.KernelCmdline{Parameters: []string{"nohz=on"}},
+	}
+	m := testMachine()
+	m.Bootloader = "uboot"
+	if err := m.Check(cfg); err == nil || !strings.Contains(err.Error(), "not supported on uboot") {
+		t.Fatalf("expected unsupported bootloader error, got: %v", err)
+	}
+
+	m.Bootloader = "lilo"
+	if err := m.Check(cfg); err == nil || !strings.Contains(err.Error(), "invalid bootloader") {
+		t.Fatalf("expected invalid bootloader error, got: %v", err)
+	}
+
+	// No kernel parameters to apply
+	if err := m.Check(&model.Config{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMarshalStable(t *testing.T) {
+	first, err := testMachine().Marshal("json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range 5 {
+		again, err := testMachine().Marshal("json")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(again) != string(first) {
+			t.Fatalf("expected stable output, got:\n%s\nand:\n%s", first, again)
+		}
+	}
+}
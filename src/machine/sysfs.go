@@ -0,0 +1,171 @@
+package machine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+)
+
+// The machine description maps to the following files, relative to the
+// sysfs root:
+//
+//	devices/system/cpu/{possible,present,online,isolated,nohz_full}
+//	devices/system/cpu/cpuN/topology/{physical_package_id,thread_siblings_list}
+//	devices/system/cpu/cpuN/cpu_capacity
+//	devices/system/cpu/cpufreq/policyN/{related_cpus,scaling_driver,...}
+//	devices/system/cpu/cpuidle/{current_driver,current_governor}
+//	devices/system/cpu/cpuN/cpuidle/stateK/{name,latency,disable}
+//	devices/{cpu_core,cpu_atom}/cpus
+//	devices/system/node/nodeN/cpulist
+//	kernel/irq/N/actions
+//	kernel/realtime
+
+func cpuDir(root string) string {
+	return filepath.Join(root, "devices", "system", "cpu")
+}
+
+func writeFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write to %s file: %v", path, err)
+	}
+	return nil
+}
+
+// WriteSysfs renders the machine description as a fake sysfs tree
+func (m *Machine) WriteSysfs(root string) error {
+	files := map[string]string{
+		filepath.Join(cpuDir(root), "possible"): m.CPUs.Possible,
+		filepath.Join(cpuDir(root), "present"):  m.CPUs.Present,
+		filepath.Join(cpuDir(root), "online"):   m.CPUs.Online,
+		filepath.Join(cpuDir(root), "isolated"): m.CPUs.Isolated,
+	}
+	if m.CPUs.NohzFull != "" {
+		files[filepath.Join(cpuDir(root), "nohz_full")] = m.CPUs.NohzFull
+	}
+	if m.CPUs.PCores != "" {
+		files[filepath.Join(root, "devices", "cpu_core", "cpus")] = m.CPUs.PCores
+	}
+	if m.CPUs.ECores != "" {
+		files[filepath.Join(root, "devices", "cpu_atom", "cpus")] = m.CPUs.ECores
+	}
+	for _, topo := range m.CPUs.Topology {
+		dir := filepath.Join(cpuDir(root), fmt.Sprintf("cpu%d", topo.CPU))
+		files[filepath.Join(dir, "topology", "physical_package_id")] = strconv.Itoa(topo.Package)
+		files[filepath.Join(dir, "topology", "thread_siblings_list")] = topo.Siblings
+		if topo.Capacity != 0 {
+			files[filepath.Join(dir, "cpu_capacity")] = strconv.Itoa(topo.Capacity)
+		}
+	}
+	if m.Realtime {
+		files[filepath.Join(root, "kernel", "realtime")] = "1"
+	}
+	if err := m.cpuFreqFiles(root, files); err != nil {
+		return err
+	}
+	if err := m.cpuIdleFiles(root, files); err != nil {
+		return err
+	}
+	for node, cpus := range m.Nodes {
+		files[filepath.Join(root, "devices", "system", "node",
+			fmt.Sprintf("node%d", node), "cpulist")] = cpus
+	}
+	for _, irq := range m.IRQs {
+		files[filepath.Join(root, "kernel", "irq", strconv.Itoa(irq.Number), "actions")] = irq.Actions
+	}
+
+	for path, content := range files {
+		if err := writeFile(path, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Machine) cpuFreqFiles(root string, files map[string]string) error {
+	policies := m.CPUFreq
+	// Hand written descriptions may only list the governors
+	if len(policies) == 0 && len(m.Governors) > 0 {
+		policies = []CPUFreq{{Policy: 0, Governors: m.Governors}}
+	}
+	for _, policy := range policies {
+		dir := filepath.Join(cpuDir(root), "cpufreq", fmt.Sprintf("policy%d", policy.Policy))
+		cpus, err := cpuNumbers(policy.CPUs)
+		if err != nil {
+			return fmt.Errorf("invalid CPUs of policy%d: %v", policy.Policy, err)
+		}
+		related := make([]string, 0, len(cpus))
+		for _, cpu := range cpus {
+			related = append(related, strconv.Itoa(cpu))
+		}
+		files[filepath.Join(dir, "related_cpus")] = strings.Join(related, " ")
+		files[filepath.Join(dir, "scaling_driver")] = policy.Driver
+		files[filepath.Join(dir, "scaling_governor")] = policy.Governor
+		files[filepath.Join(dir, "scaling_available_governors")] = strings.Join(policy.Governors, " ")
+	}
+	return nil
+}
+
+func (m *Machine) cpuIdleFiles(root string, files map[string]string) error {
+	if m.CPUIdle == nil {
+		return nil
+	}
+	files[filepath.Join(cpuDir(root), "cpuidle", "current_driver")] = m.CPUIdle.Driver
+	files[filepath.Join(cpuDir(root), "cpuidle", "current_governor")] = m.CPUIdle.Governor
+
+	online, err := cpuNumbers(m.CPUs.Online)
+	if err != nil {
+		return fmt.Errorf("invalid online CPUs: %v", err)
+	}
+	for i, state := range m.CPUIdle.States {
+		disabled := make(cpulists.CPUs)
+		if state.Disabled != "" {
+			if disabled, err = cpulists.ParseForCPUs(state.Disabled, maxCPUs); err != nil {
+				return fmt.Errorf("invalid CPUs of idle state %s: %v", state.Name, err)
+			}
+		}
+		for _, cpu := range online {
+			dir := filepath.Join(cpuDir(root), fmt.Sprintf("cpu%d", cpu), "cpuidle",
+				fmt.Sprintf("state%d", i))
+			files[filepath.Join(dir, "name")] = state.Name
+			files[filepath.Join(dir, "latency")] = strconv.Itoa(state.Latency)
+			files[filepath.Join(dir, "disable")] = "0"
+			if disabled[cpu] {
+				files[filepath.Join(dir, "disable")] = "1"
+			}
+		}
+	}
+	return nil
+}
+
+// Use makes the validators read the machine description instead of the
+// local machine, until restore is called. The IRQ affinities under
+// model.ProcIRQ aren't part of the description, so it keeps pointing at
+// the local machine: only validation, which doesn't read it, may run in
+// between.
+func (m *Machine) Use() (restore func(), err error) {
+	root, err := os.MkdirTemp("", "rt-conf-target-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create target sysfs: %v", err)
+	}
+	if err := m.WriteSysfs(root); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	restoreCPUs := cpulists.SetSysfsRoot(root)
+	prevIRQ := model.SysKernelIRQ
+	model.SysKernelIRQ = filepath.Join(root, "kernel", "irq")
+	return func() {
+		restoreCPUs()
+		model.SysKernelIRQ = prevIRQ
+		os.RemoveAll(root)
+	}, nil
+}
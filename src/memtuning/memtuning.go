@@ -0,0 +1,141 @@
+package memtuning
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Documentation for the memory management files:
+// https://docs.kernel.org/admin-guide/mm/transhuge.html
+// https://docs.kernel.org/admin-guide/mm/ksm.html
+// https://docs.kernel.org/admin-guide/sysctl/vm.html#compaction-proactiveness
+
+type ReaderWriter struct {
+	MMPath      string
+	ProcSysPath string
+}
+
+var memTuningReaderWriter = ReaderWriter{
+	MMPath:      "/sys/kernel/mm",
+	ProcSysPath: "/proc/sys",
+}
+
+// setting is a value to be written to a memory management file
+type setting struct {
+	// Path of the file, relative to the ReaderWriter base path
+	path string
+	// Value to be written
+	value string
+	// Whether the file lists the available options, with the selected one
+	// in brackets, e.g. "always [madvise] never"
+	bracketed bool
+}
+
+// ParseOptions parses the content of a file in the bracketed selection
+// format, e.g. "always [madvise] never", returning the available options
+// and the selected one
+func ParseOptions(content string) (options []string, selected string, err error) {
+	for _, field := range strings.Fields(content) {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			if selected != "" {
+				return nil, "", fmt.Errorf("more than one option selected: %q", content)
+			}
+			field = strings.TrimSuffix(strings.TrimPrefix(field, "["), "]")
+			selected = field
+		}
+		options = append(options, field)
+	}
+	if selected == "" {
+		return nil, "", fmt.Errorf("no option selected: %q", content)
+	}
+	return options, selected, nil
+}
+
+// settings returns the memory management files to be written, in order
+func (rw ReaderWriter) settings(cfg model.MemoryTuning) []setting {
+	var settings []setting
+	thp := filepath.Join(rw.MMPath, "transparent_hugepage")
+	if cfg.THPEnabled != "" {
+		settings = append(settings,
+			setting{filepath.Join(thp, "enabled"), cfg.THPEnabled, true})
+	}
+	if cfg.THPDefrag != "" {
+		settings = append(settings,
+			setting{filepath.Join(thp, "defrag"), cfg.THPDefrag, true})
+	}
+	if cfg.KhugepagedDefrag != nil {
+		settings = append(settings, setting{filepath.Join(thp, "khugepaged", "defrag"),
+			strconv.Itoa(*cfg.KhugepagedDefrag), false})
+	}
+	if cfg.KSMRun != nil {
+		settings = append(settings, setting{filepath.Join(rw.MMPath, "ksm", "run"),
+			strconv.Itoa(*cfg.KSMRun), false})
+	}
+	if cfg.CompactionProactiveness != nil {
+		settings = append(settings, setting{
+			filepath.Join(rw.ProcSysPath, "vm", "compaction_proactiveness"),
+			strconv.Itoa(*cfg.CompactionProactiveness), false})
+	}
+	return settings
+}
+
+// apply writes a setting, returning the value found before writing it
+func (s setting) apply() (string, error) {
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %v", s.path, err)
+	}
+	previous := strings.TrimSpace(string(content))
+
+	if s.bracketed {
+		options, selected, err := ParseOptions(previous)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s: %v", s.path, err)
+		}
+		if !slices.Contains(options, s.value) {
+			return "", fmt.Errorf("%q is not supported by %s, available options: %s",
+				s.value, s.path, strings.Join(options, ", "))
+		}
+		previous = selected
+	}
+
+	if previous == s.value {
+		return previous, nil
+	}
+	return previous, utils.WriteOnly(s.path, s.value)
+}
+
+func ApplyMemoryTuningConfig(config *model.InternalConfig) error {
+	utils.PrintTitle("Memory Tuning")
+	if config.Data.MemoryTuning.IsEmpty() {
+		log.Println("No memory tuning options found in config")
+		return nil
+	}
+	return memTuningReaderWriter.applyMemoryTuningConfig(config.Data.MemoryTuning)
+}
+
+// Apply changes based on YAML config
+func (rw ReaderWriter) applyMemoryTuningConfig(cfg model.MemoryTuning) error {
+	var msgs []string
+	for _, s := range rw.settings(cfg) {
+		previous, err := s.apply()
+		if err != nil {
+			return err
+		}
+		if previous == s.value {
+			msgs = append(msgs, fmt.Sprintf("%s is already %s", s.path, s.value))
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("Set %s to %s (was %s)", s.path, s.value, previous))
+	}
+	utils.LogTreeStyle(msgs)
+	return nil
+}
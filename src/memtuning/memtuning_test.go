@@ -0,0 +1,123 @@
+package memtuning
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+// setupMMDir creates fake /sys/kernel/mm and /proc/sys directories
+func setupMMDir(t *testing.T) ReaderWriter {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	rw := ReaderWriter{
+		MMPath:      filepath.Join(tmpDir, "mm"),
+		ProcSysPath: filepath.Join(tmpDir, "sys"),
+	}
+	files := map[string]string{
+		"mm/transparent_hugepage/enabled":           "always [madvise] never\n",
+		"mm/transparent_hugepage/defrag":            "always defer defer+madvise [madvise] never\n",
+		"mm/transparent_hugepage/khugepaged/defrag": "1\n",
+		"mm/ksm/run":                      "1\n",
+		"sys/vm/compaction_proactiveness": "20\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	return rw
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func TestParseOptions(t *testing.T) {
+	options, selected, err := ParseOptions("always [madvise] never")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if selected != "madvise" || strings.Join(options, " ") != "always madvise never" {
+		t.Errorf("unexpected result: %v, %q", options, selected)
+	}
+
+	for _, content := range []string{"always madvise never", "[always] [never]"} {
+		if _, _, err := ParseOptions(content); err == nil {
+			t.Errorf("expected error for %q", content)
+		}
+	}
+}
+
+func TestApplyMemoryTuningConfig(t *testing.T) {
+	rw := setupMMDir(t)
+	zero := 0
+	two := 2
+
+	err := rw.applyMemoryTuningConfig(model.MemoryTuning{
+		THPEnabled:              "never",
+		THPDefrag:               "madvise",
+		KhugepagedDefrag:        &zero,
+		KSMRun:                  &two,
+		CompactionProactiveness: &zero,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"transparent_hugepage/enabled":           "never",
+		"transparent_hugepage/khugepaged/defrag": "0",
+		"ksm/run":                                "2",
+		// Already selected, so left untouched
+		"transparent_hugepage/defrag": "always defer defer+madvise [madvise] never",
+	}
+	for name, want := range expected {
+		if got := readFile(t, filepath.Join(rw.MMPath, name)); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+	got := readFile(t, filepath.Join(rw.ProcSysPath, "vm", "compaction_proactiveness"))
+	if got != "0" {
+		t.Errorf("compaction_proactiveness: expected %q, got %q", "0", got)
+	}
+}
+
+func TestApplyMemoryTuningConfigUnsupportedOption(t *testing.T) {
+	rw := setupMMDir(t)
+	path := filepath.Join(rw.MMPath, "transparent_hugepage", "defrag")
+	// Kernel without the defer modes
+	if err := os.WriteFile(path, []byte("always [madvise] never\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	err := rw.applyMemoryTuningConfig(model.MemoryTuning{THPDefrag: "defer"})
+	if err == nil || !strings.Contains(err.Error(),
+		"available options: always, madvise, never") {
+		t.Fatalf("expected unsupported option error, got: %v", err)
+	}
+}
+
+func TestApplyMemoryTuningConfigMissingFile(t *testing.T) {
+	rw := ReaderWriter{MMPath: t.TempDir(), ProcSysPath: t.TempDir()}
+	two := 2
+
+	err := rw.applyMemoryTuningConfig(model.MemoryTuning{KSMRun: &two})
+	if err == nil || !strings.Contains(err.Error(), "error reading") {
+		t.Fatalf("expected read error, got: %v", err)
+	}
+}
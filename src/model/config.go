@@ -53,14 +53,31 @@ func (c *Config) LoadFromFile(confPath string) error {
 	return nil
 }
 
-// LoadSnapOptions reads IRQ and CPU governance objects from snap options
+// snapctlGet returns the document of the given snap options. It is a
+// variable, so tests can fake the snap options.
+var snapctlGet = func(keys ...string) (string, error) {
+	return snapctl.Get(keys...).Document().Run()
+}
+
+// LoadSnapOptions reads IRQ, CPU governance, uncore frequency, CPU idle,
+// PM QoS, CPU hotplug, network steering, sysctl, memory tuning, hugepages and
+// systemd affinity objects from snap options
 // When a value is set, the whole object gets overridden.
 func (c *Config) LoadSnapOptions() error {
-	value, err := snapctl.Get(
+	value, err := snapctlGet(
 		"kernel-cmdline",
+		"irq-affinity",
 		"irq-tuning",
 		"cpu-governance",
-	).Document().Run()
+		"uncore-frequency",
+		"cpu-idle",
+		"pm-qos",
+		"cpu-hotplug",
+		"network-steering",
+		"memory-tuning",
+		"hugepages",
+		"systemd-affinity",
+	)
 	if err != nil {
 		return fmt.Errorf("failed to get snap option: %v", err)
 	}
@@ -74,18 +91,60 @@ func (c *Config) LoadSnapOptions() error {
 		return fmt.Errorf("failed to unmarshal snap options: %v", err)
 	}
 
+	sysctl, err := loadSnapSysctl()
+	if err != nil {
+		return err
+	}
+
 	// reject kernel command line arguments
 	if len(confOptions.KernelCmdline.Parameters) > 0 {
 		return fmt.Errorf("kernel-cmdline snap option is not supported, use the config file instead")
 	}
 
+	for label, rule := range confOptions.Hugepages {
+		if rule.KernelCmdline {
+			return fmt.Errorf("kernel-cmdline of hugepages rule #%s is not supported "+
+				"via snap options, use the config file instead", label)
+		}
+	}
+
 	// override full objects
+	if !confOptions.IRQAffinity.IsEmpty() {
+		c.IRQAffinity = confOptions.IRQAffinity
+	}
 	if len(confOptions.Interrupts) > 0 {
 		c.Interrupts = confOptions.Interrupts
 	}
 	if len(confOptions.CpuGovernance) > 0 {
 		c.CpuGovernance = confOptions.CpuGovernance
 	}
+	if len(confOptions.UncoreFreq) > 0 {
+		c.UncoreFreq = confOptions.UncoreFreq
+	}
+	if len(confOptions.CpuIdle) > 0 {
+		c.CpuIdle = confOptions.CpuIdle
+	}
+	if !confOptions.PmQos.IsEmpty() {
+		c.PmQos = confOptions.PmQos
+	}
+	if len(confOptions.CpuHotplug) > 0 {
+		c.CpuHotplug = confOptions.CpuHotplug
+	}
+	if len(confOptions.NetSteering) > 0 {
+		c.NetSteering = confOptions.NetSteering
+	}
+	if len(sysctl) > 0 {
+		c.Sysctl = sysctl
+	}
+	if !confOptions.MemoryTuning.IsEmpty() {
+		c.MemoryTuning = confOptions.MemoryTuning
+	}
+	if len(confOptions.Hugepages) > 0 {
+		c.Hugepages = confOptions.Hugepages
+	}
+	if !confOptions.Systemd.IsEmpty() {
+		c.Systemd = confOptions.Systemd
+	}
 
 	err = c.Validate()
 	if err != nil {
@@ -94,3 +153,38 @@ func (c *Config) LoadSnapOptions() error {
 
 	return nil
 }
+
+// loadSnapSysctl reads the sysctl object from snap options. Snap options nest
+// the dotted keys, e.g. sysctl.vm.swappiness=10 is read as
+// {"vm": {"swappiness": 10}}, so the keys are flattened back.
+func loadSnapSysctl() (Sysctl, error) {
+	value, err := snapctlGet("sysctl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snap option: %v", err)
+	}
+
+	var options struct {
+		Sysctl map[string]any `yaml:"sysctl"`
+	}
+	err = yaml.Unmarshal([]byte(value), &options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sysctl snap option: %v", err)
+	}
+
+	sysctl := make(Sysctl)
+	flattenSysctl("", options.Sysctl, sysctl)
+	return sysctl, nil
+}
+
+func flattenSysctl(prefix string, values map[string]any, sysctl Sysctl) {
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok {
+			flattenSysctl(key, nested, sysctl)
+			continue
+		}
+		sysctl[key] = fmt.Sprint(value)
+	}
+}
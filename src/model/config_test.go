@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -131,3 +132,69 @@ kernel-cmdline:
 		})
 	}
 }
+
+// setupSnapOptions fakes the snap options, returning the given document for
+// the sysctl option and no other options
+func setupSnapOptions(t *testing.T, sysctl string) {
+	t.Helper()
+
+	prev := snapctlGet
+	t.Cleanup(func() { snapctlGet = prev })
+	snapctlGet = func(keys ...string) (string, error) {
+		if slices.Contains(keys, "sysctl") {
+			return sysctl, nil
+		}
+		return "{}", nil
+	}
+}
+
+func TestLoadSnapOptionsSysctl(t *testing.T) {
+	testCases := []struct {
+		name     string
+		document string
+		expected Sysctl
+		err      string
+	}{
+		{
+			name:     "nested keys",
+			document: `{"sysctl": {"kernel": {"timer_migration": 0}, "vm": {"swappiness": "10"}}}`,
+			expected: Sysctl{"kernel.timer_migration": "0", "vm.swappiness": "10"},
+		},
+		{
+			name:     "dotted keys",
+			document: `{"sysctl": {"vm.stat_interval": 10}}`,
+			expected: Sysctl{"vm.stat_interval": "10"},
+		},
+		{
+			name:     "not set",
+			document: `{}`,
+			expected: Sysctl{"vm.swappiness": "60"},
+		},
+		{
+			name:     "unsupported key",
+			document: `{"sysctl": {"net": {"core": {"somaxconn": 1024}}}}`,
+			err:      `unsupported sysctl key: "net.core.somaxconn"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setupSnapOptions(t, tc.document)
+
+			c := Config{Sysctl: Sysctl{"vm.swappiness": "60"}}
+			err := c.LoadSnapOptions()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(c.Sysctl, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, c.Sysctl)
+			}
+		})
+	}
+}
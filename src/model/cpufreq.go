@@ -0,0 +1,174 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+// CPUs which share a cpufreq policy share their scaling governor and
+// frequency limits, so writing to any of them changes all of them.
+// See: https://docs.kernel.org/admin-guide/pm/cpufreq.html#policy-interface-in-sysfs
+
+// Policy represents a cpufreq policy and the CPUs it manages
+type Policy struct {
+	ID   int
+	CPUs []int
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("policy%d (CPUs %s)", p.ID, cpulists.GenCPUlist(p.CPUs))
+}
+
+// Policies maps each CPU to its cpufreq policy
+type Policies map[int]Policy
+
+// ReadPolicies reads the cpufreq policies from a sysfs directory, e.g.
+// /sys/devices/system/cpu/cpufreq.
+// CPUs which don't belong to any policy are considered to have their own.
+func ReadPolicies(dir string) (Policies, error) {
+	policies := make(Policies)
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return policies, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", dir, err)
+	}
+
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "policy"))
+		if err != nil || !strings.HasPrefix(entry.Name(), "policy") {
+			continue
+		}
+
+		relatedCPUsFile := filepath.Join(dir, entry.Name(), "related_cpus")
+		content, err := os.ReadFile(relatedCPUsFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", relatedCPUsFile, err)
+		}
+
+		policy := Policy{ID: id}
+		for _, field := range strings.Fields(string(content)) {
+			cpu, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CPU in %s: %v",
+					relatedCPUsFile, err)
+			}
+			policy.CPUs = append(policy.CPUs, cpu)
+		}
+		sort.Ints(policy.CPUs)
+
+		for _, cpu := range policy.CPUs {
+			policies[cpu] = policy
+		}
+	}
+	return policies, nil
+}
+
+// Of returns the policy of a CPU
+func (p Policies) Of(cpu int) Policy {
+	if policy, ok := p[cpu]; ok {
+		return policy
+	}
+	return Policy{ID: cpu, CPUs: []int{cpu}}
+}
+
+// conflictingSetting returns the name of the first policy setting which
+// both rules set, to different values, or an empty string
+func conflictingSetting(a, b CpuGovernanceRule) (string, error) {
+	if a.ScalGov != "" && b.ScalGov != "" && a.ScalGov != b.ScalGov {
+		return "scaling-governor", nil
+	}
+
+	freqs := []struct {
+		name   string
+		values [2]string
+	}{
+		{"min-freq", [2]string{a.MinFreq, b.MinFreq}},
+		{"max-freq", [2]string{a.MaxFreq, b.MaxFreq}},
+	}
+	for _, freq := range freqs {
+		first, err := ParseFreq(freq.values[0])
+		if err != nil {
+			return "", err
+		}
+		second, err := ParseFreq(freq.values[1])
+		if err != nil {
+			return "", err
+		}
+		if first != -1 && second != -1 && first != second {
+			return freq.name, nil
+		}
+	}
+	return "", nil
+}
+
+// ValidatePolicies fails when rules with the same priority set a setting
+// to different values on CPUs which belong to the same policy, since only
+// one of them could take effect. It returns the policies set by more than
+// one rule, along with the rule which wins over the others.
+func (p PwrMgmt) ValidatePolicies(policies Policies) ([]string, error) {
+	// Rules setting each policy, in the order they are applied
+	setBy := make(map[int][]string)
+	byID := make(map[int]Policy)
+
+	for _, label := range p.Labels() {
+		rule := p[label]
+		cpus, err := cpulists.Parse(rule.CPUs)
+		if err != nil {
+			return nil, err
+		}
+
+		for cpu := range cpus {
+			policy := policies.Of(cpu)
+			if slices.Contains(setBy[policy.ID], label) {
+				continue
+			}
+			for _, other := range setBy[policy.ID] {
+				if p[other].Priority != rule.Priority {
+					continue
+				}
+				setting, err := conflictingSetting(p[other], rule)
+				if err != nil {
+					return nil, err
+				}
+				if setting != "" {
+					return nil, fmt.Errorf(
+						"rules #%s and #%s conflict on cpufreq %s: "+
+							"both set %s, to different values, but CPUs in the same "+
+							"policy share their scaling governor and frequency limits, "+
+							"so they must set the same value or have different priorities",
+						other, label, policy, setting)
+				}
+			}
+			setBy[policy.ID] = append(setBy[policy.ID], label)
+			byID[policy.ID] = policy
+		}
+	}
+
+	ids := make([]int, 0, len(setBy))
+	for id, labels := range setBy {
+		if len(labels) > 1 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	overlaps := make([]string, 0, len(ids))
+	for _, id := range ids {
+		labels := setBy[id]
+		overlaps = append(overlaps, fmt.Sprintf("cpufreq %s set by rules #%s, #%s won",
+			byID[id], strings.Join(labels, ", #"),
+			labels[len(labels)-1]))
+	}
+	return overlaps, nil
+}
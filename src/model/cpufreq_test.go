@@ -0,0 +1,137 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+// setupSysfs creates a fake sysfs root with CPUs 0-3 and the given files,
+// relative to the CPUs directory
+func setupSysfs(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	t.Cleanup(cpulists.SetSysfsRoot(t.TempDir()))
+	files["possible"] = "0-3\n"
+	files["present"] = "0-3\n"
+	files["online"] = "0-3\n"
+	for name, content := range files {
+		path := filepath.Join(cpulists.SysCPUPath(), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+}
+
+func TestValidatePolicies(t *testing.T) {
+	setupSysfs(t, map[string]string{})
+
+	// CPU 0 shares its policy with CPUs 1-3
+	shared := Policy{ID: 0, CPUs: []int{0, 1, 2, 3}}
+	policies := Policies{0: shared, 1: shared, 2: shared, 3: shared}
+
+	testCases := []struct {
+		name     string
+		rules    PwrMgmt
+		overlaps []string
+		err      string
+	}{
+		{
+			name: "single rule",
+			rules: PwrMgmt{
+				"foo": {CPUs: "0", ScalGov: "performance"},
+			},
+		},
+		{
+			name: "rules with the same settings",
+			rules: PwrMgmt{
+				"foo": {CPUs: "0", ScalGov: "performance", MaxFreq: "2GHz"},
+				"bar": {CPUs: "0", ScalGov: "performance", MaxFreq: "2000MHz"},
+			},
+			overlaps: []string{
+				"cpufreq policy0 (CPUs 0-3) set by rules #bar, #foo, #foo won",
+			},
+		},
+		{
+			name: "conflicting rules with different priorities",
+			rules: PwrMgmt{
+				"foo": {CPUs: "0", ScalGov: "performance", Priority: 10},
+				"bar": {CPUs: "0", ScalGov: "powersave"},
+			},
+			overlaps: []string{
+				"cpufreq policy0 (CPUs 0-3) set by rules #bar, #foo, #foo won",
+			},
+		},
+		{
+			name: "rules setting different settings",
+			rules: PwrMgmt{
+				"foo": {CPUs: "0", ScalGov: "performance"},
+				"bar": {CPUs: "1", MaxFreq: "2GHz"},
+			},
+			overlaps: []string{
+				"cpufreq policy0 (CPUs 0-3) set by rules #bar, #foo, #foo won",
+			},
+		},
+		{
+			name: "conflicting rules",
+			rules: PwrMgmt{
+				"foo": {CPUs: "0", ScalGov: "performance"},
+				"bar": {CPUs: "0", ScalGov: "powersave"},
+			},
+			err: "rules #bar and #foo conflict on cpufreq policy0 (CPUs 0-3): " +
+				"both set scaling-governor",
+		},
+		{
+			name: "conflicting frequencies",
+			rules: PwrMgmt{
+				"foo": {CPUs: "0", ScalGov: "performance", MinFreq: "1GHz"},
+				"bar": {CPUs: "2", ScalGov: "performance", MinFreq: "800MHz"},
+			},
+			err: "both set min-freq",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			overlaps, err := tc.rules.ValidatePolicies(policies)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(overlaps, tc.overlaps) &&
+					(len(overlaps) != 0 || len(tc.overlaps) != 0) {
+					t.Fatalf("expected overlaps %v, got %v", tc.overlaps, overlaps)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+// The policy conflicts are caught when validating the config
+func TestValidateCpufreqPolicies(t *testing.T) {
+	setupSysfs(t, map[string]string{
+		"cpufreq/policy0/related_cpus": "0 1\n",
+	})
+
+	c := Config{
+		CpuGovernance: PwrMgmt{
+			"foo": {CPUs: "0", ScalGov: "performance"},
+			"bar": {CPUs: "1", ScalGov: "powersave"},
+		},
+	}
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "conflict on cpufreq policy0") {
+		t.Fatalf("expected policy conflict, got: %v", err)
+	}
+}
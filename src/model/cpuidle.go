@@ -0,0 +1,49 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+// Regex for valid idle state names, as exposed by cpuidle drivers
+// e.g. POLL, C1, C1E, C6, WFI
+var validIdleStateName = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+type CpuIdleRule struct {
+	CPUs string `yaml:"cpus"`
+	// Names of the idle states to be disabled
+	DisableStates []string `yaml:"disable-states"`
+	// Disable all idle states with an exit latency (in microseconds)
+	// greater than this value
+	MaxLatency *int `yaml:"max-latency"`
+}
+
+// Labels returns the labels of the CPU idle rules in the order they are
+// applied
+func (c CpuIdle) Labels() []string {
+	return sortedLabels(c)
+}
+
+func (c CpuIdleRule) Validate() error {
+	if _, err := cpulists.Parse(c.CPUs); err != nil {
+		return err
+	}
+
+	if len(c.DisableStates) == 0 && c.MaxLatency == nil {
+		return fmt.Errorf("either disable-states or max-latency must be set")
+	}
+
+	for _, state := range c.DisableStates {
+		if !validIdleStateName.MatchString(state) {
+			return fmt.Errorf("invalid idle state name: %q", state)
+		}
+	}
+
+	if c.MaxLatency != nil && *c.MaxLatency < 0 {
+		return fmt.Errorf("max latency cannot be negative: %d", *c.MaxLatency)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCpuIdleValidation(t *testing.T) {
+	latency := 10
+	negativeLatency := -1
+
+	tests := []struct {
+		name    string
+		rule    CpuIdleRule
+		wantErr string
+	}{
+		{
+			name: "valid state names",
+			rule: CpuIdleRule{
+				CPUs:          "0",
+				DisableStates: []string{"C1E", "C6"},
+			},
+		},
+		{
+			name: "valid max latency",
+			rule: CpuIdleRule{
+				CPUs:       "0",
+				MaxLatency: &latency,
+			},
+		},
+		{
+			name: "invalid cpulist",
+			rule: CpuIdleRule{
+				CPUs:          "zz",
+				DisableStates: []string{"C6"},
+			},
+			wantErr: "invalid CPU: zz",
+		},
+		{
+			name: "nothing to disable",
+			rule: CpuIdleRule{
+				CPUs: "0",
+			},
+			wantErr: "either disable-states or max-latency must be set",
+		},
+		{
+			name: "invalid state name",
+			rule: CpuIdleRule{
+				CPUs:          "0",
+				DisableStates: []string{"C6 C8"},
+			},
+			wantErr: "invalid idle state name",
+		},
+		{
+			name: "negative max latency",
+			rule: CpuIdleRule{
+				CPUs:       "0",
+				MaxLatency: &negativeLatency,
+			},
+			wantErr: "max latency cannot be negative",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rule.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestCpuIdleLabels(t *testing.T) {
+	rules := CpuIdle{
+		"web":   {CPUs: "0"},
+		"audio": {CPUs: "1"},
+		"rt":    {CPUs: "2"},
+	}
+	expected := []string{"audio", "rt", "web"}
+	if got := rules.Labels(); !slices.Equal(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
@@ -2,24 +2,67 @@ package model
 
 import (
 	"fmt"
+	"path/filepath"
 	"regexp"
+	"sort"
+
+	"github.com/canonical/rt-conf/src/cpulists"
 )
 
 type InternalConfig struct {
 	Data Config
 
 	GrubCfg Grub
+
+	// Path to the generated irqbalance configuration file, if any
+	IrqbalanceCfgFile string
+
+	// Path to the generated sysctl drop-in configuration file, if any
+	SysctlDropInFile string
+
+	// Directory of the systemd configuration, in which the drop-in files
+	// are created, e.g. /etc/systemd
+	SystemdDir string
+
+	// Strict mode turns runtime mismatches into errors
+	Strict bool
 }
 
 type (
-	PwrMgmt    map[string]CpuGovernanceRule
-	Interrupts map[string]IRQTuning
+	PwrMgmt     map[string]CpuGovernanceRule
+	Interrupts  map[string]IRQTuning
+	CpuIdle     map[string]CpuIdleRule
+	CpuHotplug  map[string]CpuHotplugRule
+	UncoreFreq  map[string]UncoreFreqRule
+	NetSteering map[string]NetSteeringRule
+	Hugepages   map[string]HugepagesRule
 )
 
+// sortedLabels returns the labels of rules applied in ascending order of
+// label
+func sortedLabels[T any](rules map[string]T) []string {
+	labels := make([]string, 0, len(rules))
+	for label := range rules {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
 type Config struct {
-	KernelCmdline KernelCmdline `yaml:"kernel-cmdline"`
-	Interrupts    Interrupts    `yaml:"irq-tuning"`
-	CpuGovernance PwrMgmt       `yaml:"cpu-governance"`
+	KernelCmdline KernelCmdline   `yaml:"kernel-cmdline"`
+	IRQAffinity   IRQs            `yaml:"irq-affinity"`
+	Interrupts    Interrupts      `yaml:"irq-tuning"`
+	CpuGovernance PwrMgmt         `yaml:"cpu-governance"`
+	CpuIdle       CpuIdle         `yaml:"cpu-idle"`
+	PmQos         PmQos           `yaml:"pm-qos"`
+	CpuHotplug    CpuHotplug      `yaml:"cpu-hotplug"`
+	UncoreFreq    UncoreFreq      `yaml:"uncore-frequency"`
+	NetSteering   NetSteering     `yaml:"network-steering"`
+	Sysctl        Sysctl          `yaml:"sysctl"`
+	MemoryTuning  MemoryTuning    `yaml:"memory-tuning"`
+	Hugepages     Hugepages       `yaml:"hugepages"`
+	Systemd       SystemdAffinity `yaml:"systemd-affinity"`
 }
 
 // Regex for valid snap options from snapd:
@@ -27,10 +70,26 @@ type Config struct {
 var validRuleName = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
 
 func (c Config) Validate() error {
-	err := c.KernelCmdline.Validate()
+	err := c.BootKernelCmdline().Validate()
 	if err != nil {
 		return fmt.Errorf("failed to validate kernel cmdline: %v", err)
 	}
+	if err := c.Sysctl.Validate(); err != nil {
+		return fmt.Errorf("failed to validate sysctl: %v", err)
+	}
+	if err := c.MemoryTuning.Validate(); err != nil {
+		return fmt.Errorf("failed to validate memory tuning: %v", err)
+	}
+	if err := c.Hugepages.Validate(); err != nil {
+		return fmt.Errorf("failed to validate hugepages: %v", err)
+	}
+	if err := c.Systemd.Validate(); err != nil {
+		return fmt.Errorf("failed to validate systemd affinity: %v", err)
+	}
+	if err := c.IRQAffinity.Validate(); err != nil {
+		return fmt.Errorf("failed to validate irq affinity: %v", err)
+	}
+
 	for label, irq := range c.Interrupts {
 		if !validRuleName.MatchString(label) {
 			return fmt.Errorf("invalid rule name: %q", label)
@@ -51,6 +110,71 @@ func (c Config) Validate() error {
 				"failed to validate cpu governance rule #%s: %s", label, err)
 		}
 	}
+	if len(c.CpuGovernance) > 0 {
+		policies, err := ReadPolicies(
+			filepath.Join(cpulists.SysCPUPath(), "cpufreq"))
+		if err != nil {
+			return fmt.Errorf("failed to read cpufreq policies: %v", err)
+		}
+		if _, err := c.CpuGovernance.ValidatePolicies(policies); err != nil {
+			return fmt.Errorf("failed to validate cpu governance: %v", err)
+		}
+	}
+
+	for label, idle := range c.CpuIdle {
+		if !validRuleName.MatchString(label) {
+			return fmt.Errorf("invalid rule name: %q", label)
+		}
+		err := idle.Validate()
+		if err != nil {
+			return fmt.Errorf(
+				"failed to validate cpu idle rule #%s: %s", label, err)
+		}
+	}
+
+	for label, uncore := range c.UncoreFreq {
+		if !validRuleName.MatchString(label) {
+			return fmt.Errorf("invalid rule name: %q", label)
+		}
+		err := uncore.Validate()
+		if err != nil {
+			return fmt.Errorf(
+				"failed to validate uncore frequency rule #%s: %s", label, err)
+		}
+	}
+
+	for label, steering := range c.NetSteering {
+		if !validRuleName.MatchString(label) {
+			return fmt.Errorf("invalid rule name: %q", label)
+		}
+		err := steering.Validate()
+		if err != nil {
+			return fmt.Errorf(
+				"failed to validate network steering rule #%s: %s", label, err)
+		}
+	}
+
+	if err := c.PmQos.Validate(); err != nil {
+		return fmt.Errorf("failed to validate pm qos: %v", err)
+	}
+
+	for label, hotplug := range c.CpuHotplug {
+		if !validRuleName.MatchString(label) {
+			return fmt.Errorf("invalid rule name: %q", label)
+		}
+		err := hotplug.Validate()
+		if err != nil {
+			return fmt.Errorf(
+				"failed to validate cpu hotplug rule #%s: %s", label, err)
+		}
+	}
+
+	if err := c.validateOfflineCPUs(); err != nil {
+		return fmt.Errorf("failed to validate cpu hotplug: %v", err)
+	}
+	if err := c.warnOfflineCPUs(); err != nil {
+		return fmt.Errorf("failed to validate cpu hotplug: %v", err)
+	}
 
 	return nil
 }
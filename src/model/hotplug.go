@@ -0,0 +1,206 @@
+package model
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+const (
+	CpuOnline  = "online"
+	CpuOffline = "offline"
+)
+
+type CpuHotplugRule struct {
+	CPUs string `yaml:"cpus"`
+	// Either "online" or "offline"
+	State string `yaml:"state"`
+}
+
+func (c CpuHotplugRule) Validate() error {
+	cpus, err := cpulists.Parse(c.CPUs)
+	if err != nil {
+		return err
+	}
+
+	switch c.State {
+	case CpuOnline:
+	case CpuOffline:
+		if cpus[0] {
+			return fmt.Errorf("CPU 0 cannot be set offline")
+		}
+	default:
+		return fmt.Errorf("invalid state: %q, expected %q or %q",
+			c.State, CpuOnline, CpuOffline)
+	}
+	return nil
+}
+
+// Labels returns the labels of the CPU hotplug rules in the order they are
+// applied
+func (c CpuHotplug) Labels() []string {
+	return sortedLabels(c)
+}
+
+// expandsToCPUs returns true if a CPU list is expanded to the CPUs of the
+// machine, e.g. all or 0-N, instead of naming them. The offline CPUs are
+// skipped from such lists at runtime.
+func expandsToCPUs(cpuList string) bool {
+	for _, item := range strings.Split(cpuList, ",") {
+		item = strings.TrimSpace(item)
+		if item == "all" || strings.Contains(item, "N") {
+			return true
+		}
+	}
+	return cpulists.HasSelectors(cpuList)
+}
+
+// validateOfflineCPUs makes sure that CPUs set offline by hotplug rules
+// aren't also set online, and aren't named by the kernel command line or by
+// the rules applied after CPU hotplug
+func (c Config) validateOfflineCPUs() error {
+	online := make(map[int]string)
+	offline := make(map[int]string)
+	for _, label := range c.CpuHotplug.Labels() {
+		rule := c.CpuHotplug[label]
+		cpus, err := cpulists.Parse(rule.CPUs)
+		if err != nil {
+			return err
+		}
+		states := online
+		if rule.State == CpuOffline {
+			states = offline
+		}
+		for cpu := range cpus {
+			states[cpu] = label
+		}
+	}
+	if len(offline) == 0 {
+		return nil
+	}
+
+	for _, cpu := range sortedKeys(offline) {
+		if label, ok := online[cpu]; ok {
+			return fmt.Errorf("CPU %d is set both offline by rule #%s "+
+				"and online by rule #%s", cpu, offline[cpu], label)
+		}
+	}
+
+	// CPUs which must stay online, mapped to the settings which need them
+	inUse := make(map[string]string)
+	for _, p := range c.KernelCmdline.Parameters {
+		key, value, found := strings.Cut(p, "=")
+		if !found || (key != "irqaffinity" && key != "kthread_cpus") {
+			continue
+		}
+		inUse[key] = value
+	}
+	for user, cpuList := range c.runtimeCPULists() {
+		inUse[user] = cpuList
+	}
+
+	for _, user := range namingUsers(inUse) {
+		cpus, err := cpulists.Parse(inUse[user])
+		if err != nil {
+			return err
+		}
+		for _, cpu := range sortedKeys(offline) {
+			if cpus[cpu] {
+				return fmt.Errorf("CPU %d cannot be set offline: used by %s",
+					cpu, user)
+			}
+		}
+	}
+	return nil
+}
+
+// runtimeCPULists maps the settings of the rules applied after CPU hotplug
+// to their CPU lists
+func (c Config) runtimeCPULists() map[string]string {
+	lists := make(map[string]string)
+	lists["irq-affinity handle-on-cpus"] = c.IRQAffinity.IRQHandler
+	for label, rule := range c.Interrupts {
+		lists["irq-tuning rule #"+label] = rule.CPUs
+	}
+	for label, rule := range c.CpuGovernance {
+		lists["cpu-governance rule #"+label] = rule.CPUs
+	}
+	for label, rule := range c.CpuIdle {
+		lists["cpu-idle rule #"+label] = rule.CPUs
+	}
+	for label, rule := range c.PmQos.ResumeLatency {
+		lists["pm-qos resume-latency rule #"+label] = rule.CPUs
+	}
+	for label, rule := range c.NetSteering {
+		lists["network-steering rule #"+label+" rps-cpus"] = rule.RPSCPUs
+		lists["network-steering rule #"+label+" xps-cpus"] = rule.XPSCPUs
+	}
+	lists["systemd-affinity"] = c.Systemd.CPUs
+	return lists
+}
+
+// namingUsers returns the settings which name their CPUs, sorted
+func namingUsers(lists map[string]string) []string {
+	users := make([]string, 0, len(lists))
+	for user, cpuList := range lists {
+		if cpuList != "" && !expandsToCPUs(cpuList) {
+			users = append(users, user)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// warnOfflineCPUs warns about the CPUs named by the rules applied after CPU
+// hotplug which are offline in the machine, and not set online by a hotplug
+// rule, as they are skipped at runtime
+func (c Config) warnOfflineCPUs() error {
+	sets, err := cpulists.ReadCPUSets()
+	if err != nil {
+		return fmt.Errorf("failed to read CPU sets: %v", err)
+	}
+	setOnline := make(cpulists.CPUs)
+	for _, rule := range c.CpuHotplug {
+		if rule.State != CpuOnline {
+			continue
+		}
+		cpus, err := cpulists.Parse(rule.CPUs)
+		if err != nil {
+			return err
+		}
+		for cpu := range cpus {
+			setOnline[cpu] = true
+		}
+	}
+
+	lists := c.runtimeCPULists()
+	for _, user := range namingUsers(lists) {
+		cpus, err := cpulists.Parse(lists[user])
+		if err != nil {
+			return err
+		}
+		var offline []int
+		for cpu := range cpus {
+			if !sets.Online[cpu] && !setOnline[cpu] {
+				offline = append(offline, cpu)
+			}
+		}
+		sort.Ints(offline)
+		for _, cpu := range offline {
+			log.Printf("Warning: CPU %d is offline, %s skips it\n", cpu, user)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(cpus map[int]string) []int {
+	keys := make([]int, 0, len(cpus))
+	for cpu := range cpus {
+		keys = append(keys, cpu)
+	}
+	sort.Ints(keys)
+	return keys
+}
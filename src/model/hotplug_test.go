@@ -0,0 +1,225 @@
+package model
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+func TestCpuHotplugValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    CpuHotplugRule
+		wantErr string
+	}{
+		{
+			name: "online",
+			rule: CpuHotplugRule{CPUs: "0", State: CpuOnline},
+		},
+		{
+			name:    "offline CPU 0",
+			rule:    CpuHotplugRule{CPUs: "0", State: CpuOffline},
+			wantErr: "CPU 0 cannot be set offline",
+		},
+		{
+			name:    "invalid state",
+			rule:    CpuHotplugRule{CPUs: "0", State: "on"},
+			wantErr: "invalid state",
+		},
+		{
+			name:    "invalid cpulist",
+			rule:    CpuHotplugRule{CPUs: "zz", State: CpuOnline},
+			wantErr: "invalid CPU: zz",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rule.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOfflineCPUs(t *testing.T) {
+	// Rules bypass the per-rule validation here, so CPU 0 can be used
+	// as the offline CPU on any machine
+	offlineCPU0 := CpuHotplug{"foo": {CPUs: "0", State: CpuOffline}}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			name: "no offline CPUs",
+			cfg: Config{
+				KernelCmdline: KernelCmdline{
+					Parameters: []string{"irqaffinity=0"},
+				},
+				CpuHotplug: CpuHotplug{
+					"foo": {CPUs: "0", State: CpuOnline},
+				},
+			},
+		},
+		{
+			name: "offline CPU used by irqaffinity",
+			cfg: Config{
+				KernelCmdline: KernelCmdline{
+					Parameters: []string{"irqaffinity=0"},
+				},
+				CpuHotplug: offlineCPU0,
+			},
+			wantErr: "CPU 0 cannot be set offline: used by irqaffinity",
+		},
+		{
+			name: "offline CPU used by kthread_cpus",
+			cfg: Config{
+				KernelCmdline: KernelCmdline{
+					Parameters: []string{"kthread_cpus=0"},
+				},
+				CpuHotplug: offlineCPU0,
+			},
+			wantErr: "used by kthread_cpus",
+		},
+		{
+			name: "offline CPU used by IRQ tuning rule",
+			cfg: Config{
+				Interrupts: Interrupts{
+					"bar": {CPUs: "0"},
+				},
+				CpuHotplug: offlineCPU0,
+			},
+			wantErr: "used by irq-tuning rule #bar",
+		},
+		{
+			name: "offline CPU used by CPU governance rule",
+			cfg: Config{
+				CpuGovernance: PwrMgmt{"bar": {CPUs: "0"}},
+				CpuHotplug:    offlineCPU0,
+			},
+			wantErr: "used by cpu-governance rule #bar",
+		},
+		{
+			name: "offline CPU used by CPU idle rule",
+			cfg: Config{
+				CpuIdle:    CpuIdle{"bar": {CPUs: "0"}},
+				CpuHotplug: offlineCPU0,
+			},
+			wantErr: "used by cpu-idle rule #bar",
+		},
+		{
+			name: "offline CPU used by PM QoS rule",
+			cfg: Config{
+				PmQos: PmQos{
+					ResumeLatency: PmQosResumeLatencies{"bar": {CPUs: "0"}},
+				},
+				CpuHotplug: offlineCPU0,
+			},
+			wantErr: "used by pm-qos resume-latency rule #bar",
+		},
+		{
+			name: "offline CPU used by network steering rule",
+			cfg: Config{
+				NetSteering: NetSteering{"bar": {XPSCPUs: "0"}},
+				CpuHotplug:  offlineCPU0,
+			},
+			wantErr: "used by network-steering rule #bar xps-cpus",
+		},
+		{
+			name: "offline CPU used by systemd affinity",
+			cfg: Config{
+				Systemd:    SystemdAffinity{CPUs: "0"},
+				CpuHotplug: offlineCPU0,
+			},
+			wantErr: "used by systemd-affinity",
+		},
+		{
+			name: "offline CPU skipped by rules for all CPUs",
+			cfg: Config{
+				CpuGovernance: PwrMgmt{"bar": {CPUs: "all"}},
+				CpuIdle:       CpuIdle{"bar": {CPUs: "0-N"}},
+				CpuHotplug:    offlineCPU0,
+			},
+		},
+		{
+			name: "CPU set both online and offline",
+			cfg: Config{
+				CpuHotplug: CpuHotplug{
+					"foo": {CPUs: "0", State: CpuOffline},
+					"bar": {CPUs: "0", State: CpuOnline},
+				},
+			},
+			wantErr: "CPU 0 is set both offline by rule #foo and online by rule #bar",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.validateOfflineCPUs()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestWarnOfflineCPUs(t *testing.T) {
+	setupSysfs(t, map[string]string{})
+	online := filepath.Join(cpulists.SysCPUPath(), "online")
+	if err := os.WriteFile(online, []byte("0-1\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	cfg := Config{
+		Interrupts:  Interrupts{"bar": {CPUs: "1-3"}},
+		CpuIdle:     CpuIdle{"baz": {CPUs: "0"}},
+		CpuHotplug:  CpuHotplug{"foo": {CPUs: "2", State: CpuOnline}},
+		NetSteering: NetSteering{"qux": {RPSCPUs: "all"}},
+	}
+	if err := cfg.warnOfflineCPUs(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	out := buf.String()
+	want := "Warning: CPU 3 is offline, irq-tuning rule #bar skips it"
+	if !strings.Contains(out, want) {
+		t.Errorf("expected %q in output, got: %q", want, out)
+	}
+	// CPU 2 is set online by the hotplug rule
+	if strings.Contains(out, "CPU 2") {
+		t.Errorf("unexpected warning for CPU 2: %q", out)
+	}
+	if strings.Count(out, "Warning:") != 1 {
+		t.Errorf("expected a single warning, got: %q", out)
+	}
+}
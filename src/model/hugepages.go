@@ -0,0 +1,216 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+// Maximum number of NUMA nodes supported by the kernel, see NODES_SHIFT
+const MaxNUMANodes = 1024
+
+// HugepagesRule reserves hugepages of a given size
+// See: https://docs.kernel.org/admin-guide/mm/hugetlbpage.html
+type HugepagesRule struct {
+	// Size of the hugepages, e.g. 2M or 1G
+	Size string `yaml:"size"`
+	// Number of hugepages to be reserved, on each node when nodes is set
+	Count int `yaml:"count"`
+	// NUMA nodes on which the hugepages are reserved, in the CPU Lists format
+	// When unset, the kernel spreads the hugepages across the nodes
+	Nodes string `yaml:"nodes"`
+	// Also reserve the hugepages at boot, via the kernel command line
+	KernelCmdline bool `yaml:"kernel-cmdline"`
+}
+
+// ParseHugepageSize parses a hugepage size, e.g. 2M, 1G or 2048kB, into kB
+func ParseHugepageSize(size string) (int, error) {
+	s := strings.TrimSuffix(strings.TrimSpace(size), "B")
+	multipliers := map[string]int{"k": 1, "K": 1, "M": 1024, "G": 1024 * 1024}
+
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid hugepage size: %q", size)
+	}
+	multiplier, ok := multipliers[s[len(s)-1:]]
+	if !ok {
+		return 0, fmt.Errorf("invalid hugepage size: %q, expected a K, M or G suffix", size)
+	}
+	value, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid hugepage size: %q", size)
+	}
+	return value * multiplier, nil
+}
+
+// FormatHugepageSize formats a hugepage size in kB, in the format of the
+// hugepagesz kernel parameter, e.g. 2M
+func FormatHugepageSize(kB int) string {
+	switch {
+	case kB%(1024*1024) == 0:
+		return fmt.Sprintf("%dG", kB/(1024*1024))
+	case kB%1024 == 0:
+		return fmt.Sprintf("%dM", kB/1024)
+	default:
+		return fmt.Sprintf("%dK", kB)
+	}
+}
+
+// ParseNodes parses the NUMA nodes of the rule, nil when unset.
+// The all and N items are expanded to the NUMA nodes of the machine.
+func (r HugepagesRule) ParseNodes() (cpulists.CPUs, error) {
+	if r.Nodes == "" {
+		return nil, nil
+	}
+	if cpulists.HasSelectors(r.Nodes) {
+		return nil, fmt.Errorf("topology selectors are not supported: %s", r.Nodes)
+	}
+	if !expandsToCPUs(r.Nodes) {
+		return cpulists.ParseForCPUs(r.Nodes, MaxNUMANodes)
+	}
+
+	existing, err := cpulists.ReadNodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NUMA nodes: %v", err)
+	}
+	total := 0
+	for node := range existing {
+		total = max(total, node+1)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no NUMA nodes found")
+	}
+	parsed, err := cpulists.ParseForCPUs(r.Nodes, total)
+	if err != nil {
+		return nil, err
+	}
+	// Node IDs may be sparse
+	nodes := make(cpulists.CPUs)
+	for node := range parsed {
+		if existing[node] {
+			nodes[node] = true
+		}
+	}
+	return nodes, nil
+}
+
+func (r HugepagesRule) Validate() error {
+	if _, err := ParseHugepageSize(r.Size); err != nil {
+		return err
+	}
+	if r.Count < 0 {
+		return fmt.Errorf("count cannot be negative: %d", r.Count)
+	}
+	if _, err := r.ParseNodes(); err != nil {
+		return fmt.Errorf("invalid nodes: %v", err)
+	}
+	return nil
+}
+
+// Labels returns the labels of the rules, sorted
+func (h Hugepages) Labels() []string {
+	return sortedLabels(h)
+}
+
+func (h Hugepages) Validate() error {
+	// Rule reserving each size system wide (node -1) or on each node
+	owners := make(map[int]map[int]string)
+	for _, label := range h.Labels() {
+		if !validRuleName.MatchString(label) {
+			return fmt.Errorf("invalid rule name: %q", label)
+		}
+		rule := h[label]
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("failed to validate hugepages rule #%s: %v", label, err)
+		}
+
+		size, _ := ParseHugepageSize(rule.Size)
+		nodes, _ := rule.ParseNodes()
+		if owners[size] == nil {
+			owners[size] = make(map[int]string)
+		}
+		if nodes == nil {
+			nodes = cpulists.CPUs{-1: true}
+		}
+		for node := range nodes {
+			// A system wide reservation conflicts with any per node one
+			for other, owner := range owners[size] {
+				if other == node || other == -1 || node == -1 {
+					return fmt.Errorf("hugepages rules #%s and #%s both reserve %s hugepages%s",
+						owner, label, FormatHugepageSize(size), nodeSuffix(node, other))
+				}
+			}
+			owners[size][node] = label
+		}
+	}
+	return nil
+}
+
+func nodeSuffix(node, other int) string {
+	if node == other && node != -1 {
+		return fmt.Sprintf(" on node %d", node)
+	}
+	return ""
+}
+
+// KernelParameters returns the hugepagesz and hugepages kernel parameters
+// of the rules with kernel-cmdline set, e.g. hugepagesz=1G hugepages=0:4,1:4
+func (h Hugepages) KernelParameters() []string {
+	// Per size, the system wide count or the counts per node
+	global := make(map[int]int)
+	perNode := make(map[int]map[int]int)
+	for _, rule := range h {
+		if !rule.KernelCmdline {
+			continue
+		}
+		size, err := ParseHugepageSize(rule.Size)
+		if err != nil {
+			continue
+		}
+		nodes, err := rule.ParseNodes()
+		if err != nil {
+			continue
+		}
+		if nodes == nil {
+			global[size] = rule.Count
+			continue
+		}
+		if perNode[size] == nil {
+			perNode[size] = make(map[int]int)
+		}
+		for node := range nodes {
+			perNode[size][node] = rule.Count
+		}
+	}
+
+	var sizes []int
+	for size := range global {
+		sizes = append(sizes, size)
+	}
+	for size := range perNode {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+
+	var params []string
+	for _, size := range sizes {
+		count := strconv.Itoa(global[size])
+		if counts, ok := perNode[size]; ok {
+			var nodes []int
+			for node := range counts {
+				nodes = append(nodes, node)
+			}
+			sort.Ints(nodes)
+			var entries []string
+			for _, node := range nodes {
+				entries = append(entries, fmt.Sprintf("%d:%d", node, counts[node]))
+			}
+			count = strings.Join(entries, ",")
+		}
+		params = append(params, "hugepagesz="+FormatHugepageSize(size),
+			"hugepages="+count)
+	}
+	return params
+}
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+func TestParseHugepageSize(t *testing.T) {
+	valid := map[string]int{
+		"2M":     2048,
+		"2MB":    2048,
+		"1G":     1048576,
+		"2048kB": 2048,
+		"64K":    64,
+	}
+	for size, want := range valid {
+		got, err := ParseHugepageSize(size)
+		if err != nil || got != want {
+			t.Errorf("%q: expected (%d, nil), got (%d, %v)", size, want, got, err)
+		}
+		if size == "2M" || size == "1G" || size == "64K" {
+			if formatted := FormatHugepageSize(got); formatted != size {
+				t.Errorf("expected %q, got %q", size, formatted)
+			}
+		}
+	}
+
+	for _, size := range []string{"", "M", "2", "2T", "-2M", "0G"} {
+		if _, err := ParseHugepageSize(size); err == nil {
+			t.Errorf("%q: expected error", size)
+		}
+	}
+}
+
+func TestHugepagesValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		hugepages Hugepages
+		wantErr   string
+	}{
+		{
+			name: "valid",
+			hugepages: Hugepages{
+				"small":  {Size: "2M", Count: 512},
+				"large":  {Size: "1G", Count: 4, Nodes: "0"},
+				"large1": {Size: "1G", Count: 2, Nodes: "1"},
+			},
+		},
+		{
+			name:      "invalid size",
+			hugepages: Hugepages{"foo": {Size: "2X", Count: 1}},
+			wantErr:   "failed to validate hugepages rule #foo: invalid hugepage size",
+		},
+		{
+			name:      "negative count",
+			hugepages: Hugepages{"foo": {Size: "2M", Count: -1}},
+			wantErr:   "count cannot be negative: -1",
+		},
+		{
+			name:      "invalid nodes",
+			hugepages: Hugepages{"foo": {Size: "2M", Count: 1, Nodes: "a"}},
+			wantErr:   "invalid nodes",
+		},
+		{
+			name: "overlapping nodes",
+			hugepages: Hugepages{
+				"bar": {Size: "1G", Count: 4, Nodes: "0-1"},
+				"foo": {Size: "1G", Count: 2, Nodes: "1"},
+			},
+			wantErr: "hugepages rules #bar and #foo both reserve 1G hugepages on node 1",
+		},
+		{
+			name: "system wide and per node",
+			hugepages: Hugepages{
+				"bar": {Size: "2M", Count: 4},
+				"foo": {Size: "2M", Count: 2, Nodes: "0"},
+			},
+			wantErr: "hugepages rules #bar and #foo both reserve 2M hugepages",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.hugepages.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseNodes(t *testing.T) {
+	root := t.TempDir()
+	t.Cleanup(cpulists.SetSysfsRoot(root))
+	for _, node := range []string{"node0", "node2"} {
+		dir := filepath.Join(root, "devices", "system", "node", node)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		nodes    string
+		expected []int
+		err      string
+	}{
+		{nodes: "all", expected: []int{0, 2}},
+		{nodes: "0-N", expected: []int{0, 2}},
+		{nodes: "N", expected: []int{2}},
+		{nodes: "1", expected: []int{1}},
+		{nodes: "@node:0", err: "topology selectors are not supported"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.nodes, func(t *testing.T) {
+			nodes, err := HugepagesRule{Size: "2M", Nodes: tc.nodes}.ParseNodes()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := make([]int, 0, len(nodes))
+			for node := range nodes {
+				got = append(got, node)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("expected nodes %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestHugepagesKernelParameters(t *testing.T) {
+	hugepages := Hugepages{
+		"small":  {Size: "2M", Count: 512, KernelCmdline: true},
+		"large":  {Size: "1G", Count: 4, Nodes: "0", KernelCmdline: true},
+		"large1": {Size: "1G", Count: 2, Nodes: "1", KernelCmdline: true},
+		"late":   {Size: "64K", Count: 8},
+	}
+	expected := []string{
+		"hugepagesz=2M", "hugepages=512",
+		"hugepagesz=1G", "hugepages=0:4,1:2",
+	}
+	if got := hugepages.KernelParameters(); !slices.Equal(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	config := Config{
+		KernelCmdline: KernelCmdline{Parameters: []string{"nohz=on"}},
+		Hugepages:     hugepages,
+	}
+	boot := config.BootKernelCmdline()
+	if len(boot.Parameters) != 5 || boot.Parameters[0] != "nohz=on" {
+		t.Fatalf("unexpected boot parameters: %v", boot.Parameters)
+	}
+	if len(config.KernelCmdline.Parameters) != 1 {
+		t.Fatalf("expected the config parameters to be left untouched")
+	}
+	if err := boot.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := boot.HasDuplicates(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"github.com/canonical/rt-conf/src/cpulists"
 )
 
-// TODO: THis needs to be superseed in the unit tests
-const (
+// Variables, so configs can be validated against another machine. Only
+// SysKernelIRQ is read by the validators: ProcIRQ holds the IRQ affinities,
+// which are only read and written on the local machine.
+var (
 	SysKernelIRQ = "/sys/kernel/irq"
 	ProcIRQ      = "/proc/irq"
 )
@@ -19,20 +21,54 @@ var readDir = func(name string) ([]os.DirEntry, error) {
 	return os.ReadDir(name)
 }
 
+// IRQ distribution modes
+const (
+	// Spread the IRQs evenly over the CPUs, consecutive queues sharing a
+	// CPU when there are more IRQs than CPUs
+	DistributionSpread = "spread"
+	// Assign the IRQs to the CPUs in turn, wrapping around the CPU list
+	DistributionRoundRobin = "round-robin"
+)
+
 type IRQTuning struct {
 	CPUs   string    `yaml:"cpus"`
 	Filter IRQFilter `yaml:"filter"`
+	// Alternative filters, an IRQ is matched if it matches any of them
+	Filters []IRQFilter `yaml:"filters"`
+	// Assign each IRQ to a single CPU, in the order of their queue index.
+	// All IRQs are assigned to all CPUs if not set.
+	Distribution string `yaml:"distribution"`
+	// Rules with a higher priority win over the ones matching the same IRQs
+	Priority int `yaml:"priority"`
+}
+
+// AllFilters returns the filters of the rule, which are ORed together
+func (c IRQTuning) AllFilters() []IRQFilter {
+	if len(c.Filters) == 0 {
+		return []IRQFilter{c.Filter}
+	}
+	if c.Filter.IsEmpty() {
+		return c.Filters
+	}
+	return append([]IRQFilter{c.Filter}, c.Filters...)
 }
 
 func (c IRQTuning) Validate() error {
-	err := c.Filter.Validate()
-	if err != nil {
-		return fmt.Errorf("IRQFilter validation failed: %v", err)
+	for _, filter := range c.AllFilters() {
+		if err := filter.Validate(); err != nil {
+			return fmt.Errorf("IRQFilter validation failed: %v", err)
+		}
 	}
-	_, err = cpulists.Parse(c.CPUs)
+	_, err := cpulists.Parse(c.CPUs)
 	if err != nil {
 		return fmt.Errorf("invalid cpus: %v", err)
 	}
+	switch c.Distribution {
+	case "", DistributionSpread, DistributionRoundRobin:
+	default:
+		return fmt.Errorf("invalid distribution: %q, must be one of: %s, %s",
+			c.Distribution, DistributionSpread, DistributionRoundRobin)
+	}
 	return nil
 }
 
@@ -41,15 +77,76 @@ type IRQFilter struct {
 	ChipName string `yaml:"chip-name" validation:"regex"`
 	Name     string `yaml:"name" validation:"regex"`
 	Type     string `yaml:"type" validation:"regex"`
+	// IRQ numbers, in the CPU Lists format
+	Numbers string `yaml:"numbers" validation:"cpulist"`
+	// PCI device of the IRQs, e.g. "0000:03:00.0"
+	PCIAddress string `yaml:"pci-address" validation:"regex"`
+	// Driver bound to the PCI device of the IRQs
+	Driver string `yaml:"driver" validation:"regex"`
+	// Network interface of the PCI device of the IRQs
+	Netdev string `yaml:"netdev" validation:"regex"`
+	// IRQs matching any of these filters are excluded
+	Exclude []IRQFilter `yaml:"exclude"`
+}
+
+// IsEmpty returns true if no field of the filter is set
+func (c IRQFilter) IsEmpty() bool {
+	return c.Actions == "" && c.ChipName == "" && c.Name == "" &&
+		c.Type == "" && c.Numbers == "" && c.PCIAddress == "" &&
+		c.Driver == "" && c.Netdev == "" && len(c.Exclude) == 0
 }
 
+// IRQs holds the options which apply to all IRQs
 type IRQs struct {
+	// CPUs from which all non-managed IRQs are to be moved
 	IsolateCPU string `yaml:"remove-from-cpus"`
+	// CPUs set as the default affinity of newly registered IRQs
 	IRQHandler string `yaml:"handle-on-cpus"`
 }
 
+// IsEmpty returns true if no option is set
+func (c IRQs) IsEmpty() bool {
+	return c.IsolateCPU == "" && c.IRQHandler == ""
+}
+
+func (c IRQs) Validate() error {
+	var isolated, handlers cpulists.CPUs
+	var err error
+	if c.IsolateCPU != "" {
+		isolated, err = cpulists.Parse(c.IsolateCPU)
+		if err != nil {
+			return fmt.Errorf("invalid remove-from-cpus: %v", err)
+		}
+	}
+	if c.IRQHandler != "" {
+		handlers, err = cpulists.Parse(c.IRQHandler)
+		if err != nil {
+			return fmt.Errorf("invalid handle-on-cpus: %v", err)
+		}
+	}
+
+	for cpu := range handlers {
+		if isolated[cpu] {
+			return fmt.Errorf(
+				"CPU %d is set in both remove-from-cpus and handle-on-cpus", cpu)
+		}
+	}
+	return nil
+}
+
 func (c IRQFilter) Validate() error {
-	return Validate(c, c.validateIRQField)
+	if err := Validate(c, c.validateIRQField); err != nil {
+		return err
+	}
+	for _, exclude := range c.Exclude {
+		if exclude.IsEmpty() {
+			return fmt.Errorf("empty exclude filter")
+		}
+		if err := exclude.Validate(); err != nil {
+			return fmt.Errorf("on exclude filter: %v", err)
+		}
+	}
+	return nil
 }
 
 // TODO: Validate mutual exclusive cpu lists
@@ -61,7 +158,8 @@ func (c IRQFilter) validateIRQField(name string, value string, tag string) error
 		if err != nil {
 			return err
 		}
-		_, err = cpulists.ParseForCPUs(value, num)
+		// The highest IRQ is a valid entry of the list
+		_, err = cpulists.ParseForCPUs(value, num+1)
 		if err != nil {
 			return fmt.Errorf("on field %v: invalid irq list: %v", name,
 				err)
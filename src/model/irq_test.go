@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -165,3 +166,191 @@ func TestGetHigherIRQ(t *testing.T) {
 		})
 	}
 }
+
+func TestIRQAffinityValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     IRQs
+		wantErr string
+	}{
+		{
+			name: "empty",
+			cfg:  IRQs{},
+		},
+		{
+			name: "handle on",
+			cfg:  IRQs{IRQHandler: "0"},
+		},
+		{
+			name:    "invalid remove from",
+			cfg:     IRQs{IsolateCPU: "zz"},
+			wantErr: "invalid remove-from-cpus",
+		},
+		{
+			name:    "invalid handle on",
+			cfg:     IRQs{IRQHandler: "zz"},
+			wantErr: "invalid handle-on-cpus",
+		},
+		{
+			name:    "overlapping CPUs",
+			cfg:     IRQs{IsolateCPU: "0", IRQHandler: "0"},
+			wantErr: "CPU 0 is set in both remove-from-cpus and handle-on-cpus",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestIRQFilterNumbersValidate(t *testing.T) {
+	prev := readDir
+	readDir = func(_ string) ([]os.DirEntry, error) {
+		return []os.DirEntry{
+			&mockDirEntry{name: "0", isDir: true},
+			&mockDirEntry{name: "40", isDir: true},
+		}, nil
+	}
+	t.Cleanup(func() { readDir = prev })
+
+	tests := []struct {
+		name    string
+		numbers string
+		wantErr string
+	}{
+		{
+			name:    "valid list",
+			numbers: "24-31,40",
+		},
+		{
+			name:    "highest IRQ",
+			numbers: "N",
+		},
+		{
+			name:    "above highest IRQ",
+			numbers: "41",
+			wantErr: "invalid irq list",
+		},
+		{
+			name:    "invalid list",
+			numbers: "foo",
+			wantErr: "invalid irq list",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := IRQFilter{Numbers: tc.numbers}.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestIRQFilterExcludeValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       IRQTuning
+		wantErr string
+	}{
+		{
+			name: "valid exclude",
+			c: IRQTuning{
+				CPUs: "0",
+				Filter: IRQFilter{
+					ChipName: "MSI",
+					Exclude:  []IRQFilter{{Actions: "nvme"}},
+				},
+			},
+		},
+		{
+			name: "valid alternative filters",
+			c: IRQTuning{
+				CPUs:    "0",
+				Filters: []IRQFilter{{Actions: "nvme"}, {Actions: "eth"}},
+			},
+		},
+		{
+			name: "empty exclude filter",
+			c: IRQTuning{
+				CPUs:   "0",
+				Filter: IRQFilter{Exclude: []IRQFilter{{}}},
+			},
+			wantErr: "empty exclude filter",
+		},
+		{
+			name: "invalid exclude regex",
+			c: IRQTuning{
+				CPUs:   "0",
+				Filter: IRQFilter{Exclude: []IRQFilter{{Actions: `(?!abc)`}}},
+			},
+			wantErr: "on exclude filter",
+		},
+		{
+			name: "invalid alternative filter regex",
+			c: IRQTuning{
+				CPUs:    "0",
+				Filters: []IRQFilter{{Actions: "nvme"}, {Name: `(?!abc)`}},
+			},
+			wantErr: "invalid regex",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.c.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestIRQTuningDistributionValidate(t *testing.T) {
+	for _, distribution := range []string{"", DistributionSpread, DistributionRoundRobin} {
+		c := IRQTuning{CPUs: "0", Distribution: distribution}
+		if err := c.Validate(); err != nil {
+			t.Errorf("distribution %q: expected no error, got: %v",
+				distribution, err)
+		}
+	}
+
+	c := IRQTuning{CPUs: "0", Distribution: "random"}
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "invalid distribution") {
+		t.Fatalf("expected invalid distribution error, got: %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/canonical/rt-conf/src/cpulists"
@@ -11,6 +13,10 @@ import (
 
 var isolcpuFlags = []string{"domain", "nohz", "managed_irq"}
 
+// Parameters which the kernel accepts several times with different values,
+// e.g. hugepagesz=2M hugepages=512 hugepagesz=1G hugepages=4
+var repeatableParams = []string{"hugepagesz", "hugepages"}
+
 // KernelCmdline represents the kernel command line options.
 type KernelCmdline struct {
 	Parameters []string `yaml:"parameters"`
@@ -94,6 +100,14 @@ func (k KernelCmdline) validateParameterValues() error {
 			if value != "on" && value != "off" {
 				return fmt.Errorf("%q must be set to either 'on' or 'off', got %q", key, value)
 			}
+		case "hugepagesz", "default_hugepagesz":
+			if _, err := ParseHugepageSize(value); err != nil {
+				return fmt.Errorf("%q has an invalid value: %q: %v", key, value, err)
+			}
+		case "hugepages":
+			if err := validateHugepagesCount(value); err != nil {
+				return fmt.Errorf("%q has an invalid value: %q: %v", key, value, err)
+			}
 		default:
 			log.Printf("Warning: Parameter %q not recognized by rt-conf; skipping specific validation", key)
 		}
@@ -101,6 +115,36 @@ func (k KernelCmdline) validateParameterValues() error {
 	return nil
 }
 
+// BootKernelCmdline returns the kernel command line parameters, along with the
+// ones generated by the hugepages rules with kernel-cmdline set
+func (c Config) BootKernelCmdline() KernelCmdline {
+	params := c.Hugepages.KernelParameters()
+	if len(params) == 0 {
+		return c.KernelCmdline
+	}
+	return KernelCmdline{
+		Parameters: append(slices.Clone(c.KernelCmdline.Parameters), params...),
+	}
+}
+
+// ResolveSelectors replaces the topology selectors in the parameter values
+// with numeric CPU lists, as the kernel doesn't understand them
+func (k KernelCmdline) ResolveSelectors() (KernelCmdline, error) {
+	resolved := KernelCmdline{Parameters: make([]string, 0, len(k.Parameters))}
+	for _, p := range k.Parameters {
+		key, value, found := strings.Cut(p, "=")
+		if found && cpulists.HasSelectors(value) {
+			list, err := cpulists.Resolve(value)
+			if err != nil {
+				return KernelCmdline{}, fmt.Errorf("%q: %v", key, err)
+			}
+			p = key + "=" + list
+		}
+		resolved.Parameters = append(resolved.Parameters, p)
+	}
+	return resolved, nil
+}
+
 // HasDuplicates checks for duplicate parameters with different values
 func (k KernelCmdline) HasDuplicates() error {
 	params := make(map[string]string)
@@ -119,6 +163,10 @@ func (k KernelCmdline) HasDuplicates() error {
 			value = ""
 		}
 
+		if slices.Contains(repeatableParams, key) {
+			continue
+		}
+
 		if existingValue, exists := params[key]; exists {
 			// Allow duplicate parameters with the same value
 			if existingValue != value {
@@ -130,3 +178,23 @@ func (k KernelCmdline) HasDuplicates() error {
 	}
 	return nil
 }
+
+// validateHugepagesCount validates the value of the hugepages parameter,
+// either a count or counts per node, e.g. 0:4,1:4
+func validateHugepagesCount(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		node, count, perNode := strings.Cut(entry, ":")
+		if !perNode {
+			count = node
+		} else if _, err := strconv.ParseUint(node, 10, 32); err != nil {
+			return fmt.Errorf("invalid node: %q", node)
+		}
+		if _, err := strconv.ParseUint(count, 10, 32); err != nil {
+			return fmt.Errorf("invalid count: %q", count)
+		}
+		if !perNode && strings.Contains(value, ",") {
+			return fmt.Errorf("a single count or counts per node expected")
+		}
+	}
+	return nil
+}
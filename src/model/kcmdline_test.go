@@ -272,6 +272,18 @@ func TestKcmdDuplicates(t *testing.T) {
 			},
 			ExpectErr: false,
 		},
+		{
+			Name: "Repeated hugepages parameters",
+			Cfg: model.KernelCmdline{
+				Parameters: []string{
+					"hugepagesz=2M",
+					"hugepages=512",
+					"hugepagesz=1G",
+					"hugepages=4",
+				},
+			},
+			ExpectErr: false,
+		},
 		{
 			Name: "Empty value in list",
 			Cfg: model.KernelCmdline{
@@ -353,6 +365,35 @@ func TestKcmdValidation(t *testing.T) {
 			},
 			ExpectErr: true,
 		},
+		{
+			Name: "valid hugepages",
+			Cfg: model.KernelCmdline{
+				Parameters: []string{
+					"default_hugepagesz=1G",
+					"hugepagesz=1G",
+					"hugepages=0:4,1:4",
+				},
+			},
+			ExpectErr: false,
+		},
+		{
+			Name: "invalid hugepagesz",
+			Cfg: model.KernelCmdline{
+				Parameters: []string{
+					"hugepagesz=1T",
+				},
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "invalid hugepages",
+			Cfg: model.KernelCmdline{
+				Parameters: []string{
+					"hugepages=4,0:4",
+				},
+			},
+			ExpectErr: true,
+		},
 		{
 			Name: "valid tag paramter",
 			Cfg: model.KernelCmdline{
@@ -377,3 +418,25 @@ func TestKcmdValidation(t *testing.T) {
 		assertError(t, err, tc.ExpectErr)
 	}
 }
+
+func TestKcmdResolveSelectors(t *testing.T) {
+	cmdline := model.KernelCmdline{
+		Parameters: []string{"nohz", "nohz_full=0", "irqaffinity=@first-cores:1"},
+	}
+	resolved, err := cmdline.ResolveSelectors()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resolved.Parameters) != 3 ||
+		resolved.Parameters[0] != "nohz" ||
+		resolved.Parameters[1] != "nohz_full=0" ||
+		strings.Contains(resolved.Parameters[2], "@") {
+		t.Fatalf("unexpected parameters: %v", resolved.Parameters)
+	}
+
+	cmdline = model.KernelCmdline{Parameters: []string{"isolcpus=@bogus"}}
+	if _, err := cmdline.ResolveSelectors(); err == nil ||
+		!strings.Contains(err.Error(), "unknown topology selector") {
+		t.Fatalf("expected error, got: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+)
+
+// MemoryTuning sets the memory management features which cause latency
+// spikes, e.g. by compacting or scanning the memory in the background
+// See: https://docs.kernel.org/admin-guide/mm/transhuge.html
+// See: https://docs.kernel.org/admin-guide/mm/ksm.html
+type MemoryTuning struct {
+	// Transparent hugepages mode, e.g. never
+	THPEnabled string `yaml:"thp-enabled"`
+	// Transparent hugepages defragmentation on page faults, e.g. never
+	THPDefrag string `yaml:"thp-defrag"`
+	// Defragmentation by khugepaged: 0 to disable, 1 to enable
+	KhugepagedDefrag *int `yaml:"khugepaged-defrag"`
+	// KSM scanning: 0 to stop, 1 to run, 2 to stop and unmerge all pages
+	KSMRun *int `yaml:"ksm-run"`
+	// Proactive compaction, from 0 (disabled) to 100
+	CompactionProactiveness *int `yaml:"compaction-proactiveness"`
+}
+
+// Modes known to the kernel. Older kernels may offer fewer of them, which
+// is checked against the options listed in sysfs when applying.
+var (
+	thpEnabledModes = []string{"always", "madvise", "never"}
+	thpDefragModes  = []string{"always", "defer", "defer+madvise", "madvise", "never"}
+)
+
+// IsEmpty returns true if no memory tuning option is configured
+func (m MemoryTuning) IsEmpty() bool {
+	return m.THPEnabled == "" && m.THPDefrag == "" &&
+		m.KhugepagedDefrag == nil && m.KSMRun == nil &&
+		m.CompactionProactiveness == nil
+}
+
+func (m MemoryTuning) Validate() error {
+	if m.THPEnabled != "" && !slices.Contains(thpEnabledModes, m.THPEnabled) {
+		return fmt.Errorf("invalid thp-enabled: %q, expected one of %v",
+			m.THPEnabled, thpEnabledModes)
+	}
+	if m.THPDefrag != "" && !slices.Contains(thpDefragModes, m.THPDefrag) {
+		return fmt.Errorf("invalid thp-defrag: %q, expected one of %v",
+			m.THPDefrag, thpDefragModes)
+	}
+	if err := validateRange("khugepaged-defrag", m.KhugepagedDefrag, 0, 1); err != nil {
+		return err
+	}
+	if err := validateRange("ksm-run", m.KSMRun, 0, 2); err != nil {
+		return err
+	}
+	return validateRange("compaction-proactiveness", m.CompactionProactiveness, 0, 100)
+}
+
+// validateRange checks that an optional value is within [min, max]
+func validateRange(name string, value *int, min, max int) error {
+	if value != nil && (*value < min || *value > max) {
+		return fmt.Errorf("invalid %s: %d is out of range [%d, %d]",
+			name, *value, min, max)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemoryTuningValidation(t *testing.T) {
+	zero := 0
+	two := 2
+	tooHigh := 101
+
+	tests := []struct {
+		name    string
+		mem     MemoryTuning
+		wantErr string
+	}{
+		{
+			name: "valid",
+			mem: MemoryTuning{
+				THPEnabled:              "never",
+				THPDefrag:               "defer+madvise",
+				KhugepagedDefrag:        &zero,
+				KSMRun:                  &two,
+				CompactionProactiveness: &zero,
+			},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:    "invalid thp mode",
+			mem:     MemoryTuning{THPEnabled: "defer"},
+			wantErr: `invalid thp-enabled: "defer"`,
+		},
+		{
+			name:    "invalid thp defrag",
+			mem:     MemoryTuning{THPDefrag: "[never]"},
+			wantErr: `invalid thp-defrag: "[never]"`,
+		},
+		{
+			name:    "invalid khugepaged defrag",
+			mem:     MemoryTuning{KhugepagedDefrag: &two},
+			wantErr: "invalid khugepaged-defrag: 2 is out of range [0, 1]",
+		},
+		{
+			name:    "invalid compaction proactiveness",
+			mem:     MemoryTuning{CompactionProactiveness: &tooHigh},
+			wantErr: "invalid compaction-proactiveness: 101 is out of range [0, 100]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.mem.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
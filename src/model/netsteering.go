@@ -0,0 +1,54 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+// NetSteeringRule sets the CPUs on which the network stack processes the
+// packets of the queues of the matching interfaces
+type NetSteeringRule struct {
+	// Regex matching the whole name of the network interfaces, e.g. eth0
+	Interfaces string `yaml:"interfaces"`
+	// CPUs processing the received packets (Receive Packet Steering)
+	RPSCPUs string `yaml:"rps-cpus"`
+	// CPUs allowed to transmit on each queue (Transmit Packet Steering)
+	XPSCPUs string `yaml:"xps-cpus"`
+	// Number of flow entries per receive queue (Receive Flow Steering)
+	RPSFlowCnt *int `yaml:"rps-flow-cnt"`
+}
+
+// Labels returns the labels of the network steering rules in the order they
+// are applied
+func (c NetSteering) Labels() []string {
+	return sortedLabels(c)
+}
+
+func (c NetSteeringRule) Validate() error {
+	if c.Interfaces == "" {
+		return fmt.Errorf("interfaces must be set")
+	}
+	if _, err := regexp.Compile(c.Interfaces); err != nil {
+		return fmt.Errorf("invalid interfaces regex: %v", err)
+	}
+
+	if c.RPSCPUs == "" && c.XPSCPUs == "" && c.RPSFlowCnt == nil {
+		return fmt.Errorf("either rps-cpus, xps-cpus or rps-flow-cnt must be set")
+	}
+	if c.RPSCPUs != "" {
+		if _, err := cpulists.Parse(c.RPSCPUs); err != nil {
+			return fmt.Errorf("invalid rps-cpus: %v", err)
+		}
+	}
+	if c.XPSCPUs != "" {
+		if _, err := cpulists.Parse(c.XPSCPUs); err != nil {
+			return fmt.Errorf("invalid xps-cpus: %v", err)
+		}
+	}
+	if c.RPSFlowCnt != nil && *c.RPSFlowCnt < 0 {
+		return fmt.Errorf("rps-flow-cnt cannot be negative: %d", *c.RPSFlowCnt)
+	}
+	return nil
+}
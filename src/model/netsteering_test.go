@@ -0,0 +1,74 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNetSteeringValidation(t *testing.T) {
+	flowCnt := 4096
+	negative := -1
+
+	tests := []struct {
+		name    string
+		rule    NetSteeringRule
+		wantErr string
+	}{
+		{
+			name: "rps and rfs",
+			rule: NetSteeringRule{Interfaces: "eth0", RPSCPUs: "0", RPSFlowCnt: &flowCnt},
+		},
+		{
+			name: "xps",
+			rule: NetSteeringRule{Interfaces: "eth[0-9]+", XPSCPUs: "0"},
+		},
+		{
+			name:    "no interfaces",
+			rule:    NetSteeringRule{RPSCPUs: "0"},
+			wantErr: "interfaces must be set",
+		},
+		{
+			name:    "invalid interfaces regex",
+			rule:    NetSteeringRule{Interfaces: "eth(", RPSCPUs: "0"},
+			wantErr: "invalid interfaces regex",
+		},
+		{
+			name:    "no settings",
+			rule:    NetSteeringRule{Interfaces: "eth0"},
+			wantErr: "either rps-cpus, xps-cpus or rps-flow-cnt must be set",
+		},
+		{
+			name:    "invalid rps cpus",
+			rule:    NetSteeringRule{Interfaces: "eth0", RPSCPUs: "zz"},
+			wantErr: "invalid rps-cpus",
+		},
+		{
+			name:    "invalid xps cpus",
+			rule:    NetSteeringRule{Interfaces: "eth0", XPSCPUs: "zz"},
+			wantErr: "invalid xps-cpus",
+		},
+		{
+			name:    "negative flow count",
+			rule:    NetSteeringRule{Interfaces: "eth0", RPSFlowCnt: &negative},
+			wantErr: "rps-flow-cnt cannot be negative",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rule.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
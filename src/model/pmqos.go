@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+// Value of pm_qos_resume_latency_us which forbids any idle state
+// with a nonzero exit latency
+const NoResumeLatency = "n/a"
+
+type PmQos struct {
+	// Global CPU latency request (in microseconds) held via /dev/cpu_dma_latency
+	CpuDmaLatency *int                 `yaml:"cpu-dma-latency"`
+	ResumeLatency PmQosResumeLatencies `yaml:"resume-latency"`
+}
+
+type PmQosResumeLatencies map[string]ResumeLatencyRule
+
+type ResumeLatencyRule struct {
+	CPUs string `yaml:"cpus"`
+	// Resume latency in microseconds or "n/a"
+	// See: https://docs.kernel.org/ABI/testing/sysfs-devices-power
+	Latency string `yaml:"latency"`
+}
+
+// IsEmpty returns true if no PM QoS request is configured
+func (p PmQos) IsEmpty() bool {
+	return p.CpuDmaLatency == nil && len(p.ResumeLatency) == 0
+}
+
+func (p PmQos) Validate() error {
+	if p.CpuDmaLatency != nil && *p.CpuDmaLatency < 0 {
+		return fmt.Errorf("cpu-dma-latency cannot be negative: %d",
+			*p.CpuDmaLatency)
+	}
+
+	for label, rule := range p.ResumeLatency {
+		if !validRuleName.MatchString(label) {
+			return fmt.Errorf("invalid rule name: %q", label)
+		}
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf(
+				"failed to validate resume latency rule #%s: %s", label, err)
+		}
+	}
+	return nil
+}
+
+// Labels returns the labels of the resume latency rules in the order they
+// are applied
+func (p PmQosResumeLatencies) Labels() []string {
+	return sortedLabels(p)
+}
+
+func (r ResumeLatencyRule) Validate() error {
+	if _, err := cpulists.Parse(r.CPUs); err != nil {
+		return err
+	}
+
+	if r.Latency == NoResumeLatency {
+		return nil
+	}
+	latency, err := strconv.Atoi(r.Latency)
+	if err != nil {
+		return fmt.Errorf("invalid latency: %q, expected microseconds or %q",
+			r.Latency, NoResumeLatency)
+	}
+	if latency < 0 {
+		return fmt.Errorf("latency cannot be negative: %d", latency)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPmQosValidation(t *testing.T) {
+	latency := 0
+	negativeLatency := -5
+
+	tests := []struct {
+		name    string
+		pmqos   PmQos
+		wantErr string
+	}{
+		{
+			name: "valid cpu dma latency",
+			pmqos: PmQos{
+				CpuDmaLatency: &latency,
+			},
+		},
+		{
+			name: "valid resume latency",
+			pmqos: PmQos{
+				ResumeLatency: PmQosResumeLatencies{
+					"foo": {CPUs: "0", Latency: "20"},
+					"bar": {CPUs: "0", Latency: "n/a"},
+				},
+			},
+		},
+		{
+			name: "negative cpu dma latency",
+			pmqos: PmQos{
+				CpuDmaLatency: &negativeLatency,
+			},
+			wantErr: "cpu-dma-latency cannot be negative",
+		},
+		{
+			name: "invalid rule name",
+			pmqos: PmQos{
+				ResumeLatency: PmQosResumeLatencies{
+					"foo bar": {CPUs: "0", Latency: "20"},
+				},
+			},
+			wantErr: "invalid rule name",
+		},
+		{
+			name: "invalid resume latency",
+			pmqos: PmQos{
+				ResumeLatency: PmQosResumeLatencies{
+					"foo": {CPUs: "0", Latency: "potato"},
+				},
+			},
+			wantErr: "invalid latency",
+		},
+		{
+			name: "negative resume latency",
+			pmqos: PmQos{
+				ResumeLatency: PmQosResumeLatencies{
+					"foo": {CPUs: "0", Latency: "-1"},
+				},
+			},
+			wantErr: "latency cannot be negative",
+		},
+		{
+			name: "invalid cpulist",
+			pmqos: PmQos{
+				ResumeLatency: PmQosResumeLatencies{
+					"foo": {CPUs: "zz", Latency: "0"},
+				},
+			},
+			wantErr: "invalid CPU: zz",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.pmqos.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
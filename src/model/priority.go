@@ -0,0 +1,34 @@
+package model
+
+import "sort"
+
+// Rules are applied in ascending order of priority, then of label, so when
+// several rules match the same IRQ or CPU the one with the highest priority
+// is applied last and wins.
+
+// Labels returns the labels of the IRQ tuning rules in the order they are
+// applied
+func (c Interrupts) Labels() []string {
+	return sortByPriority(c, func(rule IRQTuning) int { return rule.Priority })
+}
+
+// Labels returns the labels of the CPU governance rules in the order they
+// are applied
+func (c PwrMgmt) Labels() []string {
+	return sortByPriority(c, func(rule CpuGovernanceRule) int { return rule.Priority })
+}
+
+func sortByPriority[T any](rules map[string]T, priority func(T) int) []string {
+	labels := make([]string, 0, len(rules))
+	for label := range rules {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		pi, pj := priority(rules[labels[i]]), priority(rules[labels[j]])
+		if pi != pj {
+			return pi < pj
+		}
+		return labels[i] < labels[j]
+	})
+	return labels
+}
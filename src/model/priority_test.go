@@ -0,0 +1,31 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterruptsLabels(t *testing.T) {
+	rules := Interrupts{
+		"b":    {Priority: 0},
+		"a":    {Priority: 0},
+		"high": {Priority: 10},
+		"low":  {Priority: -1},
+	}
+	expected := []string{"low", "a", "b", "high"}
+	if got := rules.Labels(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestPwrMgmtLabels(t *testing.T) {
+	rules := PwrMgmt{
+		"foo": {Priority: 1},
+		"bar": {Priority: 2},
+		"baz": {Priority: 1},
+	}
+	expected := []string{"baz", "foo", "bar"}
+	if got := rules.Labels(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -27,6 +28,8 @@ type CpuGovernanceRule struct {
 	ScalGov string `yaml:"scaling-governor"`
 	MinFreq string `yaml:"min-freq"`
 	MaxFreq string `yaml:"max-freq"`
+	// Rules with a higher priority win over the ones setting the same CPUs
+	Priority int `yaml:"priority"`
 }
 
 func (c CpuGovernanceRule) Validate() error {
@@ -54,6 +57,45 @@ func (c CpuGovernanceRule) Validate() error {
 	return nil
 }
 
+// UncoreFreqRule sets the frequency limits of Intel uncore domains
+type UncoreFreqRule struct {
+	// Regex matching the uncore domains, e.g. package_00_die_00 or uncore00
+	Domains string `yaml:"domains"`
+	MinFreq string `yaml:"min-freq"`
+	MaxFreq string `yaml:"max-freq"`
+}
+
+// Labels returns the labels of the uncore frequency rules in the order they
+// are applied
+func (c UncoreFreq) Labels() []string {
+	return sortedLabels(c)
+}
+
+func (c UncoreFreqRule) Validate() error {
+	if _, err := regexp.Compile(c.Domains); err != nil {
+		return fmt.Errorf("invalid domains regex: %v", err)
+	}
+
+	if c.MinFreq == "" && c.MaxFreq == "" {
+		return fmt.Errorf("either min-freq or max-freq must be set")
+	}
+
+	minFreq, err := ParseFreq(c.MinFreq)
+	if err != nil {
+		return fmt.Errorf("invalid min frequency: %v", err)
+	}
+	maxFreq, err := ParseFreq(c.MaxFreq)
+	if err != nil {
+		return fmt.Errorf("invalid max frequency: %v", err)
+	}
+
+	if err := validateFreqRange(minFreq, maxFreq); err != nil {
+		return fmt.Errorf("invalid frequency range: %v", err)
+	}
+
+	return nil
+}
+
 func validateFreqRange(min, max int) error {
 	if min == -1 && max == -1 {
 		return nil // No frequency bounds
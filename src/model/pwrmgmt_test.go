@@ -214,3 +214,68 @@ func TestCheckFreqFormat(t *testing.T) { // TODO: drop this test
 		})
 	}
 }
+
+func TestUncoreFreqValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    UncoreFreqRule
+		wantErr string
+	}{
+		{
+			name: "valid rule",
+			rule: UncoreFreqRule{
+				Domains: "package_00_die_.*",
+				MinFreq: "1.2GHz",
+				MaxFreq: "2GHz",
+			},
+		},
+		{
+			name: "no frequency set",
+			rule: UncoreFreqRule{
+				Domains: "package_00",
+			},
+			wantErr: "either min-freq or max-freq must be set",
+		},
+		{
+			name: "invalid regex",
+			rule: UncoreFreqRule{
+				Domains: "**",
+				MaxFreq: "2GHz",
+			},
+			wantErr: "invalid domains regex",
+		},
+		{
+			name: "invalid frequency",
+			rule: UncoreFreqRule{
+				MinFreq: "2G",
+			},
+			wantErr: "invalid min frequency",
+		},
+		{
+			name: "max freq less than min",
+			rule: UncoreFreqRule{
+				MinFreq: "2GHz",
+				MaxFreq: "1GHz",
+			},
+			wantErr: "should not be less than min frequency",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rule.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sysctl maps kernel tunables, e.g. vm.swappiness, to their values
+type Sysctl map[string]string
+
+// sysctlRange is the range of values accepted for a sysctl key
+type sysctlRange struct {
+	min int
+	max int
+}
+
+// Sysctl keys supported by rt-conf, along with their accepted values
+// See: https://docs.kernel.org/admin-guide/sysctl/
+var knownSysctls = map[string]sysctlRange{
+	// -1 disables the RT throttling
+	"kernel.sched_rt_runtime_us": {-1, 1000000},
+	"kernel.timer_migration":     {0, 1},
+	"kernel.watchdog":            {0, 1},
+	"kernel.nmi_watchdog":        {0, 1},
+	// In seconds
+	"vm.stat_interval": {1, 3600},
+	"vm.swappiness":    {0, 200},
+}
+
+// Keys returns the sysctl keys, sorted
+func (c Sysctl) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c Sysctl) Validate() error {
+	for _, key := range c.Keys() {
+		r, ok := knownSysctls[key]
+		if !ok {
+			return fmt.Errorf("unsupported sysctl key: %q", key)
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(c[key]))
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q is not an integer",
+				key, c[key])
+		}
+		if value < r.min || value > r.max {
+			return fmt.Errorf("invalid value for %s: %d is out of range [%d, %d]",
+				key, value, r.min, r.max)
+		}
+	}
+	return nil
+}
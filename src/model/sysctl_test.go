@@ -0,0 +1,57 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSysctlValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		sysctl  Sysctl
+		wantErr string
+	}{
+		{
+			name: "valid",
+			sysctl: Sysctl{
+				"kernel.sched_rt_runtime_us": "-1",
+				"kernel.timer_migration":     "0",
+				"vm.stat_interval":           "10",
+				"vm.swappiness":              "10",
+			},
+		},
+		{
+			name:    "unsupported key",
+			sysctl:  Sysctl{"net.core.somaxconn": "1024"},
+			wantErr: `unsupported sysctl key: "net.core.somaxconn"`,
+		},
+		{
+			name:    "not an integer",
+			sysctl:  Sysctl{"kernel.watchdog": "off"},
+			wantErr: `invalid value for kernel.watchdog: "off" is not an integer`,
+		},
+		{
+			name:    "out of range",
+			sysctl:  Sysctl{"vm.swappiness": "201"},
+			wantErr: "invalid value for vm.swappiness: 201 is out of range [0, 200]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.sysctl.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error: %q, got nil", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error: %q, got: %q",
+						tc.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+)
+
+// SystemdAffinity keeps systemd and the services it starts off the isolated
+// CPUs, by setting the CPU affinity of the service manager and the CPUs
+// allowed to the system.slice, user.slice and init.scope units
+// See: https://www.freedesktop.org/software/systemd/man/latest/systemd-system.conf.html
+// See: https://www.freedesktop.org/software/systemd/man/latest/systemd.resource-control.html
+type SystemdAffinity struct {
+	// Housekeeping CPUs, on which systemd and the services run
+	CPUs string `yaml:"cpus"`
+	// Also apply the allowed CPUs at runtime, via the systemd D-Bus API
+	Runtime bool `yaml:"runtime"`
+}
+
+// IsEmpty returns true if no systemd affinity is configured
+func (s SystemdAffinity) IsEmpty() bool {
+	return s.CPUs == ""
+}
+
+func (s SystemdAffinity) Validate() error {
+	if s.IsEmpty() {
+		if s.Runtime {
+			return fmt.Errorf("cpus must be set")
+		}
+		return nil
+	}
+	cpus, err := cpulists.Parse(s.CPUs)
+	if err != nil {
+		return fmt.Errorf("invalid cpus: %v", err)
+	}
+	if len(cpus) == 0 {
+		return fmt.Errorf("cpus cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdAffinityValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		affinity SystemdAffinity
+		wantErr  string
+	}{
+		{
+			name:     "valid",
+			affinity: SystemdAffinity{CPUs: "0", Runtime: true},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:     "runtime without cpus",
+			affinity: SystemdAffinity{Runtime: true},
+			wantErr:  "cpus must be set",
+		},
+		{
+			name:     "invalid cpus",
+			affinity: SystemdAffinity{CPUs: "foo"},
+			wantErr:  "invalid cpus",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.affinity.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package netsteering
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Documentation for RPS, RFS and XPS:
+// https://docs.kernel.org/networking/scaling.html
+//
+// NOTE: RFS also requires the global flow table size to be set via the
+// net.core.rps_sock_flow_entries sysctl.
+
+type ReaderWriter struct {
+	NetPath string
+}
+
+var netSteeringReaderWriter = ReaderWriter{
+	NetPath: "/sys/class/net",
+}
+
+// Queue represents a receive or transmit queue of a network interface
+type Queue struct {
+	// Directory name of the queue, e.g. rx-0 or tx-3
+	Dir string
+	// Queue index, e.g. 3 for tx-3
+	Index int
+}
+
+// ReadInterfaces returns the names of the network interfaces, sorted
+func (rw ReaderWriter) ReadInterfaces() ([]string, error) {
+	entries, err := os.ReadDir(rw.NetPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", rw.NetPath, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReadQueues returns the queues of an interface with the given prefix,
+// "rx" or "tx", sorted by queue index
+func (rw ReaderWriter) ReadQueues(iface, prefix string) ([]Queue, error) {
+	queuesDir := filepath.Join(rw.NetPath, iface, "queues")
+	entries, err := os.ReadDir(queuesDir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", queuesDir, err)
+	}
+
+	var queues []Queue
+	for _, entry := range entries {
+		index, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), prefix+"-"))
+		if err != nil || !strings.HasPrefix(entry.Name(), prefix+"-") {
+			continue
+		}
+		queues = append(queues, Queue{Dir: entry.Name(), Index: index})
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		return queues[i].Index < queues[j].Index
+	})
+	return queues, nil
+}
+
+// WriteQueue writes a value to a file of a queue and returns the previous value
+func (rw ReaderWriter) WriteQueue(iface string, queue Queue, file, value string) (string, error) {
+	path := filepath.Join(rw.NetPath, iface, "queues", queue.Dir, file)
+	previous, err := utils.ReadTrimmed(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if err := utils.WriteOnly(path, value); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+func ApplyNetSteeringConfig(config *model.InternalConfig) error {
+	utils.PrintTitle("Network Steering")
+	if len(config.Data.NetSteering) == 0 {
+		log.Println("No network steering rules found in config")
+		return nil
+	}
+	return netSteeringReaderWriter.applyNetSteeringConfig(config.Data.NetSteering)
+}
+
+// Apply changes based on YAML config
+func (rw ReaderWriter) applyNetSteeringConfig(rules model.NetSteering) error {
+	ifaces, err := rw.ReadInterfaces()
+	if err != nil {
+		return err
+	}
+
+	for _, label := range rules.Labels() {
+		rule := rules[label]
+		log.Printf("Rule: %s\n", label)
+
+		// Match the whole name, so plain interface names match exactly
+		re, err := regexp.Compile("^(?:" + rule.Interfaces + ")$")
+		if err != nil {
+			return err
+		}
+
+		var msgs []string
+		for _, iface := range ifaces {
+			if !re.MatchString(iface) {
+				continue
+			}
+			ifaceMsgs, err := rw.applyRule(iface, rule)
+			if err != nil {
+				return fmt.Errorf("failed to apply network steering rule #%s for %s: %v",
+					label, iface, err)
+			}
+			msgs = append(msgs, ifaceMsgs...)
+		}
+		if len(msgs) == 0 {
+			msgs = append(msgs, fmt.Sprintf("No interfaces matched %q", rule.Interfaces))
+		}
+		utils.LogTreeStyle(msgs)
+	}
+	return nil
+}
+
+// queueSetting is a value to be written to a file of each queue
+type queueSetting struct {
+	file  string
+	value string
+}
+
+// applyRule writes the settings of a rule to the queues of an interface and
+// returns a message per queue
+func (rw ReaderWriter) applyRule(iface string, rule model.NetSteeringRule) ([]string, error) {
+	var rxSettings, txSettings []queueSetting
+	if rule.RPSCPUs != "" {
+		cpus, err := cpulists.Parse(rule.RPSCPUs)
+		if err != nil {
+			return nil, err
+		}
+		rxSettings = append(rxSettings, queueSetting{"rps_cpus", cpulists.GenHexMask(cpus)})
+	}
+	if rule.RPSFlowCnt != nil {
+		rxSettings = append(rxSettings,
+			queueSetting{"rps_flow_cnt", strconv.Itoa(*rule.RPSFlowCnt)})
+	}
+	if rule.XPSCPUs != "" {
+		cpus, err := cpulists.Parse(rule.XPSCPUs)
+		if err != nil {
+			return nil, err
+		}
+		txSettings = append(txSettings, queueSetting{"xps_cpus", cpulists.GenHexMask(cpus)})
+	}
+
+	var msgs []string
+	for _, group := range []struct {
+		prefix   string
+		settings []queueSetting
+	}{
+		{"rx", rxSettings},
+		{"tx", txSettings},
+	} {
+		if len(group.settings) == 0 {
+			continue
+		}
+		queues, err := rw.ReadQueues(iface, group.prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, queue := range queues {
+			var changes, warnings []string
+			for _, setting := range group.settings {
+				previous, err := rw.WriteQueue(iface, queue, setting.file, setting.value)
+				// Single queue devices have no xps_cpus, and kernels
+				// without RPS support no rps_cpus
+				if errors.Is(err, os.ErrNotExist) {
+					warnings = append(warnings, fmt.Sprintf(
+						"Warning: %s %s: %s not supported, skipped",
+						iface, queue.Dir, setting.file))
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				if !sameValue(setting.file, previous, setting.value) {
+					changes = append(changes, fmt.Sprintf("%s %s -> %s",
+						setting.file, previous, setting.value))
+				}
+			}
+			if len(changes) > 0 || len(warnings) < len(group.settings) {
+				msgs = append(msgs, changeMsg(iface, queue, changes))
+			}
+			msgs = append(msgs, warnings...)
+		}
+	}
+	return msgs, nil
+}
+
+// sameValue compares a value read from a queue file with the written one.
+// The kernel reports the masks zero padded in groups of 32 bits.
+func sameValue(file, previous, value string) bool {
+	if file == "rps_flow_cnt" {
+		return previous == value
+	}
+	return normalizeMask(previous) == normalizeMask(value)
+}
+
+func normalizeMask(mask string) string {
+	mask = strings.TrimLeft(strings.ReplaceAll(mask, ",", ""), "0")
+	if mask == "" {
+		return "0"
+	}
+	return mask
+}
+
+func changeMsg(iface string, queue Queue, changes []string) string {
+	if len(changes) == 0 {
+		return fmt.Sprintf("%s %s: unchanged", iface, queue.Dir)
+	}
+	return fmt.Sprintf("%s %s: %s", iface, queue.Dir, strings.Join(changes, ", "))
+}
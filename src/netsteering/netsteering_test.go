@@ -0,0 +1,187 @@
+package netsteering
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+// setupNetDir creates a fake /sys/class/net directory with the given
+// interfaces, each with two receive queues and one transmit queue
+func setupNetDir(t *testing.T, ifaces ...string) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	for _, iface := range ifaces {
+		queues := map[string]map[string]string{
+			"rx-0": {"rps_cpus": "00000000\n", "rps_flow_cnt": "0\n"},
+			"rx-1": {"rps_cpus": "00000001\n", "rps_flow_cnt": "0\n"},
+			"tx-0": {"xps_cpus": "00000000\n"},
+		}
+		for queue, files := range queues {
+			queueDir := filepath.Join(tmpDir, iface, "queues", queue)
+			if err := os.MkdirAll(queueDir, 0o755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(queueDir, name),
+					[]byte(content), 0o644); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+		}
+	}
+	return tmpDir
+}
+
+func readQueueFile(t *testing.T, netPath, iface, queue, file string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(netPath, iface, "queues", queue, file))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func TestApplyNetSteeringConfig(t *testing.T) {
+	flowCnt := 4096
+
+	testCases := []struct {
+		name     string
+		rule     model.NetSteeringRule
+		expected map[string]string
+	}{
+		{
+			name: "rps and rfs",
+			rule: model.NetSteeringRule{
+				Interfaces: "eth0",
+				RPSCPUs:    "0",
+				RPSFlowCnt: &flowCnt,
+			},
+			expected: map[string]string{
+				"eth0/rx-0/rps_cpus":     "1",
+				"eth0/rx-1/rps_cpus":     "1",
+				"eth0/rx-0/rps_flow_cnt": "4096",
+				"eth0/tx-0/xps_cpus":     "00000000",
+				"eth01/rx-0/rps_cpus":    "00000000",
+			},
+		},
+		{
+			name: "xps by regex",
+			rule: model.NetSteeringRule{
+				Interfaces: "eth.*",
+				XPSCPUs:    "0",
+			},
+			expected: map[string]string{
+				"eth0/tx-0/xps_cpus":  "1",
+				"eth01/tx-0/xps_cpus": "1",
+				"eth0/rx-0/rps_cpus":  "00000000",
+			},
+		},
+		{
+			name: "no matching interface",
+			rule: model.NetSteeringRule{
+				Interfaces: "wlan0",
+				RPSCPUs:    "0",
+			},
+			expected: map[string]string{
+				"eth0/rx-0/rps_cpus": "00000000",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			netPath := setupNetDir(t, "eth0", "eth01")
+			rw := ReaderWriter{NetPath: netPath}
+
+			err := rw.applyNetSteeringConfig(model.NetSteering{"foo": tc.rule})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for path, want := range tc.expected {
+				parts := strings.Split(path, "/")
+				got := readQueueFile(t, netPath, parts[0], parts[1], parts[2])
+				if got != want {
+					t.Errorf("%s: expected %q, got %q", path, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRuleMessages(t *testing.T) {
+	netPath := setupNetDir(t, "eth0")
+	rw := ReaderWriter{NetPath: netPath}
+
+	msgs, err := rw.applyRule("eth0", model.NetSteeringRule{
+		Interfaces: "eth0",
+		RPSCPUs:    "0",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"eth0 rx-0: rps_cpus 00000000 -> 1",
+		"eth0 rx-1: unchanged",
+	}
+	if len(msgs) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, msgs)
+	}
+	for i := range expected {
+		if msgs[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], msgs[i])
+		}
+	}
+}
+
+// A missing queue file only skips that queue, e.g. xps_cpus of single
+// queue devices
+func TestApplyRuleMissingQueueFile(t *testing.T) {
+	netPath := setupNetDir(t, "eth0")
+	if err := os.Remove(filepath.Join(netPath, "eth0", "queues", "tx-0", "xps_cpus")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	rw := ReaderWriter{NetPath: netPath}
+
+	msgs, err := rw.applyRule("eth0", model.NetSteeringRule{
+		Interfaces: "eth0",
+		RPSCPUs:    "0",
+		XPSCPUs:    "0",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"eth0 rx-0: rps_cpus 00000000 -> 1",
+		"eth0 rx-1: unchanged",
+		"Warning: eth0 tx-0: xps_cpus not supported, skipped",
+	}
+	if !slices.Equal(msgs, expected) {
+		t.Fatalf("expected %v, got %v", expected, msgs)
+	}
+	if got := readQueueFile(t, netPath, "eth0", "rx-0", "rps_cpus"); got != "1" {
+		t.Errorf("expected rps_cpus %q, got %q", "1", got)
+	}
+}
+
+func TestApplyNetSteeringConfigMissingQueues(t *testing.T) {
+	netPath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(netPath, "lo"), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	rw := ReaderWriter{NetPath: netPath}
+
+	err := rw.applyNetSteeringConfig(model.NetSteering{
+		"foo": {Interfaces: "lo", RPSCPUs: "0"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to apply network steering rule #foo for lo") {
+		t.Fatalf("expected error, got: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package pmqos
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Documentation for the PM QoS interfaces:
+// https://docs.kernel.org/power/pm_qos_interface.html
+
+// The CPU latency request on /dev/cpu_dma_latency is only held while the
+// file descriptor is open, that's why this runs as a long-running process.
+
+type Holder struct {
+	CpuDmaLatencyPath string
+	ResumeLatencyPath string
+}
+
+var pmQosHolder = Holder{
+	CpuDmaLatencyPath: "/dev/cpu_dma_latency",
+	ResumeLatencyPath: "/sys/devices/system/cpu/cpu%d/power/pm_qos_resume_latency_us",
+}
+
+// resumeLatency keeps the value of a per-CPU resume latency before it got
+// changed, so it can be restored on release
+type resumeLatency struct {
+	cpu      int
+	previous string
+}
+
+// HoldPmQosConfig applies the PM QoS requests and holds them until the
+// context is done, then releases them.
+func HoldPmQosConfig(ctx context.Context, config *model.InternalConfig) error {
+	utils.PrintTitle("PM QoS")
+	if config.Data.PmQos.IsEmpty() {
+		return fmt.Errorf("no PM QoS requests found in config")
+	}
+	return pmQosHolder.hold(ctx, config.Data.PmQos)
+}
+
+func (h Holder) hold(ctx context.Context, cfg model.PmQos) (err error) {
+	var dmaLatency *os.File
+	var changed []resumeLatency
+
+	defer func() {
+		if releaseErr := h.release(dmaLatency, changed); releaseErr != nil {
+			if err == nil {
+				err = releaseErr
+			} else {
+				log.Printf("Failed to release PM QoS requests: %v", releaseErr)
+			}
+		}
+	}()
+
+	if cfg.CpuDmaLatency != nil {
+		dmaLatency, err = h.requestCpuDmaLatency(*cfg.CpuDmaLatency)
+		if err != nil {
+			return err
+		}
+		utils.LogTreeStyle([]string{
+			fmt.Sprintf("Holding CPU latency request of %dus",
+				*cfg.CpuDmaLatency)})
+	}
+
+	for _, label := range cfg.ResumeLatency.Labels() {
+		rule := cfg.ResumeLatency[label]
+		log.Printf("Rule: %s\n", label)
+
+		cpus, offline, err := cpulists.ParseOnline(rule.CPUs)
+		if err != nil {
+			return err
+		}
+		cpulists.LogOffline(offline)
+
+		var setCpus []int
+		for cpu := range cpus {
+			previous, err := h.writeResumeLatency(cpu, rule.Latency)
+			if err != nil {
+				return fmt.Errorf(
+					"failed to apply resume latency rule #%s for CPU %d: %v",
+					label, cpu, err)
+			}
+			changed = append(changed, resumeLatency{cpu, previous})
+			setCpus = append(setCpus, cpu)
+		}
+		logChanges(setCpus, rule.Latency)
+	}
+
+	<-ctx.Done()
+	log.Println("Releasing PM QoS requests")
+	return nil
+}
+
+// requestCpuDmaLatency opens the CPU latency device and writes the
+// requested latency as a binary 32-bit integer. The request is held until
+// the returned file is closed.
+func (h Holder) requestCpuDmaLatency(latency int) (*os.File, error) {
+	f, err := os.OpenFile(h.CpuDmaLatencyPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v",
+			h.CpuDmaLatencyPath, err)
+	}
+
+	if err := binary.Write(f, binary.NativeEndian, int32(latency)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error writing to %s: %v",
+			h.CpuDmaLatencyPath, err)
+	}
+	return f, nil
+}
+
+// writeResumeLatency sets the resume latency of a CPU and returns the
+// previous value
+func (h Holder) writeResumeLatency(cpu int, latency string) (string, error) {
+	path := fmt.Sprintf(h.ResumeLatencyPath, cpu)
+
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	if err := utils.WriteOnly(path, latency); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(previous)), nil
+}
+
+// release closes the CPU latency device and restores the previous
+// per-CPU resume latencies
+func (h Holder) release(dmaLatency *os.File, changed []resumeLatency) error {
+	var errs []string
+	if dmaLatency != nil {
+		if err := dmaLatency.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	// Restore in reverse order, so CPUs set by overlapping rules get the
+	// value found before the first of them
+	for i := len(changed) - 1; i >= 0; i-- {
+		c := changed[i]
+		path := fmt.Sprintf(h.ResumeLatencyPath, c.cpu)
+		if err := utils.WriteOnly(path, c.previous); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func logChanges(cpus []int, latency string) {
+	pluralSuffix := "s"
+	if len(cpus) == 1 {
+		pluralSuffix = ""
+	}
+	utils.LogTreeStyle([]string{
+		fmt.Sprintf("Set resume latency of CPU%s %s to %s", pluralSuffix,
+			cpulists.GenCPUlist(cpus), latency)})
+}
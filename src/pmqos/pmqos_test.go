@@ -0,0 +1,166 @@
+package pmqos
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+func setupHolder(t *testing.T, prevResumeLatency string) Holder {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	dmaLatency := filepath.Join(tmpDir, "cpu_dma_latency")
+	if err := os.WriteFile(dmaLatency, nil, 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	cpuDir := filepath.Join(tmpDir, "0")
+	if err := os.Mkdir(cpuDir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cpuDir, "resume_latency"),
+		[]byte(prevResumeLatency+"\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	return Holder{
+		CpuDmaLatencyPath: dmaLatency,
+		ResumeLatencyPath: filepath.Join(tmpDir, "%d", "resume_latency"),
+	}
+}
+
+func TestHold(t *testing.T) {
+	h := setupHolder(t, "0")
+	latency := 5
+
+	cfg := model.PmQos{
+		CpuDmaLatency: &latency,
+		ResumeLatency: model.PmQosResumeLatencies{
+			"isolated": {
+				CPUs:    "0",
+				Latency: "n/a",
+			},
+		},
+	}
+
+	// The context is already done, so the requests are released right
+	// after being applied
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := h.hold(ctx, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(h.CpuDmaLatencyPath)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if len(content) != 4 {
+		t.Fatalf("expected 4 bytes written, got %d", len(content))
+	}
+	if got := binary.NativeEndian.Uint32(content); got != uint32(latency) {
+		t.Fatalf("expected latency %d, got %d", latency, got)
+	}
+
+	resumeLatencyPath := strings.Replace(h.ResumeLatencyPath, "%d", "0", 1)
+	content, err = os.ReadFile(resumeLatencyPath)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "0" {
+		t.Fatalf("expected resume latency to be restored to %q, got %q",
+			"0", string(content))
+	}
+}
+
+// CPUs set by overlapping rules get the value found before the first one
+func TestHoldOverlappingRules(t *testing.T) {
+	h := setupHolder(t, "0")
+
+	cfg := model.PmQos{
+		ResumeLatency: model.PmQosResumeLatencies{
+			"a": {CPUs: "0", Latency: "10"},
+			"b": {CPUs: "0", Latency: "n/a"},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := h.hold(ctx, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resumeLatencyPath := strings.Replace(h.ResumeLatencyPath, "%d", "0", 1)
+	content, err := os.ReadFile(resumeLatencyPath)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "0" {
+		t.Fatalf("expected resume latency to be restored to %q, got %q",
+			"0", string(content))
+	}
+}
+
+func TestHoldUnhappy(t *testing.T) {
+	latency := 0
+
+	testCases := []struct {
+		name string
+		cfg  model.PmQos
+		h    func(h Holder) Holder
+		err  string
+	}{
+		{
+			name: "missing cpu_dma_latency",
+			cfg:  model.PmQos{CpuDmaLatency: &latency},
+			h: func(h Holder) Holder {
+				h.CpuDmaLatencyPath = "/does/not/exist"
+				return h
+			},
+			err: "error opening /does/not/exist",
+		},
+		{
+			name: "missing resume latency file",
+			cfg: model.PmQos{
+				ResumeLatency: model.PmQosResumeLatencies{
+					"foo": {CPUs: "0", Latency: "10"},
+				},
+			},
+			h: func(h Holder) Holder {
+				h.ResumeLatencyPath = "/does/not/exist/%d"
+				return h
+			},
+			err: "failed to apply resume latency rule #foo for CPU 0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := tc.h(setupHolder(t, "0"))
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := h.hold(ctx, tc.cfg)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestHoldPmQosConfigEmpty(t *testing.T) {
+	err := HoldPmQosConfig(context.Background(), &model.InternalConfig{})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
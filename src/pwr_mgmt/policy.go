@@ -0,0 +1,41 @@
+package pwrmgmt
+
+import (
+	"sort"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+)
+
+// ReadPolicies reads the cpufreq policies from sysfs
+func (w ReaderWriter) ReadPolicies() (model.Policies, error) {
+	return model.ReadPolicies(w.PoliciesPath)
+}
+
+// representativeCPUs returns one CPU of the rule per policy, the lowest one,
+// along with the CPUs outside of the rule affected by writing to it
+func representativeCPUs(cpus cpulists.CPUs, policies model.Policies) ([]int, []int) {
+	sorted := make([]int, 0, len(cpus))
+	for cpu := range cpus {
+		sorted = append(sorted, cpu)
+	}
+	sort.Ints(sorted)
+
+	seen := make(map[int]bool)
+	var representatives, affected []int
+	for _, cpu := range sorted {
+		policy := policies.Of(cpu)
+		if seen[policy.ID] {
+			continue
+		}
+		seen[policy.ID] = true
+		representatives = append(representatives, cpu)
+
+		for _, related := range policy.CPUs {
+			if !cpus[related] {
+				affected = append(affected, related)
+			}
+		}
+	}
+	return representatives, affected
+}
@@ -0,0 +1,81 @@
+package pwrmgmt
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+)
+
+func TestReadPolicies(t *testing.T) {
+	tmpDir := t.TempDir()
+	policies := map[string]string{
+		"policy0": "0 1\n",
+		"policy2": "2 3\n",
+	}
+	for name, relatedCPUs := range policies {
+		dir := filepath.Join(tmpDir, name)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "related_cpus"),
+			[]byte(relatedCPUs), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	// Not a policy
+	if err := os.Mkdir(filepath.Join(tmpDir, "boost"), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+
+	rw := ReaderWriter{PoliciesPath: tmpDir}
+	got, err := rw.ReadPolicies()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := model.Policies{
+		0: {ID: 0, CPUs: []int{0, 1}},
+		1: {ID: 0, CPUs: []int{0, 1}},
+		2: {ID: 2, CPUs: []int{2, 3}},
+		3: {ID: 2, CPUs: []int{2, 3}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	// CPUs without a policy have their own
+	if policy := got.Of(7); policy.ID != 7 || len(policy.CPUs) != 1 {
+		t.Fatalf("unexpected policy for CPU 7: %v", policy)
+	}
+}
+
+func TestReadPoliciesNoCpufreq(t *testing.T) {
+	rw := ReaderWriter{PoliciesPath: "/does/not/exist"}
+	got, err := rw.ReadPolicies()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected no policies, got %v", got)
+	}
+}
+
+func TestRepresentativeCPUs(t *testing.T) {
+	first := model.Policy{ID: 0, CPUs: []int{0, 1}}
+	second := model.Policy{ID: 2, CPUs: []int{2, 3}}
+	policies := model.Policies{0: first, 1: first, 2: second, 3: second}
+
+	representatives, affected := representativeCPUs(
+		cpulists.CPUs{1: true, 2: true, 3: true}, policies)
+
+	if !reflect.DeepEqual(representatives, []int{1, 2}) {
+		t.Errorf("expected representatives [1 2], got %v", representatives)
+	}
+	if !reflect.DeepEqual(affected, []int{0}) {
+		t.Errorf("expected affected [0], got %v", affected)
+	}
+}
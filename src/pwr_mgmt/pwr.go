@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/canonical/rt-conf/src/cpulists"
 	"github.com/canonical/rt-conf/src/model"
@@ -15,59 +18,90 @@ type ReaderWriter struct {
 	ScalingGovernorPath string
 	MinFreqPath         string
 	MaxFreqPath         string
+	PoliciesPath        string
+	// Strict turns any mismatch between the written and read back values
+	// into an error
+	Strict bool
 }
 
 var pwrmgmtReaderWriter = ReaderWriter{
 	ScalingGovernorPath: "/sys/devices/system/cpu/cpu%d/cpufreq/scaling_governor",
 	MinFreqPath:         "/sys/devices/system/cpu/cpu%d/cpufreq/scaling_min_freq",
 	MaxFreqPath:         "/sys/devices/system/cpu/cpu%d/cpufreq/scaling_max_freq",
+	PoliciesPath:        "/sys/devices/system/cpu/cpufreq",
 }
 
-func writeOnly(path string, data string) error {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
-	if err != nil {
-		return fmt.Errorf("error opening %s: %v", path, err)
+var readFile = func(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// writeVerified writes the data and reads it back, since cpufreq drivers
+// may silently clamp or ignore the written value.
+// It returns a warning with the effective value on mismatch, or an error
+// when in strict mode.
+func writeVerified(path string, data string, strict bool) (string, error) {
+	if err := utils.WriteOnly(path, data); err != nil {
+		return "", err
 	}
-	defer f.Close()
 
-	_, err = f.Write([]byte(data))
+	content, err := readFile(path)
 	if err != nil {
-		return fmt.Errorf("error writing to %s: %v", path, err)
+		return "", fmt.Errorf("error reading back %s: %v", path, err)
 	}
-	return nil
+	effective := strings.TrimSpace(string(content))
+	if effective == data {
+		return "", nil
+	}
+
+	msg := fmt.Sprintf("%s is %s, requested %s",
+		filepath.Base(path), effective, data)
+	if strict {
+		return "", fmt.Errorf("mismatch on %s: %s", path, msg)
+	}
+	return msg, nil
 }
 
-func (w ReaderWriter) WriteScalingGov(sclgov string, cpu int) error {
+func (w ReaderWriter) WriteScalingGov(sclgov string, cpu int) (string, error) {
 	if sclgov == "" {
-		return nil // No scaling governor set, nothing to write
+		return "", nil // No scaling governor set, nothing to write
 	}
 	scalingGovFile := fmt.Sprintf(w.ScalingGovernorPath, cpu)
 
-	err := writeOnly(scalingGovFile, sclgov)
+	warning, err := writeVerified(scalingGovFile, sclgov, w.Strict)
 	if err != nil {
-		return fmt.Errorf("error writing to %s: %v", scalingGovFile, err)
+		return "", fmt.Errorf("error writing to %s: %v", scalingGovFile, err)
 	}
-	return nil
+	return warning, nil
 }
 
-func (w ReaderWriter) WriteCPUFreq(freqMin, freqMax, cpu int) error {
+func (w ReaderWriter) WriteCPUFreq(freqMin, freqMax, cpu int) ([]string, error) {
+	var warnings []string
+
 	if freqMin != -1 {
 		minFreqSysfs := fmt.Sprintf(w.MinFreqPath, cpu)
-		if err := writeOnly(minFreqSysfs,
-			strconv.Itoa(freqMin)); err != nil {
-			return fmt.Errorf("error writing to %s: %v", minFreqSysfs, err)
+		warning, err := writeVerified(minFreqSysfs,
+			strconv.Itoa(freqMin), w.Strict)
+		if err != nil {
+			return nil, fmt.Errorf("error writing to %s: %v", minFreqSysfs, err)
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
 		}
 	}
 
 	if freqMax != -1 {
 		maxFreqSysfs := fmt.Sprintf(w.MaxFreqPath, cpu)
-		if err := writeOnly(maxFreqSysfs,
-			strconv.Itoa(freqMax)); err != nil {
-			return fmt.Errorf("error writing to %s: %v", maxFreqSysfs, err)
+		warning, err := writeVerified(maxFreqSysfs,
+			strconv.Itoa(freqMax), w.Strict)
+		if err != nil {
+			return nil, fmt.Errorf("error writing to %s: %v", maxFreqSysfs, err)
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
 		}
 	}
 
-	return nil
+	return warnings, nil
 }
 
 func ApplyPwrConfig(config *model.InternalConfig) error {
@@ -76,38 +110,73 @@ func ApplyPwrConfig(config *model.InternalConfig) error {
 		log.Println("No CPU governance rules found in config")
 		return nil
 	}
-	return pwrmgmtReaderWriter.applyPwrConfig(config.Data.CpuGovernance)
+	rw := pwrmgmtReaderWriter
+	rw.Strict = config.Strict
+	return rw.applyPwrConfig(config.Data.CpuGovernance)
 }
 
 // Apply changes based on YAML config
 func (wr ReaderWriter) applyPwrConfig(
 	rules model.PwrMgmt,
 ) error {
-	// Range over all CPU governance rules
-	for label, sclgov := range rules {
+	policies, err := wr.ReadPolicies()
+	if err != nil {
+		return fmt.Errorf("failed to read cpufreq policies: %v", err)
+	}
+	overlaps, err := rules.ValidatePolicies(policies)
+	if err != nil {
+		return err
+	}
+
+	// Range over all CPU governance rules, the highest priority last
+	for _, label := range rules.Labels() {
+		sclgov := rules[label]
 
 		log.Printf("Rule: %s \n", label)
-		cpus, err := cpulists.Parse(sclgov.CPUs)
+		cpus, offline, err := cpulists.ParseOnline(sclgov.CPUs)
 		if err != nil {
 			return err
 		}
+		cpulists.LogOffline(offline)
 
 		var setCpus []int
-
 		for cpu := range cpus {
-			if err := wr.applyRule(cpu, sclgov); err != nil {
+			setCpus = append(setCpus, cpu)
+		}
+
+		// Write once per policy, through one of its CPUs
+		representatives, affected := representativeCPUs(cpus, policies)
+
+		var warnings []string
+		for _, cpu := range representatives {
+			cpuWarnings, err := wr.applyRule(cpu, sclgov)
+			if err != nil {
 				return fmt.Errorf("failed to apply CPU governance rule #%s for CPU %d: %v",
 					label, cpu, err)
 			}
-			setCpus = append(setCpus, cpu)
+			for _, warning := range cpuWarnings {
+				warnings = append(warnings,
+					fmt.Sprintf("Warning: %s: %s", policies.Of(cpu), warning))
+			}
+		}
+		sort.Strings(warnings)
+		if len(affected) > 0 {
+			warnings = append(warnings, fmt.Sprintf(
+				"Warning: CPUs %s are also affected, as they share a cpufreq policy with this rule",
+				cpulists.GenCPUlist(affected)))
 		}
-		logChanges(setCpus, sclgov.MinFreq, sclgov.MaxFreq, sclgov.ScalGov)
+		logChanges(setCpus, sclgov.MinFreq, sclgov.MaxFreq, sclgov.ScalGov,
+			warnings)
 	}
 
+	if len(overlaps) > 0 {
+		log.Println("WARNING: overlapping CPU governance rules")
+		utils.LogTreeStyle(overlaps)
+	}
 	return nil
 }
 
-func logChanges(cpus []int, minFreq, maxFreq, scalingGov string) {
+func logChanges(cpus []int, minFreq, maxFreq, scalingGov string, warnings []string) {
 	pluralSuffix := "s"
 	if len(cpus) == 1 {
 		pluralSuffix = ""
@@ -131,26 +200,36 @@ func logChanges(cpus []int, minFreq, maxFreq, scalingGov string) {
 				cpuList, maxFreq))
 	}
 
+	msg = append(msg, warnings...)
+
 	utils.LogTreeStyle(msg)
 }
 
-func (wr ReaderWriter) applyRule(cpu int, sclgov model.CpuGovernanceRule) error {
-	if err := wr.WriteScalingGov(sclgov.ScalGov, cpu); err != nil {
-		return err
+// applyRule applies a CPU governance rule to a CPU and returns warnings
+// for the values which were not applied as requested
+func (wr ReaderWriter) applyRule(cpu int, sclgov model.CpuGovernanceRule) ([]string, error) {
+	var warnings []string
+	warning, err := wr.WriteScalingGov(sclgov.ScalGov, cpu)
+	if err != nil {
+		return nil, err
+	}
+	if warning != "" {
+		warnings = append(warnings, warning)
 	}
 	minFreq, err := model.ParseFreq(sclgov.MinFreq)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	maxFreq, err := model.ParseFreq(sclgov.MaxFreq)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := wr.WriteCPUFreq(
+	freqWarnings, err := wr.WriteCPUFreq(
 		minFreq,
 		maxFreq,
-		cpu); err != nil {
-		return fmt.Errorf("failed to set CPU frequency for CPU %d: %v", cpu, err)
+		cpu)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set CPU frequency for CPU %d: %v", cpu, err)
 	}
-	return nil
+	return append(warnings, freqWarnings...), nil
 }
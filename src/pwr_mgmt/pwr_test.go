@@ -9,7 +9,9 @@ import (
 	"testing"
 
 	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/hotplug"
 	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
 )
 
 // setupTempDirWithFiles creates a temporary directory and then creates n files
@@ -401,7 +403,7 @@ func TestWriteOnly(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.prepare(tc.path)
 
-			err := writeOnly(tc.path, tc.data)
+			err := utils.WriteOnly(tc.path, tc.data)
 			if tc.expectError && err == nil {
 				t.Errorf("expected error but got none")
 			}
@@ -452,7 +454,7 @@ func TestApplyRuleUnhappy(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			err := pwrmgmtReaderWriter.applyRule(0, tc.sclgov)
+			_, err := pwrmgmtReaderWriter.applyRule(0, tc.sclgov)
 			if err == nil {
 				t.Fatalf(
 					"expected error when processing %+v got nil", tc.sclgov,
@@ -461,3 +463,127 @@ func TestApplyRuleUnhappy(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteVerified(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "scaling_max_freq")
+	if err := os.WriteFile(path, []byte("0"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	t.Cleanup(func() {
+		readFile = func(name string) ([]byte, error) {
+			return os.ReadFile(name)
+		}
+	})
+
+	testCases := []struct {
+		name      string
+		effective string
+		strict    bool
+		warning   string
+		err       string
+	}{
+		{
+			name:      "value applied",
+			effective: "2000000\n",
+		},
+		{
+			name:      "value clamped",
+			effective: "1800000\n",
+			warning:   "scaling_max_freq is 1800000, requested 2000000",
+		},
+		{
+			name:      "value clamped in strict mode",
+			effective: "1800000\n",
+			strict:    true,
+			err:       "mismatch on " + path,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Simulate the driver clamping the written value
+			readFile = func(_ string) ([]byte, error) {
+				return []byte(tc.effective), nil
+			}
+
+			warning, err := writeVerified(path, "2000000", tc.strict)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if warning != tc.warning {
+				t.Fatalf("expected warning %q, got %q", tc.warning, warning)
+			}
+		})
+	}
+}
+
+// An offline hotplug rule is applied first and must not break the rules
+// covering all CPUs
+func TestOfflineCPUWithGovernanceForAll(t *testing.T) {
+	root := t.TempDir()
+	t.Cleanup(cpulists.SetSysfsRoot(root))
+	cpuDir := cpulists.SysCPUPath()
+
+	writeTestFile := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	for _, name := range []string{"possible", "present", "online"} {
+		writeTestFile(filepath.Join(cpuDir, name), "0-3\n")
+	}
+	for cpu := range 4 {
+		dir := filepath.Join(cpuDir, fmt.Sprintf("cpu%d", cpu))
+		writeTestFile(filepath.Join(dir, "online"), "1\n")
+		writeTestFile(filepath.Join(dir, "cpufreq", "scaling_governor"), "powersave")
+	}
+
+	err := hotplug.ApplyHotplugConfig(&model.InternalConfig{
+		Data: model.Config{
+			CpuHotplug: model.CpuHotplug{
+				"0": {CPUs: "3", State: model.CpuOffline},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to apply hotplug config: %v", err)
+	}
+	// The kernel updates the online CPUs when one goes offline
+	writeTestFile(filepath.Join(cpuDir, "online"), "0-2\n")
+
+	rw := ReaderWriter{
+		ScalingGovernorPath: filepath.Join(cpuDir, "cpu%d", "cpufreq", "scaling_governor"),
+		MinFreqPath:         filepath.Join(cpuDir, "cpu%d", "cpufreq", "scaling_min_freq"),
+		MaxFreqPath:         filepath.Join(cpuDir, "cpu%d", "cpufreq", "scaling_max_freq"),
+		PoliciesPath:        filepath.Join(cpuDir, "cpufreq"),
+	}
+	err = rw.applyPwrConfig(model.PwrMgmt{
+		"0": {CPUs: "all", ScalGov: "performance"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	for cpu, expected := range []string{"performance", "performance", "performance", "powersave"} {
+		path := fmt.Sprintf(rw.ScalingGovernorPath, cpu)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if string(content) != expected {
+			t.Errorf("CPU %d: expected %s, got %s", cpu, expected, content)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package pwrmgmt
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Documentation for the Intel uncore frequency scaling driver:
+// https://docs.kernel.org/admin-guide/pm/intel_uncore_frequency_scaling.html
+
+type UncoreReaderWriter struct {
+	UncorePath string
+	// Strict turns any mismatch between the written and read back values
+	// into an error
+	Strict bool
+}
+
+var uncoreReaderWriter = UncoreReaderWriter{
+	UncorePath: "/sys/devices/system/cpu/intel_uncore_frequency",
+}
+
+// Uncore domains are named after the package and die they belong to, or
+// numbered on TPMI based platforms, e.g. uncore00
+var uncoreDomainName = regexp.MustCompile(`^(?:package_\d+_die_\d+|uncore\d+)$`)
+
+// UncoreDomain holds the hardware frequency limits of an uncore domain, in kHz
+type UncoreDomain struct {
+	Name           string
+	InitialMinFreq int
+	InitialMaxFreq int
+}
+
+func readKHz(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid frequency in %s: %v", path, err)
+	}
+	return value, nil
+}
+
+// Available returns false if the uncore frequency driver isn't loaded
+func (rw UncoreReaderWriter) Available() bool {
+	_, err := os.Stat(rw.UncorePath)
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// ReadDomains returns the uncore domains sorted by name
+func (rw UncoreReaderWriter) ReadDomains() ([]UncoreDomain, error) {
+	entries, err := os.ReadDir(rw.UncorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []UncoreDomain
+	for _, entry := range entries {
+		if !uncoreDomainName.MatchString(entry.Name()) {
+			continue
+		}
+		dir := filepath.Join(rw.UncorePath, entry.Name())
+
+		minFreq, err := readKHz(filepath.Join(dir, "initial_min_freq_khz"))
+		if err != nil {
+			return nil, err
+		}
+		maxFreq, err := readKHz(filepath.Join(dir, "initial_max_freq_khz"))
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, UncoreDomain{
+			Name:           entry.Name(),
+			InitialMinFreq: minFreq,
+			InitialMaxFreq: maxFreq,
+		})
+	}
+
+	sort.Slice(domains, func(i, j int) bool {
+		return domains[i].Name < domains[j].Name
+	})
+	return domains, nil
+}
+
+func (rw UncoreReaderWriter) WriteUncoreFreq(domain UncoreDomain, freqMin, freqMax int) ([]string, error) {
+	dir := filepath.Join(rw.UncorePath, domain.Name)
+	minFreqSysfs := filepath.Join(dir, "min_freq_khz")
+	maxFreqSysfs := filepath.Join(dir, "max_freq_khz")
+
+	type freqWrite struct {
+		path string
+		freq int
+	}
+	writes := []freqWrite{{minFreqSysfs, freqMin}, {maxFreqSysfs, freqMax}}
+
+	// The driver rejects a min frequency above the current max frequency,
+	// so raise the max frequency first in that case
+	currentMax, err := readKHz(maxFreqSysfs)
+	if err != nil {
+		return nil, err
+	}
+	if freqMin > currentMax {
+		writes[0], writes[1] = writes[1], writes[0]
+	}
+
+	var warnings []string
+	for _, w := range writes {
+		if w.freq == -1 {
+			continue
+		}
+		warning, err := writeVerified(w.path, strconv.Itoa(w.freq), rw.Strict)
+		if err != nil {
+			return nil, err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings, nil
+}
+
+func ApplyUncoreConfig(config *model.InternalConfig) error {
+	utils.PrintTitle("Uncore Frequency")
+	if len(config.Data.UncoreFreq) == 0 {
+		log.Println("No uncore frequency rules found in config")
+		return nil
+	}
+	if !uncoreReaderWriter.Available() {
+		log.Printf("Skipping uncore frequency rules: the intel_uncore_frequency "+
+			"driver is not available (%s not found)\n",
+			uncoreReaderWriter.UncorePath)
+		return nil
+	}
+	rw := uncoreReaderWriter
+	rw.Strict = config.Strict
+	return rw.applyUncoreConfig(config.Data.UncoreFreq)
+}
+
+// Apply changes based on YAML config
+func (rw UncoreReaderWriter) applyUncoreConfig(rules model.UncoreFreq) error {
+	domains, err := rw.ReadDomains()
+	if err != nil {
+		return fmt.Errorf("failed to read uncore domains: %v", err)
+	}
+
+	for _, label := range rules.Labels() {
+		rule := rules[label]
+		log.Printf("Rule: %s \n", label)
+
+		minFreq, err := model.ParseFreq(rule.MinFreq)
+		if err != nil {
+			return err
+		}
+		maxFreq, err := model.ParseFreq(rule.MaxFreq)
+		if err != nil {
+			return err
+		}
+
+		pattern, err := regexp.Compile(rule.Domains)
+		if err != nil {
+			return err
+		}
+
+		var setDomains, warnings []string
+		for _, domain := range domains {
+			if !pattern.MatchString(domain.Name) {
+				continue
+			}
+			if err := validateUncoreFreq(domain, minFreq, maxFreq); err != nil {
+				return fmt.Errorf("uncore frequency rule #%s: %v", label, err)
+			}
+			domainWarnings, err := rw.WriteUncoreFreq(domain, minFreq, maxFreq)
+			if err != nil {
+				return fmt.Errorf(
+					"failed to apply uncore frequency rule #%s for %s: %v",
+					label, domain.Name, err)
+			}
+			for _, warning := range domainWarnings {
+				warnings = append(warnings,
+					fmt.Sprintf("Warning: %s: %s", domain.Name, warning))
+			}
+			setDomains = append(setDomains, domain.Name)
+		}
+
+		if len(setDomains) == 0 {
+			return fmt.Errorf("uncore frequency rule #%s: no uncore domains matched %q",
+				label, rule.Domains)
+		}
+		logUncoreChanges(setDomains, rule.MinFreq, rule.MaxFreq, warnings)
+	}
+	return nil
+}
+
+// validateUncoreFreq checks the frequencies against the hardware limits
+func validateUncoreFreq(domain UncoreDomain, minFreq, maxFreq int) error {
+	for _, freq := range []int{minFreq, maxFreq} {
+		if freq == -1 {
+			continue
+		}
+		if freq < domain.InitialMinFreq || freq > domain.InitialMaxFreq {
+			return fmt.Errorf(
+				"frequency %dkHz out of range for %s: %dkHz - %dkHz",
+				freq, domain.Name, domain.InitialMinFreq, domain.InitialMaxFreq)
+		}
+	}
+	return nil
+}
+
+func logUncoreChanges(domains []string, minFreq, maxFreq string, warnings []string) {
+	names := strings.Join(domains, ", ")
+
+	var msg []string
+	if minFreq != "" {
+		msg = append(msg,
+			fmt.Sprintf("Set min uncore frequency of %s to %s", names, minFreq))
+	}
+	if maxFreq != "" {
+		msg = append(msg,
+			fmt.Sprintf("Set max uncore frequency of %s to %s", names, maxFreq))
+	}
+	msg = append(msg, warnings...)
+	utils.LogTreeStyle(msg)
+}
@@ -0,0 +1,149 @@
+package pwrmgmt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+// setupUncoreDir creates a fake intel_uncore_frequency directory with the
+// given domains, all with the limits 800MHz - 2.4GHz
+func setupUncoreDir(t *testing.T, domains ...string) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	for _, domain := range domains {
+		dir := filepath.Join(tmpDir, domain)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		files := map[string]string{
+			"initial_min_freq_khz": "800000\n",
+			"initial_max_freq_khz": "2400000\n",
+			"min_freq_khz":         "800000\n",
+			"max_freq_khz":         "2400000\n",
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name),
+				[]byte(content), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+		}
+	}
+	return tmpDir
+}
+
+func readUncoreFile(t *testing.T, path ...string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(path...))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func TestApplyUncoreConfig(t *testing.T) {
+	path := setupUncoreDir(t, "package_00_die_00", "package_01_die_00")
+	rw := UncoreReaderWriter{UncorePath: path}
+
+	err := rw.applyUncoreConfig(model.UncoreFreq{
+		"fixed": {
+			Domains: "package_01",
+			MinFreq: "2GHz",
+			MaxFreq: "2GHz",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, file := range []string{"min_freq_khz", "max_freq_khz"} {
+		if got := readUncoreFile(t, path, "package_01_die_00", file); got != "2000000" {
+			t.Errorf("expected %s to be %q, got %q", file, "2000000", got)
+		}
+		if got := readUncoreFile(t, path, "package_00_die_00", file); got == "2000000" {
+			t.Errorf("expected %s of package_00_die_00 to be unchanged", file)
+		}
+	}
+}
+
+// TPMI based platforms number the uncore domains
+func TestApplyUncoreConfigTPMI(t *testing.T) {
+	path := setupUncoreDir(t, "uncore00", "uncore01")
+	rw := UncoreReaderWriter{UncorePath: path}
+
+	domains, err := rw.ReadDomains()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(domains) != 2 || domains[0].Name != "uncore00" || domains[1].Name != "uncore01" {
+		t.Fatalf("expected domains uncore00 and uncore01, got %v", domains)
+	}
+
+	err = rw.applyUncoreConfig(model.UncoreFreq{
+		"fixed": {Domains: "uncore01", MaxFreq: "2GHz"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readUncoreFile(t, path, "uncore01", "max_freq_khz"); got != "2000000" {
+		t.Errorf("expected max_freq_khz to be %q, got %q", "2000000", got)
+	}
+	if got := readUncoreFile(t, path, "uncore00", "max_freq_khz"); got != "2400000" {
+		t.Errorf("expected max_freq_khz of uncore00 to be unchanged, got %q", got)
+	}
+}
+
+func TestApplyUncoreConfigUnhappy(t *testing.T) {
+	testCases := []struct {
+		name string
+		rule model.UncoreFreqRule
+		err  string
+	}{
+		{
+			name: "out of range",
+			rule: model.UncoreFreqRule{MaxFreq: "3GHz"},
+			err:  "out of range for package_00_die_00",
+		},
+		{
+			name: "no matching domain",
+			rule: model.UncoreFreqRule{Domains: "package_99", MaxFreq: "2GHz"},
+			err:  "no uncore domains matched",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := UncoreReaderWriter{
+				UncorePath: setupUncoreDir(t, "package_00_die_00"),
+			}
+			err := rw.applyUncoreConfig(model.UncoreFreq{"foo": tc.rule})
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestApplyUncoreConfigDriverAbsent(t *testing.T) {
+	prev := uncoreReaderWriter
+	t.Cleanup(func() { uncoreReaderWriter = prev })
+	uncoreReaderWriter.UncorePath = "/does/not/exist"
+
+	err := ApplyUncoreConfig(&model.InternalConfig{
+		Data: model.Config{
+			UncoreFreq: model.UncoreFreq{
+				"foo": {MaxFreq: "2GHz"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected rules to be skipped, got error: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package sysctl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Documentation for the sysctl files under /proc/sys:
+// https://docs.kernel.org/admin-guide/sysctl/
+
+type ReaderWriter struct {
+	ProcSysPath string
+	// File in which the values found before the first apply are saved,
+	// so they can be reverted
+	SavedValuesFile string
+}
+
+var sysctlReaderWriter = ReaderWriter{
+	ProcSysPath:     "/proc/sys",
+	SavedValuesFile: savedValuesFile(),
+}
+
+// savedValuesFile returns the path of the saved values file, in the snap
+// data directory when running as a snap
+func savedValuesFile() string {
+	dir := os.Getenv("SNAP_DATA")
+	if dir == "" {
+		dir = "/var/lib/rt-conf"
+	}
+	return filepath.Join(dir, "sysctl-saved.conf")
+}
+
+// writeOnly is a variable, so tests can make writes fail
+var writeOnly = utils.WriteOnly
+
+// path returns the /proc/sys file of a sysctl key, e.g. vm.swappiness
+func (rw ReaderWriter) path(key string) string {
+	return filepath.Join(rw.ProcSysPath, strings.ReplaceAll(key, ".", "/"))
+}
+
+// Read reads the current value of a sysctl key
+func (rw ReaderWriter) Read(key string) (string, error) {
+	content, err := os.ReadFile(rw.path(key))
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %v", rw.path(key), err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Write writes the value of a sysctl key
+func (rw ReaderWriter) Write(key, value string) error {
+	return writeOnly(rw.path(key), value)
+}
+
+// GenConfig generates sysctl configuration file content, in the
+// sysctl.conf(5) format
+func GenConfig(values model.Sysctl) string {
+	content := "# This file is automatically generated by rt-conf, please do not edit\n"
+	for _, key := range values.Keys() {
+		content += fmt.Sprintf("%s = %s\n", key, strings.TrimSpace(values[key]))
+	}
+	return content
+}
+
+// parseConfig parses sysctl configuration file content, in the
+// sysctl.conf(5) format
+func parseConfig(content string) (model.Sysctl, error) {
+	values := make(model.Sysctl)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid line: %q", line)
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return values, scanner.Err()
+}
+
+// readSavedValues reads the saved values, empty if none were saved
+func (rw ReaderWriter) readSavedValues() (model.Sysctl, error) {
+	content, err := os.ReadFile(rw.SavedValuesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(model.Sysctl), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", rw.SavedValuesFile, err)
+	}
+	values, err := parseConfig(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", rw.SavedValuesFile, err)
+	}
+	return values, nil
+}
+
+func (rw ReaderWriter) writeSavedValues(values model.Sysctl) error {
+	if err := os.MkdirAll(filepath.Dir(rw.SavedValuesFile), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %v",
+			filepath.Dir(rw.SavedValuesFile), err)
+	}
+	if err := os.WriteFile(rw.SavedValuesFile, []byte(GenConfig(values)), 0o644); err != nil {
+		return fmt.Errorf("failed to write to %s file: %v", rw.SavedValuesFile, err)
+	}
+	return nil
+}
+
+func ApplySysctlConfig(config *model.InternalConfig) error {
+	utils.PrintTitle("Sysctl")
+	if len(config.Data.Sysctl) == 0 {
+		log.Println("No sysctl values found in config")
+		return nil
+	}
+	return sysctlReaderWriter.applySysctlConfig(config.Data.Sysctl,
+		config.SysctlDropInFile)
+}
+
+// Apply changes based on YAML config
+func (rw ReaderWriter) applySysctlConfig(values model.Sysctl, dropInFile string) error {
+	saved, err := rw.readSavedValues()
+	if err != nil {
+		return err
+	}
+
+	// Save the previous values before the first write, so a failed apply
+	// can still be reverted
+	previous := make(model.Sysctl)
+	for _, key := range values.Keys() {
+		value, err := rw.Read(key)
+		if err != nil {
+			return err
+		}
+		previous[key] = value
+		// Keep the value found before the first apply, as later applies
+		// read the value set by rt-conf
+		if _, ok := saved[key]; !ok {
+			saved[key] = value
+		}
+	}
+	if err := rw.writeSavedValues(saved); err != nil {
+		return err
+	}
+
+	var msgs []string
+	for _, key := range values.Keys() {
+		value := strings.TrimSpace(values[key])
+		if previous[key] == value {
+			msgs = append(msgs, fmt.Sprintf("%s is already %s", key, value))
+			continue
+		}
+		if err := rw.Write(key, value); err != nil {
+			return err
+		}
+		msgs = append(msgs, fmt.Sprintf("Set %s to %s (was %s)", key, value,
+			previous[key]))
+	}
+
+	if dropInFile != "" {
+		if err := os.WriteFile(dropInFile, []byte(GenConfig(values)), 0o644); err != nil {
+			return fmt.Errorf("failed to write to %s file: %v", dropInFile, err)
+		}
+		msgs = append(msgs, "Created drop-in sysctl configuration file: "+dropInFile)
+	}
+	utils.LogTreeStyle(msgs)
+	return nil
+}
+
+// RevertSysctlConfig restores the values saved before the first apply and
+// removes the drop-in configuration file, if any
+func RevertSysctlConfig(dropInFile string) error {
+	utils.PrintTitle("Sysctl Revert")
+	return sysctlReaderWriter.revertSysctlConfig(dropInFile)
+}
+
+func (rw ReaderWriter) revertSysctlConfig(dropInFile string) error {
+	saved, err := rw.readSavedValues()
+	if err != nil {
+		return err
+	}
+
+	var msgs []string
+	for _, key := range saved.Keys() {
+		if err := rw.Write(key, saved[key]); err != nil {
+			return err
+		}
+		msgs = append(msgs, fmt.Sprintf("Restored %s to %s", key, saved[key]))
+	}
+	if len(msgs) == 0 {
+		msgs = append(msgs, "No saved sysctl values to restore")
+	}
+
+	if err := os.Remove(rw.SavedValuesFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %v", rw.SavedValuesFile, err)
+	}
+	if dropInFile != "" {
+		err := os.Remove(dropInFile)
+		if err == nil {
+			msgs = append(msgs, "Removed drop-in sysctl configuration file: "+dropInFile)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %v", dropInFile, err)
+		}
+	}
+	utils.LogTreeStyle(msgs)
+	return nil
+}
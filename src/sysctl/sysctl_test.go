@@ -0,0 +1,188 @@
+package sysctl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/model"
+)
+
+// setupProcSys creates a fake /proc/sys directory with the given values
+func setupProcSys(t *testing.T, values map[string]string) ReaderWriter {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	rw := ReaderWriter{
+		ProcSysPath:     filepath.Join(tmpDir, "proc", "sys"),
+		SavedValuesFile: filepath.Join(tmpDir, "data", "sysctl-saved.conf"),
+	}
+	for key, value := range values {
+		path := rw.path(key)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(value+"\n"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	return rw
+}
+
+func readValue(t *testing.T, rw ReaderWriter, key string) string {
+	t.Helper()
+
+	value, err := rw.Read(key)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", key, err)
+	}
+	return value
+}
+
+func TestApplySysctlConfig(t *testing.T) {
+	rw := setupProcSys(t, map[string]string{
+		"kernel.timer_migration": "1",
+		"vm.stat_interval":       "1",
+	})
+
+	values := model.Sysctl{
+		"kernel.timer_migration": "0",
+		"vm.stat_interval":       "10",
+	}
+	if err := rw.applySysctlConfig(values, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for key, want := range values {
+		if got := readValue(t, rw, key); got != want {
+			t.Errorf("%s: expected %q, got %q", key, want, got)
+		}
+	}
+
+	// Applying again must keep the values found before the first apply
+	values["vm.stat_interval"] = "20"
+	if err := rw.applySysctlConfig(values, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	saved, err := rw.readSavedValues()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved["kernel.timer_migration"] != "1" || saved["vm.stat_interval"] != "1" {
+		t.Errorf("expected the original values to be saved, got %v", saved)
+	}
+}
+
+func TestApplySysctlConfigDropIn(t *testing.T) {
+	rw := setupProcSys(t, map[string]string{"vm.swappiness": "60"})
+	dropIn := filepath.Join(t.TempDir(), "60-rt-conf.conf")
+
+	err := rw.applySysctlConfig(model.Sysctl{"vm.swappiness": "10"}, dropIn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(dropIn)
+	if err != nil {
+		t.Fatalf("failed to read drop-in file: %v", err)
+	}
+	if !strings.Contains(string(content), "vm.swappiness = 10\n") {
+		t.Errorf("unexpected drop-in file content:\n%s", content)
+	}
+}
+
+func TestApplySysctlConfigMissingKey(t *testing.T) {
+	rw := setupProcSys(t, nil)
+
+	err := rw.applySysctlConfig(model.Sysctl{"kernel.watchdog": "0"}, "")
+	if err == nil || !strings.Contains(err.Error(), "error reading") {
+		t.Fatalf("expected read error, got: %v", err)
+	}
+}
+
+// The previous values are saved before the first write, so a failed apply
+// can still be reverted
+func TestApplySysctlConfigWriteError(t *testing.T) {
+	rw := setupProcSys(t, map[string]string{
+		"kernel.watchdog": "1",
+		"vm.swappiness":   "60",
+	})
+	prev := writeOnly
+	t.Cleanup(func() { writeOnly = prev })
+	writeOnly = func(path string, data string) error {
+		if strings.HasSuffix(path, "swappiness") {
+			return fmt.Errorf("error writing to %s: permission denied", path)
+		}
+		return prev(path, data)
+	}
+
+	err := rw.applySysctlConfig(model.Sysctl{
+		"kernel.watchdog": "0",
+		"vm.swappiness":   "10",
+	}, "")
+	if err == nil {
+		t.Fatalf("expected write error")
+	}
+	if got := readValue(t, rw, "kernel.watchdog"); got != "0" {
+		t.Fatalf("expected kernel.watchdog to be written, got %q", got)
+	}
+
+	saved, err := rw.readSavedValues()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved["kernel.watchdog"] != "1" || saved["vm.swappiness"] != "60" {
+		t.Errorf("expected the previous values to be saved, got %v", saved)
+	}
+}
+
+func TestRevertSysctlConfig(t *testing.T) {
+	rw := setupProcSys(t, map[string]string{
+		"kernel.watchdog": "1",
+		"vm.swappiness":   "60",
+	})
+	dropIn := filepath.Join(t.TempDir(), "60-rt-conf.conf")
+
+	err := rw.applySysctlConfig(model.Sysctl{
+		"kernel.watchdog": "0",
+		"vm.swappiness":   "10",
+	}, dropIn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := rw.revertSysctlConfig(dropIn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readValue(t, rw, "kernel.watchdog"); got != "1" {
+		t.Errorf("expected kernel.watchdog to be restored to 1, got %q", got)
+	}
+	if got := readValue(t, rw, "vm.swappiness"); got != "60" {
+		t.Errorf("expected vm.swappiness to be restored to 60, got %q", got)
+	}
+	if _, err := os.Stat(rw.SavedValuesFile); !os.IsNotExist(err) {
+		t.Errorf("expected saved values file to be removed, got: %v", err)
+	}
+	if _, err := os.Stat(dropIn); !os.IsNotExist(err) {
+		t.Errorf("expected drop-in file to be removed, got: %v", err)
+	}
+
+	// Nothing left to revert
+	if err := rw.revertSysctlConfig(dropIn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	values, err := parseConfig("# comment\n; comment\n\nvm.swappiness = 10\nkernel.watchdog=0\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 2 || values["vm.swappiness"] != "10" || values["kernel.watchdog"] != "0" {
+		t.Errorf("unexpected values: %v", values)
+	}
+
+	if _, err := parseConfig("vm.swappiness\n"); err == nil {
+		t.Errorf("expected error for line without value")
+	}
+}
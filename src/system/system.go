@@ -1,6 +1,7 @@
 package system
 
 import (
+	"fmt"
 	"os"
 	"strings"
 )
@@ -15,6 +16,31 @@ const (
 	UbuntuCore
 )
 
+var systemNames = map[SystemType]string{
+	Unknown:    "unknown",
+	Grub:       "grub",
+	Rpi:        "rpi",
+	Uboot:      "uboot",
+	UbuntuCore: "ubuntu-core",
+}
+
+func (s SystemType) String() string {
+	if name, ok := systemNames[s]; ok {
+		return name
+	}
+	return systemNames[Unknown]
+}
+
+// ParseSystemType returns the system type of a name, e.g. grub
+func ParseSystemType(name string) (SystemType, error) {
+	for sys, sysName := range systemNames {
+		if sysName == name {
+			return sys, nil
+		}
+	}
+	return Unknown, fmt.Errorf("unknown system type: %q", name)
+}
+
 var baseDir = "" // baseDir is used to mock the file system in tests
 
 var DetectSystem = func() (SystemType, error) {
@@ -62,3 +62,15 @@ func TestDetectSystemUnknown(t *testing.T) {
 		t.Fatalf("expected Unknown, got %v", sys)
 	}
 }
+
+func TestSystemTypeNames(t *testing.T) {
+	for _, sys := range []SystemType{Unknown, Grub, Rpi, Uboot, UbuntuCore} {
+		parsed, err := ParseSystemType(sys.String())
+		if err != nil || parsed != sys {
+			t.Errorf("%v: expected (%v, nil), got (%v, %v)", sys, sys, parsed, err)
+		}
+	}
+	if _, err := ParseSystemType("lilo"); err == nil {
+		t.Errorf("expected error for unknown system type")
+	}
+}
@@ -0,0 +1,75 @@
+package systemd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/godbus/dbus/v5"
+)
+
+// Bus sets the properties of systemd units at runtime
+type Bus interface {
+	// SetAllowedCPUs sets the AllowedCPUs property of a unit, without
+	// persisting it, as the drop-in files take care of that
+	SetAllowedCPUs(unit string, cpus cpulists.CPUs) error
+}
+
+// dbusBus calls the systemd D-Bus API on the system bus
+// See: https://www.freedesktop.org/software/systemd/man/latest/org.freedesktop.systemd1.html
+type dbusBus struct{}
+
+// unitProperty is a systemd unit property, sent over D-Bus as (sv)
+type unitProperty struct {
+	Name  string
+	Value dbus.Variant
+}
+
+// connectBus connects to the system bus. It is a variable, so tests can
+// connect to a private bus instead.
+var connectBus = func() (*dbus.Conn, error) {
+	return dbus.ConnectSystemBus()
+}
+
+// callManager calls a method of the systemd manager. It is a variable, so
+// tests can check the calls without a bus.
+var callManager = func(method string, args ...any) error {
+	conn, err := connectBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the system bus: %v", err)
+	}
+	defer conn.Close()
+
+	obj := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
+	return obj.Call("org.freedesktop.systemd1.Manager."+method, 0, args...).Err
+}
+
+func (dbusBus) SetAllowedCPUs(unit string, cpus cpulists.CPUs) error {
+	// SetUnitProperties(in s name, in b runtime, in a(sv) properties)
+	properties := []unitProperty{
+		{"AllowedCPUs", dbus.MakeVariant(cpuBitmask(cpus))},
+	}
+	if err := callManager("SetUnitProperties", unit, true, properties); err != nil {
+		return fmt.Errorf("failed to set AllowedCPUs of %s: %v", unit, err)
+	}
+	return nil
+}
+
+// cpuBitmask encodes CPUs the way systemd expects CPU sets over D-Bus: an
+// array of bytes in which bit N of byte M stands for CPU M*8+N
+func cpuBitmask(cpus cpulists.CPUs) []byte {
+	var list []int
+	for cpu := range cpus {
+		list = append(list, cpu)
+	}
+	sort.Ints(list)
+	if len(list) == 0 {
+		return nil
+	}
+
+	mask := make([]byte, list[len(list)-1]/8+1)
+	for _, cpu := range list {
+		mask[cpu/8] |= 1 << (cpu % 8)
+	}
+	return mask
+}
@@ -0,0 +1,113 @@
+package systemd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/canonical/go-snapctl/env"
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/canonical/rt-conf/src/utils"
+)
+
+// Name of the drop-in files created by rt-conf
+const dropInName = "60-rt-conf.conf"
+
+// Units whose allowed CPUs are set, along with the section of their unit
+// file holding the resource control settings
+var units = []struct {
+	name    string
+	section string
+}{
+	{"system.slice", "Slice"},
+	{"user.slice", "Slice"},
+	{"init.scope", "Scope"},
+}
+
+// DropIn is a systemd drop-in configuration file
+type DropIn struct {
+	Path    string
+	Content string
+}
+
+// GenDropIns generates the drop-in files setting the CPU affinity of the
+// service manager and the allowed CPUs of the units, in the systemd
+// configuration directory dir, e.g. /etc/systemd
+func GenDropIns(dir, cpus string) []DropIn {
+	banner := "# This file is automatically generated by rt-conf, please do not edit\n"
+
+	dropIns := []DropIn{{
+		Path:    filepath.Join(dir, "system.conf.d", dropInName),
+		Content: banner + "[Manager]\nCPUAffinity=" + cpus + "\n",
+	}}
+	for _, unit := range units {
+		dropIns = append(dropIns, DropIn{
+			Path: filepath.Join(dir, "system", unit.name+".d", dropInName),
+			Content: banner + "[" + unit.section + "]\n" +
+				"AllowedCPUs=" + cpus + "\n",
+		})
+	}
+	return dropIns
+}
+
+func ApplySystemdConfig(config *model.InternalConfig) error {
+	utils.PrintTitle("Systemd Affinity")
+	if config.Data.Systemd.IsEmpty() {
+		log.Println("No systemd affinity found in config")
+		return nil
+	}
+	// The snap interfaces don't allow setting unit properties over D-Bus
+	if config.Data.Systemd.Runtime && env.Snap() != "" {
+		return fmt.Errorf("runtime is not supported under snap confinement: " +
+			"load the drop-in files with 'sudo systemctl daemon-reload' instead")
+	}
+	return applySystemdConfig(config.Data.Systemd, config.SystemdDir, dbusBus{})
+}
+
+// Apply changes based on YAML config
+func applySystemdConfig(cfg model.SystemdAffinity, dir string, bus Bus) error {
+	cpus, err := cpulists.Parse(cfg.CPUs)
+	if err != nil {
+		return err
+	}
+	list := make([]int, 0, len(cpus))
+	for cpu := range cpus {
+		list = append(list, cpu)
+	}
+	cpuList := cpulists.GenCPUlist(list)
+
+	var msgs []string
+	if dir != "" {
+		for _, dropIn := range GenDropIns(dir, cpuList) {
+			if err := os.MkdirAll(filepath.Dir(dropIn.Path), 0o755); err != nil {
+				return fmt.Errorf("failed to create %s: %v",
+					filepath.Dir(dropIn.Path), err)
+			}
+			if err := os.WriteFile(dropIn.Path, []byte(dropIn.Content), 0o644); err != nil {
+				return fmt.Errorf("failed to write to %s file: %v", dropIn.Path, err)
+			}
+			msgs = append(msgs, "Created drop-in systemd configuration file: "+dropIn.Path)
+		}
+		msgs = append(msgs,
+			"Run 'sudo systemctl daemon-reload' to load the allowed CPUs of the units",
+			"The CPU affinity of the service manager takes effect after a reboot")
+	}
+
+	if cfg.Runtime {
+		for _, unit := range units {
+			if err := bus.SetAllowedCPUs(unit.name, cpus); err != nil {
+				return err
+			}
+			msgs = append(msgs, fmt.Sprintf("Set AllowedCPUs of %s to %s",
+				unit.name, cpuList))
+		}
+	}
+
+	if len(msgs) == 0 {
+		msgs = append(msgs, "Nothing to do: neither the systemd directory nor runtime is set")
+	}
+	utils.LogTreeStyle(msgs)
+	return nil
+}
@@ -0,0 +1,305 @@
+package systemd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/canonical/rt-conf/src/cpulists"
+	"github.com/canonical/rt-conf/src/model"
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeBus records the properties set at runtime, instead of calling systemd
+type fakeBus struct {
+	AllowedCPUs map[string]cpulists.CPUs
+	Err         error
+}
+
+func (b *fakeBus) SetAllowedCPUs(unit string, cpus cpulists.CPUs) error {
+	if b.Err != nil {
+		return b.Err
+	}
+	if b.AllowedCPUs == nil {
+		b.AllowedCPUs = make(map[string]cpulists.CPUs)
+	}
+	b.AllowedCPUs[unit] = cpus
+	return nil
+}
+
+func TestGenDropIns(t *testing.T) {
+	dropIns := GenDropIns("/etc/systemd", "0-1")
+
+	expected := map[string]string{
+		"/etc/systemd/system.conf.d/60-rt-conf.conf":         "[Manager]\nCPUAffinity=0-1\n",
+		"/etc/systemd/system/system.slice.d/60-rt-conf.conf": "[Slice]\nAllowedCPUs=0-1\n",
+		"/etc/systemd/system/user.slice.d/60-rt-conf.conf":   "[Slice]\nAllowedCPUs=0-1\n",
+		"/etc/systemd/system/init.scope.d/60-rt-conf.conf":   "[Scope]\nAllowedCPUs=0-1\n",
+	}
+	if len(dropIns) != len(expected) {
+		t.Fatalf("expected %d drop-ins, got %d", len(expected), len(dropIns))
+	}
+	for _, dropIn := range dropIns {
+		want, ok := expected[dropIn.Path]
+		if !ok {
+			t.Errorf("unexpected drop-in: %s", dropIn.Path)
+			continue
+		}
+		if !strings.HasSuffix(dropIn.Content, want) {
+			t.Errorf("%s: expected content ending with %q, got %q",
+				dropIn.Path, want, dropIn.Content)
+		}
+	}
+}
+
+func TestApplySystemdConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         model.SystemdAffinity
+		withDir     bool
+		wantDropIns bool
+		wantRuntime bool
+	}{
+		{
+			name:        "drop-ins only",
+			cfg:         model.SystemdAffinity{CPUs: "0"},
+			withDir:     true,
+			wantDropIns: true,
+		},
+		{
+			name:        "runtime only",
+			cfg:         model.SystemdAffinity{CPUs: "0", Runtime: true},
+			wantRuntime: true,
+		},
+		{
+			name:        "drop-ins and runtime",
+			cfg:         model.SystemdAffinity{CPUs: "0", Runtime: true},
+			withDir:     true,
+			wantDropIns: true,
+			wantRuntime: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := ""
+			if tc.withDir {
+				dir = t.TempDir()
+			}
+			bus := &fakeBus{}
+
+			if err := applySystemdConfig(tc.cfg, dir, bus); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tc.wantDropIns {
+				path := filepath.Join(dir, "system", "user.slice.d", dropInName)
+				content, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("failed to read drop-in file: %v", err)
+				}
+				if !strings.Contains(string(content), "AllowedCPUs=0\n") {
+					t.Errorf("unexpected drop-in content: %q", content)
+				}
+			}
+
+			if tc.wantRuntime != (len(bus.AllowedCPUs) > 0) {
+				t.Fatalf("expected runtime changes: %v, got %v",
+					tc.wantRuntime, bus.AllowedCPUs)
+			}
+			for _, unit := range []string{"system.slice", "user.slice", "init.scope"} {
+				if tc.wantRuntime && !bus.AllowedCPUs[unit][0] {
+					t.Errorf("expected AllowedCPUs of %s to be set", unit)
+				}
+			}
+		})
+	}
+}
+
+func TestApplySystemdConfigBusError(t *testing.T) {
+	bus := &fakeBus{Err: fmt.Errorf("access denied")}
+
+	err := applySystemdConfig(model.SystemdAffinity{CPUs: "0", Runtime: true}, "", bus)
+	if err == nil || err.Error() != "access denied" {
+		t.Fatalf("expected bus error, got: %v", err)
+	}
+}
+
+func TestApplySystemdConfigRuntimeUnderSnap(t *testing.T) {
+	t.Setenv("SNAP", "/snap/rt-conf/x1")
+
+	err := ApplySystemdConfig(&model.InternalConfig{
+		Data: model.Config{
+			Systemd: model.SystemdAffinity{CPUs: "0", Runtime: true},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "snap confinement") {
+		t.Fatalf("expected confinement error, got: %v", err)
+	}
+}
+
+func TestCPUBitmask(t *testing.T) {
+	testCases := []struct {
+		cpus     cpulists.CPUs
+		expected []byte
+	}{
+		{cpulists.CPUs{}, nil},
+		{cpulists.CPUs{0: true, 1: true}, []byte{0x03}},
+		{cpulists.CPUs{0: true, 9: true}, []byte{0x01, 0x02}},
+		{cpulists.CPUs{16: true}, []byte{0x00, 0x00, 0x01}},
+	}
+	for _, tc := range testCases {
+		if got := cpuBitmask(tc.cpus); !slices.Equal(got, tc.expected) {
+			t.Errorf("%v: expected %v, got %v", tc.cpus, tc.expected, got)
+		}
+	}
+}
+
+func TestDBusSetAllowedCPUs(t *testing.T) {
+	prev := callManager
+	t.Cleanup(func() { callManager = prev })
+
+	var method string
+	var args []any
+	callManager = func(m string, a ...any) error {
+		method, args = m, a
+		return nil
+	}
+
+	err := dbusBus{}.SetAllowedCPUs("system.slice", cpulists.CPUs{0: true, 9: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if method != "SetUnitProperties" {
+		t.Fatalf("expected SetUnitProperties, got %s", method)
+	}
+	// SetUnitProperties(in s name, in b runtime, in a(sv) properties)
+	if sig := dbus.SignatureOf(args...).String(); sig != "sba(sv)" {
+		t.Fatalf("expected signature sba(sv), got %s", sig)
+	}
+	properties := args[2].([]unitProperty)
+	if len(properties) != 1 || properties[0].Name != "AllowedCPUs" {
+		t.Fatalf("unexpected properties: %v", properties)
+	}
+	if mask := properties[0].Value.Value().([]byte); !slices.Equal(mask, []byte{1, 2}) {
+		t.Fatalf("expected mask [1 2], got %v", mask)
+	}
+
+	callManager = func(string, ...any) error {
+		return fmt.Errorf("Access denied")
+	}
+	err = dbusBus{}.SetAllowedCPUs("system.slice", cpulists.CPUs{0: true})
+	if err == nil || !strings.Contains(err.Error(), "Access denied") {
+		t.Fatalf("expected D-Bus error, got: %v", err)
+	}
+}
+
+// fakeManager is exported on a private bus as the systemd manager
+type fakeManager struct {
+	unit       string
+	runtime    bool
+	properties []unitProperty
+}
+
+func (m *fakeManager) SetUnitProperties(unit string, runtime bool, properties []unitProperty) *dbus.Error {
+	if unit == "denied.slice" {
+		return dbus.NewError("org.freedesktop.DBus.Error.AccessDenied",
+			[]any{"Access denied"})
+	}
+	m.unit, m.runtime, m.properties = unit, runtime, properties
+	return nil
+}
+
+// startBus starts a private D-Bus daemon and returns its address
+func startBus(t *testing.T) string {
+	t.Helper()
+
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	dir := t.TempDir()
+	address := "unix:path=" + filepath.Join(dir, "bus")
+	config := "<busconfig>\n" +
+		"  <listen>" + address + "</listen>\n" +
+		"  <auth>EXTERNAL</auth>\n" +
+		"  <policy context=\"default\">\n" +
+		"    <allow send_destination=\"*\"/>\n" +
+		"    <allow own=\"*\"/>\n" +
+		"    <allow receive_sender=\"*\"/>\n" +
+		"  </policy>\n" +
+		"</busconfig>\n"
+	configFile := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+configFile, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("failed to get the daemon output: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	// The address is printed once the daemon listens
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatalf("failed to read the bus address: %v", err)
+	}
+	return address
+}
+
+// The properties are sent over a private bus to a fake systemd manager
+func TestDBusSetAllowedCPUsOnBus(t *testing.T) {
+	address := startBus(t)
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("failed to connect to the bus: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	manager := &fakeManager{}
+	err = conn.Export(manager, "/org/freedesktop/systemd1", "org.freedesktop.systemd1.Manager")
+	if err != nil {
+		t.Fatalf("failed to export the manager: %v", err)
+	}
+	reply, err := conn.RequestName("org.freedesktop.systemd1", dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed to own the systemd name: %v", err)
+	}
+
+	prev := connectBus
+	t.Cleanup(func() { connectBus = prev })
+	connectBus = func() (*dbus.Conn, error) {
+		return dbus.Connect(address)
+	}
+
+	err = dbusBus{}.SetAllowedCPUs("system.slice", cpulists.CPUs{0: true, 9: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if manager.unit != "system.slice" || !manager.runtime {
+		t.Fatalf("expected runtime properties of system.slice, got %q (runtime: %v)",
+			manager.unit, manager.runtime)
+	}
+	if len(manager.properties) != 1 || manager.properties[0].Name != "AllowedCPUs" {
+		t.Fatalf("unexpected properties: %v", manager.properties)
+	}
+	if mask, ok := manager.properties[0].Value.Value().([]byte); !ok || !slices.Equal(mask, []byte{1, 2}) {
+		t.Fatalf("expected mask [1 2], got %v", manager.properties[0].Value)
+	}
+
+	err = dbusBus{}.SetAllowedCPUs("denied.slice", cpulists.CPUs{0: true})
+	if err == nil || !strings.Contains(err.Error(), "Access denied") {
+		t.Fatalf("expected D-Bus error, got: %v", err)
+	}
+}
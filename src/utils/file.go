@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// WriteOnly writes data to an existing file, e.g. in sysfs or procfs,
+// without creating it
+func WriteOnly(path string, data string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", path, err)
+	}
+	defer f.Close()
+
+	_, err = f.Write([]byte(data))
+	if err != nil {
+		return fmt.Errorf("error writing to %s: %v", path, err)
+	}
+	return nil
+}
+
+// ReadTrimmed returns the content of a file without surrounding whitespace
+func ReadTrimmed(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// ReadOptional is ReadTrimmed for files which may not exist, e.g. sysfs
+// files of optional kernel features, returning empty if they don't
+func ReadOptional(path string) (string, error) {
+	content, err := ReadTrimmed(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadTrimmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "name")
+	if err := os.WriteFile(path, []byte("  C1E\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	content, err := ReadTrimmed(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "C1E" {
+		t.Fatalf("expected %q, got %q", "C1E", content)
+	}
+
+	if _, err := ReadTrimmed(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got: %v", err)
+	}
+}
+
+func TestReadOptional(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boost")
+	if err := os.WriteFile(path, []byte("1\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	content, err := ReadOptional(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "1" {
+		t.Fatalf("expected %q, got %q", "1", content)
+	}
+
+	content, err = ReadOptional(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || content != "" {
+		t.Fatalf("expected empty content and no error, got %q, %v", content, err)
+	}
+
+	if _, err := ReadOptional(t.TempDir()); err == nil {
+		t.Fatal("expected an error reading a directory")
+	}
+}